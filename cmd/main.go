@@ -2,12 +2,26 @@ package main
 
 import (
 	_ "SubscriptionService/docs"
+	"SubscriptionService/internal/cache"
+	"SubscriptionService/internal/config"
+	"SubscriptionService/internal/graphqlapi"
+	"SubscriptionService/internal/grpcapi"
+	"SubscriptionService/internal/health"
+	"SubscriptionService/internal/logging"
+	"SubscriptionService/internal/metrics"
+	"SubscriptionService/internal/ratelimit"
+	"SubscriptionService/internal/resilience"
 	"SubscriptionService/internal/subscriptions"
+	"SubscriptionService/internal/tracing"
+	"SubscriptionService/migrations"
 	"SubscriptionService/pkg/db"
 
 	"context"
 	"errors"
-	"github.com/joho/godotenv"
+	"flag"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 	"net/http"
 	"os"
@@ -25,64 +39,240 @@ import (
 // @contact.name API Support
 // @contact.email support@subscription.com
 func main() {
-	//  Инициализация логгера
-	logger, err := zap.NewDevelopment()
+	configPath := flag.String("config", "", "path to a YAML config file (default $CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	migrateCmd := flag.String("migrate", "", "run migrations and exit: up, down or status")
+	migrateSteps := flag.Int("migrate-steps", 1, "number of migrations to roll back with -migrate down")
+	flag.Parse()
+
+	// Загрузка конфигурации: значения по умолчанию, YAML, .env и переменные окружения
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		out, err := cfg.YAML()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	//  Инициализация логгера; уровень можно менять во время работы через /admin/log/level
+	logger, logLevel, err := logging.New(cfg.Log)
 	if err != nil {
 		panic("не удалось инициализировать логгер: " + err.Error())
 	}
 	defer logger.Sync()
+	if cfg.Log.Format == "json" {
+		// Отладочный вывод gin не в JSON и ломает разбор логов
+		gin.SetMode(gin.ReleaseMode)
+	}
 	logger.Info("Запуск приложения")
 
-	// Загрузка конфигурации из .env файла
-	if err := godotenv.Load(); err != nil {
-		logger.Fatal("Ошибка загрузки .env файла", zap.Error(err))
-	}
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		logger.Fatal("Не задана DB_URL в переменных окружения")
+	// Трассировка настраивается до пула соединений, чтобы запросы к БД попадали в traces
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Ошибка настройки трассировки", zap.Error(err))
 	}
 
 	// Подключение к базе данных и создание контекста
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.ConnectTimeout)
 	defer cancel() // Освобождаем ресурсы
 
-	dbPool, err := db.NewPGXPool(ctx, dbURL, logger)
+	dbPool, err := db.NewPGXPool(ctx, cfg.Database.Pool(), logger)
 	if err != nil {
 		logger.Fatal("Ошибка подключения к БД", zap.Error(err))
 	}
 	defer dbPool.Close() // Закрываем соединение с БД при завершении
 	logger.Info("Успешное подключение к PostgreSQL")
 
-	// Инициализация репозитория
-	subRepo := subscriptions.NewSubscriptionRepository(dbPool, logger)
+	// Реплики получают чтения только после успешной проверки, до этого всё идёт в primary
+	var replicaPools []*pgxpool.Pool
+	for _, replicaCfg := range cfg.Database.ReplicaPools() {
+		replicaPool, err := db.NewReplicaPool(ctx, replicaCfg, logger)
+		if err != nil {
+			logger.Fatal("Ошибка создания пула реплики", zap.Error(err))
+		}
+		replicaPools = append(replicaPools, replicaPool)
+	}
+	dbRouter := db.NewRouter(dbPool, replicaPools, cfg.Database.ReplicaMaxLag, logger)
+	defer dbRouter.Close()
+
+	// Миграции встроены в бинарник и выполняются под advisory lock
+	migrator := db.NewMigrator(dbPool, migrations.FS, logger)
+	if *migrateCmd != "" {
+		if err := runMigrateCommand(migrator, *migrateCmd, *migrateSteps); err != nil {
+			logger.Fatal("Ошибка выполнения миграций", zap.Error(err))
+		}
+		return
+	}
+
+	if cfg.Database.AutoMigrate {
+		migrateCtx, migrateCancel := context.WithTimeout(context.Background(), time.Minute)
+		applied, err := migrator.Up(migrateCtx)
+		migrateCancel()
+		if err != nil {
+			logger.Fatal("Ошибка применения миграций", zap.Error(err))
+		}
+		logger.Info("Схема БД актуальна", zap.Int("applied", len(applied)))
+	}
+
+	registry := metrics.NewRegistry()
+	registry.MustRegister(db.NewPoolCollector(dbPool, metrics.Namespace))
+
+	// Инициализация репозиториев; запросы стоимости и отчётов идут через кеш
+	pgSubRepo := subscriptions.NewSubscriptionRepository(dbRouter, logger)
+	// Временные ошибки БД повторяются, а при недоступности БД запросы сразу получают 503
+	var subRepo subscriptions.ISubscriptionRepository = resilience.NewRepository(pgSubRepo,
+		cfg.Database.Retry(), cfg.Database.BreakerThreshold, cfg.Database.BreakerCooldown, registry, logger)
+	if cfg.Cache.Enabled {
+		subRepo = cache.NewRepository(subRepo, cache.NewLRU(cfg.Cache.Size, time.Now), cfg.Cache.TTL, registry, logger)
+	}
+	budgetRepo := subscriptions.NewBudgetRepository(dbPool, logger)
+	anomalyRepo := subscriptions.NewAnomalyRepository(dbPool, logger)
+
+	notifier := subscriptions.NewLogNotifier(logger)
+	budgetEvaluator := subscriptions.NewBudgetEvaluator(budgetRepo, subRepo, notifier, logger)
+	anomalyDetector := subscriptions.NewAnomalyDetector(subRepo, anomalyRepo, notifier, logger, time.Now)
+	spendRollup := subscriptions.NewSpendRollup(pgSubRepo, logger, time.Now, cfg.Jobs.RollupHorizon)
 
 	//Создание сервера и обработчиков, Регистрация маршрутов API
-	apiServer := subscriptions.NewServer(logger)
-	apiHandler := subscriptions.NewSubscriptionHandler(logger, subRepo)
+	accessLogger := logging.Sampled(logger, cfg.Log.AccessSampleInitial, cfg.Log.AccessSampleThereafter)
+	apiServer := subscriptions.NewServer(logger, accessLogger, cfg.HTTP)
+
+	// Метрики: middleware подключается до регистрации маршрутов API
+	httpMetrics := metrics.NewHTTPMetrics(registry)
+	businessMetrics := metrics.NewBusinessMetrics(registry, subRepo, logger, time.Now)
+	apiServer.GetRouter().Use(httpMetrics.Middleware())
+	apiServer.GetRouter().GET("/metrics", gin.WrapH(metrics.Handler(registry)))
+
+	// Ограничение частоты запросов; middleware подключается после метрик, чтобы 429 попадали в них
+	var rateLimiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		var store ratelimit.Store = ratelimit.NewMemoryStore(time.Now)
+		if cfg.RateLimit.Backend == "postgres" {
+			store = ratelimit.NewPostgresStore(dbPool)
+		}
+		rateLimiter = ratelimit.NewLimiter(store, cfg.RateLimit, logger)
+		apiServer.GetRouter().Use(rateLimiter.Middleware())
+	}
+
+	// Проверки состояния; /readyz начинает отвечать 503 в начале Shutdown
+	healthChecker := health.NewChecker(logger, cfg.HTTP.HealthCheckTimeout)
+	healthChecker.Register("database", health.Ping(dbPool))
+	healthChecker.Register("migrations", health.Migrations(migrator))
+	healthChecker.RegisterRoutes(apiServer.GetRouter())
+	apiServer.OnShutdown(healthChecker.SetShuttingDown)
+
+	// Административные эндпоинты без аутентификации слушают отдельный адрес (по умолчанию
+	// только localhost), а не порт API с его ограничением частоты
+	var adminServer *http.Server
+	if cfg.HTTP.AdminAddr != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("/admin/log/level", logLevel)
+		adminMux.Handle("/admin/health", healthChecker.AdminHandler())
+		adminServer = &http.Server{
+			Addr:         cfg.HTTP.AdminAddr,
+			Handler:      adminMux,
+			ReadTimeout:  cfg.HTTP.ReadTimeout,
+			WriteTimeout: cfg.HTTP.WriteTimeout,
+		}
+	}
+
+	// События изменений приходят через LISTEN/NOTIFY, поэтому SSE-клиент видит изменения,
+	// сделанные через любой экземпляр; при завершении потоки закрываются, чтобы не держать Shutdown
+	eventHub := subscriptions.NewEventHub(cfg.HTTP.SSEBuffer, logger)
+	apiServer.OnShutdown(eventHub.Close)
+
+	apiHandler := subscriptions.NewSubscriptionHandler(logger, subRepo, eventHub, cfg.HTTP)
 	apiHandler.RegisterRoutes(apiServer.GetRouter())
+	budgetHandler := subscriptions.NewBudgetHandler(logger, budgetRepo, budgetEvaluator)
+	budgetHandler.RegisterRoutes(apiServer.GetRouter())
+	forecaster := subscriptions.NewForecaster(subRepo, time.Now)
+	reportHandler := subscriptions.NewReportHandler(logger, subRepo, forecaster)
+	reportHandler.RegisterRoutes(apiServer.GetRouter())
+	anomalyHandler := subscriptions.NewAnomalyHandler(logger, anomalyRepo, anomalyDetector)
+	anomalyHandler.RegisterRoutes(apiServer.GetRouter())
+	graphqlHandler, err := graphqlapi.NewHandler(logger, subRepo, time.Now)
+	if err != nil {
+		logger.Fatal("Ошибка построения GraphQL схемы", zap.Error(err))
+	}
+	graphqlHandler.RegisterRoutes(apiServer.GetRouter())
+
+	// gRPC API использует тот же репозиторий, что и HTTP-обработчики
+	grpcServer := grpcapi.NewServer(logger, subRepo)
+
+	// Фоновые задачи останавливаются при завершении приложения
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go budgetEvaluator.Run(jobsCtx, cfg.Jobs.BudgetInterval)
+	go anomalyDetector.Run(jobsCtx, cfg.Jobs.AnomalyInterval)
+	go businessMetrics.Run(jobsCtx, cfg.Jobs.MetricsInterval)
+	go spendRollup.Run(jobsCtx, cfg.Jobs.RollupInterval)
+	go dbRouter.Run(jobsCtx, cfg.Database.ReplicaCheckInterval)
+	go db.NewListener(dbPool, subscriptions.EventsChannel, logger).Run(jobsCtx, eventHub.HandleNotification, eventHub.Reset)
+	if rateLimiter != nil {
+		go rateLimiter.Run(jobsCtx, time.Minute)
+	}
 
 	//Настройка graceful shutdown
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		logger.Info("Запуск HTTP сервера", zap.String("port", "8080"))
-		if err := apiServer.Start(":8080"); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Info("Запуск HTTP сервера", zap.Int("port", cfg.HTTP.Port))
+		if err := apiServer.Start(cfg.HTTPAddr()); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Error("Ошибка сервера", zap.Error(err))
 			shutdown <- syscall.SIGTERM
 		}
 	}()
 
+	if adminServer != nil {
+		go func() {
+			logger.Info("Запуск административного сервера", zap.String("address", adminServer.Addr))
+			if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("Ошибка административного сервера", zap.Error(err))
+				shutdown <- syscall.SIGTERM
+			}
+		}()
+	}
+
+	go func() {
+		logger.Info("Запуск gRPC сервера", zap.Int("port", cfg.GRPC.Port))
+		if err := grpcServer.Start(cfg.GRPCAddr()); err != nil {
+			logger.Error("Ошибка gRPC сервера", zap.Error(err))
+			shutdown <- syscall.SIGTERM
+		}
+	}()
+
 	sig := <-shutdown
 	logger.Info("Получен сигнал завершения", zap.String("signal", sig.String()))
+	stopJobs()
 
 	//Graceful shutdown сервера
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 	defer shutdownCancel()
 
 	if err := apiServer.Shutdown(shutdownCtx); err != nil {
 		logger.Error("Ошибка при завершении работы сервера", zap.Error(err))
 	}
+	if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Ошибка при завершении работы gRPC сервера", zap.Error(err))
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Ошибка при завершении работы административного сервера", zap.Error(err))
+		}
+	}
+
+	// Отправляем накопленные spans перед выходом
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("Ошибка при завершении трассировки", zap.Error(err))
+	}
 
 	logger.Info("Приложение корректно завершило работу")
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+)
+
+// csvHeader — колонки экспорта; при импорте id игнорируется, end_date необязателен.
+var csvHeader = []string{"id", "service_name", "price", "user_id", "start_date", "end_date"}
+
+func runExport(a *app, args []string) error {
+	fs := newFlagSet("export", "")
+	ff := addFilterFlags(fs)
+	file := fs.String("file", "-", "output file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	filters, err := ff.filters()
+	if err != nil {
+		return err
+	}
+	subs, err := a.subs.List(a.ctx, filters)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if err := cw.Write([]string{
+			sub.ID,
+			sub.ServiceName,
+			strconv.Itoa(sub.Price),
+			sub.UserID,
+			formatMonth(sub.StartDate),
+			formatOptionalMonth(sub.EndDate),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	if *file != "-" {
+		fmt.Fprintf(os.Stderr, "exported %d subscriptions to %s\n", len(subs), *file)
+	}
+	return nil
+}
+
+// importResult — итог импорта: созданные подписки и строки, пропущенные из-за пересечений.
+type importResult struct {
+	Created []string    `json:"created"`
+	Skipped []importRow `json:"skipped"`
+}
+
+type importRow struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+func runImport(a *app, args []string) error {
+	fs := newFlagSet("import", "")
+	file := fs.String("file", "-", "input file, - for stdin")
+	allowOverlap := fs.Bool("allow-overlap", false, "import rows that overlap a subscription to the same service")
+	dryRun := fs.Bool("dry-run", false, "only validate the file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Файл проверяется целиком до записи, поэтому ошибка формата не создаёт ни одной подписки.
+	// Строки записываются по одной без общей транзакции: ошибка БД на строке N оставляет
+	// созданными предыдущие строки, их число выводится в ошибке
+	rows, err := readSubscriptionsCSV(r)
+	if err != nil {
+		return err
+	}
+
+	result := importResult{Created: []string{}, Skipped: []importRow{}}
+	if !*dryRun {
+		for _, row := range rows {
+			err := createSubscription(a, row.sub, *allowOverlap)
+			if errors.Is(err, subscriptions.ErrSubscriptionOverlaps) {
+				result.Skipped = append(result.Skipped, importRow{Line: row.line, Reason: err.Error()})
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("line %d: %w (%d subscriptions already created)", row.line, err, len(result.Created))
+			}
+			result.Created = append(result.Created, row.sub.ID)
+		}
+	}
+
+	return a.out.print(result, func(w io.Writer) {
+		if *dryRun {
+			fmt.Fprintf(w, "%d rows are valid\n", len(rows))
+			return
+		}
+		fmt.Fprintf(w, "created %d, skipped %d\n", len(result.Created), len(result.Skipped))
+		for _, s := range result.Skipped {
+			fmt.Fprintf(w, "line %d\t%s\n", s.Line, s.Reason)
+		}
+	})
+}
+
+type csvSubscription struct {
+	line int
+	sub  *subscriptions.Subscription
+}
+
+// readSubscriptionsCSV разбирает и валидирует все строки, собирая ошибки по номерам строк.
+func readSubscriptionsCSV(r io.Reader) ([]csvSubscription, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"service_name", "price", "user_id", "start_date"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header has no %s column", required)
+		}
+	}
+
+	var rows []csvSubscription
+	var errs []error
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		// csv.ParseError уже содержит номер строки, а FieldPos после ошибки недоступен
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		sub, err := parseCSVSubscription(get)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		rows = append(rows, csvSubscription{line: line, sub: sub})
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return rows, nil
+}
+
+func parseCSVSubscription(get func(column string) string) (*subscriptions.Subscription, error) {
+	price, err := strconv.Atoi(get("price"))
+	if err != nil {
+		return nil, subscriptions.ErrInvalidPrice
+	}
+	start, err := time.Parse("01-2006", get("start_date"))
+	if err != nil {
+		return nil, errors.New("invalid start_date format, use MM-YYYY")
+	}
+	var end *time.Time
+	if v := get("end_date"); v != "" {
+		date, err := time.Parse("01-2006", v)
+		if err != nil {
+			return nil, errors.New("invalid end_date format, use MM-YYYY")
+		}
+		end = &date
+	}
+
+	return subscriptions.NewSubscription(get("service_name"), price, get("user_id"), start, end)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+)
+
+const testUser = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func TestParseCSVSubscription(t *testing.T) {
+	valid := map[string]string{
+		"service_name": "Netflix",
+		"price":        "500",
+		"user_id":      testUser,
+		"start_date":   "07-2025",
+	}
+	with := func(column, value string) map[string]string {
+		row := make(map[string]string, len(valid)+1)
+		for k, v := range valid {
+			row[k] = v
+		}
+		row[column] = value
+		return row
+	}
+
+	tests := []struct {
+		name    string
+		row     map[string]string
+		wantEnd *time.Time
+		wantErr string
+	}{
+		{name: "without end date", row: valid},
+		{name: "with end date", row: with("end_date", "12-2025"), wantEnd: datePtr(2025, time.December)},
+		{name: "price is not a number", row: with("price", "5OO"), wantErr: subscriptions.ErrInvalidPrice.Error()},
+		{name: "non-positive price", row: with("price", "0"), wantErr: subscriptions.ErrInvalidPrice.Error()},
+		{name: "start date format", row: with("start_date", "2025-07"), wantErr: "invalid start_date format, use MM-YYYY"},
+		{name: "end date format", row: with("end_date", "July"), wantErr: "invalid end_date format, use MM-YYYY"},
+		{name: "end before start", row: with("end_date", "06-2025"), wantErr: subscriptions.ErrInvalidDateRange.Error()},
+		{name: "short service name", row: with("service_name", "N"), wantErr: subscriptions.ErrInvalidServiceName.Error()},
+		{name: "invalid user id", row: with("user_id", "user"), wantErr: subscriptions.ErrInvalidUserID.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := parseCSVSubscription(func(column string) string { return tt.row[column] })
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if sub.ServiceName != "Netflix" || sub.Price != 500 || sub.UserID != testUser ||
+				!sub.StartDate.Equal(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Fatalf("got %+v", sub)
+			}
+			if (sub.EndDate == nil) != (tt.wantEnd == nil) || (tt.wantEnd != nil && !sub.EndDate.Equal(*tt.wantEnd)) {
+				t.Fatalf("end date %v, want %v", sub.EndDate, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func datePtr(year int, month time.Month) *time.Time {
+	d := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+func TestReadSubscriptionsCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		csv       string
+		wantLines []int
+		wantErr   []string
+	}{
+		{
+			name: "export format",
+			csv: "id,service_name,price,user_id,start_date,end_date\n" +
+				"ignored,Netflix,500," + testUser + ",07-2025,\n" +
+				",Spotify,300," + testUser + ",01-2025,12-2025\n",
+			wantLines: []int{2, 3},
+		},
+		{
+			name: "columns in any order without optional ones",
+			csv: " start_date , user_id,price,service_name\n" +
+				"07-2025," + testUser + ",500, Netflix \n",
+			wantLines: []int{2},
+		},
+		{
+			name: "short rows read missing columns as empty",
+			csv: "service_name,price,user_id,start_date,end_date\n" +
+				"Netflix,500," + testUser + ",07-2025\n",
+			wantLines: []int{2},
+		},
+		{
+			name:      "header only",
+			csv:       "service_name,price,user_id,start_date\n",
+			wantLines: nil,
+		},
+		{
+			name:    "empty file",
+			csv:     "",
+			wantErr: []string{"failed to read CSV header"},
+		},
+		{
+			name:    "missing required column",
+			csv:     "service_name,price,start_date\nNetflix,500,07-2025\n",
+			wantErr: []string{"CSV header has no user_id column"},
+		},
+		{
+			name: "every invalid line is reported",
+			csv: "service_name,price,user_id,start_date\n" +
+				"Netflix,500," + testUser + ",07-2025\n" +
+				"Spotify,free," + testUser + ",07-2025\n" +
+				"Okko,300," + testUser + ",2025-07\n",
+			wantErr: []string{"line 3: " + subscriptions.ErrInvalidPrice.Error(), "line 4: invalid start_date format, use MM-YYYY"},
+		},
+		{
+			name:    "malformed CSV",
+			csv:     "service_name,price,user_id,start_date\n\"Netflix,500," + testUser + ",07-2025\n",
+			wantErr: []string{"extraneous or missing \" in quoted-field"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := readSubscriptionsCSV(strings.NewReader(tt.csv))
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("got %d rows, want an error", len(rows))
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Fatalf("error %q does not contain %q", err, want)
+					}
+				}
+				if rows != nil {
+					t.Fatalf("got rows %+v with an error", rows)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+
+			var lines []int
+			for _, row := range rows {
+				lines = append(lines, row.line)
+				if row.sub.ID != "" || row.sub.UserID != testUser {
+					t.Fatalf("line %d: got %+v", row.line, row.sub)
+				}
+			}
+			if !slices.Equal(lines, tt.wantLines) {
+				t.Fatalf("lines %v, want %v", lines, tt.wantLines)
+			}
+		})
+	}
+
+	// Пробелы вокруг значений не попадают в подписку
+	rows, err := readSubscriptionsCSV(strings.NewReader("service_name,price,user_id,start_date\n Netflix , 500 ," + testUser + ",07-2025\n"))
+	if err != nil || len(rows) != 1 || rows[0].sub.ServiceName != "Netflix" || rows[0].sub.Price != 500 {
+		t.Fatalf("rows %+v, error %v", rows, err)
+	}
+}
@@ -0,0 +1,212 @@
+// Command subctl is the operator tool for the subscription service: it works
+// with the same repositories as the HTTP API directly against PostgreSQL.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"SubscriptionService/internal/config"
+	"SubscriptionService/internal/subscriptions"
+	"SubscriptionService/pkg/db"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(a *app, args []string) error
+}
+
+var commands = []command{
+	{"list", "list subscriptions matching a filter", runList},
+	{"get", "show a subscription by ID", runGet},
+	{"create", "create a subscription", runCreate},
+	{"update", "update fields of a subscription", runUpdate},
+	{"delete", "delete a subscription", runDelete},
+	{"cost", "total cost of subscriptions matching a filter", runCost},
+	{"export", "export subscriptions to CSV", runExport},
+	{"import", "create subscriptions from CSV", runImport},
+	{"migrate", "apply, roll back or list database migrations", runMigrate},
+	{"purge", "delete ended subscriptions or old anomalies", runPurge},
+	{"rollup", "rebuild or inspect the monthly_spend rollup", runRollup},
+}
+
+// app — зависимости, общие для всех команд. Подключение к БД открывается
+// командой через connect после разбора флагов, чтобы справка работала без БД.
+type app struct {
+	ctx        context.Context
+	logger     *zap.Logger
+	dbURL      string
+	configPath string
+	pool       *pgxpool.Pool
+	subs       subscriptions.ISubscriptionRepository
+	spend      *subscriptions.SubscriptionRepository
+	anomalies  subscriptions.IAnomalyRepository
+	out        *printer
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "subctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("subctl", flag.ContinueOnError)
+	// Значение по умолчанию не подставляется во флаг, чтобы справка не показывала пароль
+	dbURL := fs.String("db-url", "", "PostgreSQL connection string (default $DB_URL)")
+	configPath := fs.String("config", "", "path to a YAML config file (default $CONFIG_FILE)")
+	output := fs.String("o", formatTable, "output format: table or json")
+	verbose := fs.Bool("v", false, "log database messages to stderr")
+	fs.Usage = func() { usage(fs) }
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() == 0 {
+		usage(fs)
+		return errors.New("no command given")
+	}
+
+	cmd, ok := findCommand(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("unknown command %q, run subctl -h for the list of commands", fs.Arg(0))
+	}
+	out, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		return err
+	}
+
+	logger := zap.NewNop()
+	if *verbose {
+		if logger, err = zap.NewDevelopment(); err != nil {
+			return err
+		}
+		defer logger.Sync()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a := &app{
+		ctx:        ctx,
+		logger:     logger,
+		dbURL:      *dbURL,
+		configPath: *configPath,
+		out:        out,
+	}
+	defer a.close()
+
+	err = cmd.run(a, fs.Args()[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+func (a *app) connect() error {
+	// -db-url имеет приоритет над DB_URL из окружения, .env и YAML
+	if a.dbURL != "" {
+		os.Setenv("DB_URL", a.dbURL)
+	}
+	cfg, err := config.Load(a.configPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, cfg.Database.ConnectTimeout)
+	defer cancel()
+
+	pool, err := db.NewPGXPool(ctx, cfg.Database.Pool(), a.logger)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	a.pool = pool
+	// subctl читает только из primary: реплики могут не видеть только что сделанных изменений
+	a.spend = subscriptions.NewSubscriptionRepository(db.NewRouter(pool, nil, 0, a.logger), a.logger)
+	a.subs = a.spend
+	a.anomalies = subscriptions.NewAnomalyRepository(pool, a.logger)
+	return nil
+}
+
+func (a *app) close() {
+	if a.pool != nil {
+		a.pool.Close()
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "Usage: subctl [flags] <command> [command flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run subctl <command> -h for command flags.")
+}
+
+// newFlagSet создаёт набор флагов подкоманды с единообразной справкой.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: subctl %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseWithID разбирает флаги подкоманды, принимающей ID подписки;
+// ID можно указать как до, так и после флагов.
+func parseWithID(fs *flag.FlagSet, args []string) (string, error) {
+	var id string
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		id, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if id == "" && fs.NArg() > 0 {
+		id = fs.Arg(0)
+	}
+	if id == "" {
+		fs.Usage()
+		return "", errors.New("subscription ID is required")
+	}
+	return id, nil
+}
+
+func parseMonth(flagName, value string) (time.Time, error) {
+	date, err := time.Parse("01-2006", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s format, use MM-YYYY", flagName)
+	}
+	return date, nil
+}
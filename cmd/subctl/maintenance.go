@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"time"
+
+	"SubscriptionService/migrations"
+	"SubscriptionService/pkg/db"
+)
+
+func runMigrate(a *app, args []string) error {
+	fs := newFlagSet("migrate", "[up|down|status]")
+	dir := fs.String("dir", "", "read migrations from a directory instead of the embedded ones")
+	steps := fs.Int("steps", 1, "number of migrations to roll back with down")
+
+	direction := "up"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		direction, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	var source iofs.FS = migrations.FS
+	if *dir != "" {
+		source = os.DirFS(*dir)
+	}
+	migrator := db.NewMigrator(a.pool, source, a.logger)
+
+	switch direction {
+	case "up", "down":
+		var done []db.Migration
+		var err error
+		verb := "applied"
+		if direction == "up" {
+			done, err = migrator.Up(a.ctx)
+		} else {
+			done, err = migrator.Down(a.ctx, *steps)
+			verb = "reverted"
+		}
+		if done == nil {
+			done = []db.Migration{}
+		}
+		// Уже выполненные миграции выводятся и при ошибке на следующей
+		if printErr := a.out.print(done, func(w io.Writer) {
+			if len(done) == 0 && err == nil {
+				fmt.Fprintln(w, "nothing to do")
+			}
+			for _, m := range done {
+				fmt.Fprintf(w, "%s\t%06d\t%s\n", verb, m.Version, m.Name)
+			}
+		}); printErr != nil {
+			return printErr
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(a.ctx)
+		if err != nil {
+			return err
+		}
+		return a.out.print(statuses, func(w io.Writer) {
+			fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+			for _, s := range statuses {
+				appliedAt := "pending"
+				if s.AppliedAt != nil {
+					appliedAt = s.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%06d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+			}
+		})
+	default:
+		return fmt.Errorf("unknown migrate command %q, use up, down or status", direction)
+	}
+}
+
+// purgeResult — число записей, удалённых командой purge.
+type purgeResult struct {
+	Target  string `json:"target"`
+	Deleted int64  `json:"deleted"`
+}
+
+func runPurge(a *app, args []string) error {
+	fs := newFlagSet("purge", "subscriptions|anomalies")
+	before := fs.String("before", "", "delete subscriptions ended or anomalies detected for months before MM-YYYY (required)")
+	if len(args) == 0 || args[0] == "" || args[0][0] == '-' {
+		fs.Usage()
+		return errors.New("purge target is required: subscriptions or anomalies")
+	}
+	target := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	month, err := parseMonth("before", *before)
+	if err != nil {
+		return err
+	}
+
+	var deleted int64
+	switch target {
+	case "subscriptions":
+		deleted, err = a.subs.PurgeEnded(a.ctx, month)
+	case "anomalies":
+		deleted, err = a.anomalies.Purge(a.ctx, month)
+	default:
+		return fmt.Errorf("unknown purge target %q, use subscriptions or anomalies", target)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.out.print(purgeResult{Target: target, Deleted: deleted}, func(w io.Writer) {
+		fmt.Fprintf(w, "deleted %d %s\n", deleted, target)
+	})
+}
+
+func runRollup(a *app, args []string) error {
+	fs := newFlagSet("rollup", "backfill|status")
+	horizon := fs.Int("horizon", 24, "months after the current one to materialize with backfill")
+	through := fs.String("through", "", "materialize through MM-YYYY instead of -horizon")
+	if len(args) == 0 || args[0] == "" || args[0][0] == '-' {
+		fs.Usage()
+		return errors.New("rollup command is required: backfill or status")
+	}
+	action := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	switch action {
+	case "backfill":
+		month := time.Now().UTC().AddDate(0, *horizon, 0)
+		if *through != "" {
+			var err error
+			if month, err = parseMonth("through", *through); err != nil {
+				return err
+			}
+		}
+		if _, err := a.spend.RebuildSpend(a.ctx, month, true, func(done, total int) {
+			if done%1000 == 0 || done == total {
+				fmt.Fprintf(os.Stderr, "rebuilt %d/%d subscriptions\n", done, total)
+			}
+		}); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return fmt.Errorf("unknown rollup command %q, use backfill or status", action)
+	}
+
+	state, err := a.spend.SpendState(a.ctx)
+	if err != nil {
+		return err
+	}
+	return a.out.print(state, func(w io.Writer) {
+		fmt.Fprintf(w, "ready\t%t\nthrough\t%s\n", state.Ready, state.Through.Format("01-2006"))
+	})
+}
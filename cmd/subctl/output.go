@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"SubscriptionService/internal/subscriptions"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// printer выводит результаты команд таблицей для людей или JSON для скриптов.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	if format != formatTable && format != formatJSON {
+		return nil, fmt.Errorf("unknown output format %q, use table or json", format)
+	}
+	return &printer{w: w, format: format}, nil
+}
+
+// print выводит v как JSON либо вызывает table для табличного вывода.
+func (p *printer) print(v any, table func(w io.Writer)) error {
+	if p.format == formatJSON {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+func (p *printer) subscriptions(subs []*subscriptions.Subscription) error {
+	if subs == nil {
+		subs = []*subscriptions.Subscription{}
+	}
+	return p.print(subs, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tSERVICE\tPRICE\tEFFECTIVE\tUSER\tSTART\tEND\tCANCELLED")
+		for _, sub := range subs {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+				sub.ID,
+				sub.ServiceName,
+				sub.Price,
+				sub.EffectivePrice,
+				sub.UserID,
+				formatMonth(sub.StartDate),
+				formatOptionalMonth(sub.EndDate),
+				string(sub.CancellationReason),
+			)
+		}
+	})
+}
+
+func (p *printer) subscription(sub *subscriptions.Subscription) error {
+	return p.subscriptions([]*subscriptions.Subscription{sub})
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+)
+
+// filterFlags — фильтры, общие для list, cost и export; совпадают с параметрами REST API.
+type filterFlags struct {
+	userID      string
+	serviceName string
+	from        string
+	to          string
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	f := &filterFlags{}
+	fs.StringVar(&f.userID, "user-id", "", "filter by user ID")
+	fs.StringVar(&f.serviceName, "service-name", "", "filter by service name")
+	fs.StringVar(&f.from, "from", "", "first month MM-YYYY")
+	fs.StringVar(&f.to, "to", "", "last month MM-YYYY")
+	return f
+}
+
+func (f *filterFlags) filters() (map[string]interface{}, error) {
+	filters := make(map[string]interface{})
+
+	if f.userID != "" {
+		filters["user_id"] = f.userID
+	}
+	if f.serviceName != "" {
+		filters["service_name"] = f.serviceName
+	}
+	if f.from != "" {
+		date, err := parseMonth("from", f.from)
+		if err != nil {
+			return nil, err
+		}
+		filters["start_date_from"] = date
+	}
+	if f.to != "" {
+		date, err := parseMonth("to", f.to)
+		if err != nil {
+			return nil, err
+		}
+		filters["start_date_to"] = date
+	}
+
+	return filters, nil
+}
+
+func runList(a *app, args []string) error {
+	fs := newFlagSet("list", "")
+	ff := addFilterFlags(fs)
+	limit := fs.Int("limit", 0, "maximum number of subscriptions (0 for all)")
+	offset := fs.Int("offset", 0, "number of subscriptions to skip")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	filters, err := ff.filters()
+	if err != nil {
+		return err
+	}
+	if *limit > 0 {
+		filters["limit"] = *limit
+	}
+	if *offset > 0 {
+		filters["offset"] = *offset
+	}
+
+	subs, err := a.subs.List(a.ctx, filters)
+	if err != nil {
+		return err
+	}
+	return a.out.subscriptions(subs)
+}
+
+func runGet(a *app, args []string) error {
+	fs := newFlagSet("get", "<id>")
+	id, err := parseWithID(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	sub, err := a.subs.GetByID(a.ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.out.subscription(sub)
+}
+
+func runCreate(a *app, args []string) error {
+	fs := newFlagSet("create", "")
+	serviceName := fs.String("service-name", "", "service name (required)")
+	price := fs.Int("price", 0, "monthly price in rubles (required)")
+	userID := fs.String("user-id", "", "owner user ID (required)")
+	startDate := fs.String("start-date", "", "first month MM-YYYY (required)")
+	endDate := fs.String("end-date", "", "last month MM-YYYY")
+	allowOverlap := fs.Bool("allow-overlap", false, "create even if it overlaps a subscription to the same service")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	start, err := parseMonth("start-date", *startDate)
+	if err != nil {
+		return err
+	}
+	var end *time.Time
+	if *endDate != "" {
+		date, err := parseMonth("end-date", *endDate)
+		if err != nil {
+			return err
+		}
+		end = &date
+	}
+
+	sub, err := subscriptions.NewSubscription(*serviceName, *price, *userID, start, end)
+	if err != nil {
+		return err
+	}
+
+	if err := createSubscription(a, sub, *allowOverlap); err != nil {
+		return err
+	}
+	return a.out.subscription(sub)
+}
+
+func runUpdate(a *app, args []string) error {
+	fs := newFlagSet("update", "<id>")
+	serviceName := fs.String("service-name", "", "new service name")
+	price := fs.Int("price", 0, "new monthly price, effective from -price-from")
+	priceFrom := fs.String("price-from", "", "first month MM-YYYY of the new price (default: current month)")
+	userID := fs.String("user-id", "", "new owner user ID")
+	startDate := fs.String("start-date", "", "new first month MM-YYYY")
+	endDate := fs.String("end-date", "", "new last month MM-YYYY, empty to make it open-ended")
+	id, err := parseWithID(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	sub, err := a.subs.GetByID(a.ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Меняются только явно переданные флаги, остальные поля остаются прежними.
+	// Базовая цена действует с первого месяца, поэтому новая цена планируется
+	// отдельным изменением и не пересчитывает прошлые месяцы.
+	var parseErr error
+	var newPrice bool
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "service-name":
+			sub.ServiceName = strings.TrimSpace(*serviceName)
+		case "price":
+			newPrice = true
+		case "user-id":
+			sub.UserID = strings.TrimSpace(*userID)
+		case "start-date":
+			date, err := parseMonth("start-date", *startDate)
+			parseErr = errors.Join(parseErr, err)
+			sub.StartDate = date
+		case "end-date":
+			sub.EndDate = nil
+			if *endDate != "" {
+				date, err := parseMonth("end-date", *endDate)
+				parseErr = errors.Join(parseErr, err)
+				sub.EndDate = &date
+			}
+		}
+	})
+	if parseErr != nil {
+		return parseErr
+	}
+	if err := sub.Validate(); err != nil {
+		return err
+	}
+
+	var change *subscriptions.PriceChange
+	if newPrice {
+		from := time.Now().UTC()
+		if *priceFrom != "" {
+			if from, err = parseMonth("price-from", *priceFrom); err != nil {
+				return err
+			}
+		}
+		// Проверяем изменение цены до записи, чтобы не применить update наполовину
+		if change, err = sub.SchedulePriceChange(*price, from); err != nil {
+			return err
+		}
+	}
+
+	if err := a.subs.Update(a.ctx, sub); err != nil {
+		return err
+	}
+	if change != nil {
+		if err := a.subs.AddPriceChange(a.ctx, change); err != nil {
+			return err
+		}
+	}
+
+	updated, err := a.subs.GetByID(a.ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.out.subscription(updated)
+}
+
+func runDelete(a *app, args []string) error {
+	fs := newFlagSet("delete", "<id>")
+	id, err := parseWithID(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	if err := a.subs.Delete(a.ctx, id); err != nil {
+		return err
+	}
+	return a.out.print(map[string]string{"deleted": id}, func(w io.Writer) {
+		fmt.Fprintf(w, "deleted %s\n", id)
+	})
+}
+
+func runCost(a *app, args []string) error {
+	fs := newFlagSet("cost", "")
+	ff := addFilterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	filters, err := ff.filters()
+	if err != nil {
+		return err
+	}
+
+	total, err := a.subs.CalculateMonthlyCost(a.ctx, filters)
+	if err != nil {
+		return err
+	}
+	return a.out.print(subscriptions.CalculateCostResponse{TotalCost: total}, func(w io.Writer) {
+		fmt.Fprintf(w, "TOTAL COST\n%d\n", total)
+	})
+}
+
+// createSubscription повторяет POST /subscriptions: пересечение с подпиской
+// на тот же сервис допускается только явно. Отказ из-за пересечения
+// оборачивает subscriptions.ErrSubscriptionOverlaps.
+func createSubscription(a *app, sub *subscriptions.Subscription, allowOverlap bool) error {
+	if allowOverlap {
+		return a.subs.Create(a.ctx, sub)
+	}
+
+	conflicts, err := a.subs.CreateNonOverlapping(a.ctx, sub)
+	if !errors.Is(err, subscriptions.ErrSubscriptionOverlaps) {
+		return err
+	}
+
+	ids := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		ids[i] = c.ID
+	}
+	return fmt.Errorf("%w: %s subscription(s) %s, use -allow-overlap to create it anyway",
+		err, sub.ServiceName, strings.Join(ids, ", "))
+}
+
+func formatMonth(t time.Time) string {
+	return t.Format("01-2006")
+}
+
+func formatOptionalMonth(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatMonth(*t)
+}
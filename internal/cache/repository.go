@@ -0,0 +1,425 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"SubscriptionService/internal/logging"
+	"SubscriptionService/internal/metrics"
+	"SubscriptionService/internal/subscriptions"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Ключи поколений. Закешированная запись содержит в ключе поколения, от которых
+// зависит, поэтому для инвалидации достаточно сменить поколение: старые записи
+// становятся недостижимы и вытесняются по LRU или TTL.
+const (
+	// epochKey входит во все ключи и меняется при массовом удалении
+	epochKey = "subs:gen:epoch"
+	// allKey входит в ключи запросов без фильтра по пользователю и меняется при любой записи
+	allKey = "subs:gen:all"
+	// userKeyPrefix — поколение данных одного пользователя
+	userKeyPrefix = "subs:gen:user:"
+)
+
+// Repository decorates ISubscriptionRepository with read-through caching of
+// cost and report queries: CalculateMonthlyCost, ListForPeriod and ChurnStats.
+// Writes go to the wrapped repository and then invalidate the entries of the
+// users they affect; queries without a user filter are invalidated by any write.
+// Store failures are logged and the wrapped repository is used directly.
+type Repository struct {
+	// Обёрнутый репозиторий не встраивается: новый метод записи без явной
+	// реализации здесь обходил бы инвалидацию
+	repo     subscriptions.ISubscriptionRepository
+	store    Store
+	ttl      time.Duration
+	logger   *zap.Logger
+	requests *prometheus.CounterVec
+}
+
+func NewRepository(repo subscriptions.ISubscriptionRepository, store Store, ttl time.Duration, reg prometheus.Registerer, logger *zap.Logger) *Repository {
+	r := &Repository{
+		repo:   repo,
+		store:  store,
+		ttl:    ttl,
+		logger: logger,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cache",
+			Name:      "requests_total",
+			Help:      "Cache lookups by repository method and result: hit, miss or error.",
+		}, []string{"method", "result"}),
+	}
+	reg.MustRegister(r.requests)
+	return r
+}
+
+func (r *Repository) CalculateMonthlyCost(ctx context.Context, filters map[string]interface{}) (int, error) {
+	return cached(ctx, r, "CalculateMonthlyCost", filters, func() (int, error) {
+		return r.repo.CalculateMonthlyCost(ctx, filters)
+	})
+}
+
+func (r *Repository) ListForPeriod(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.Subscription, error) {
+	entries, err := cached(ctx, r, "ListForPeriod", filters, func() ([]cachedSubscription, error) {
+		subs, err := r.repo.ListForPeriod(ctx, filters)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]cachedSubscription, len(subs))
+		for i, sub := range subs {
+			entries[i] = cachedSubscription{Subscription: sub, PriceChanges: sub.PriceChanges}
+		}
+		return entries, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	subs := make([]*subscriptions.Subscription, len(entries))
+	for i, e := range entries {
+		e.Subscription.PriceChanges = e.PriceChanges
+		subs[i] = e.Subscription
+	}
+	return subs, nil
+}
+
+func (r *Repository) ChurnStats(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.ChurnStat, error) {
+	return cached(ctx, r, "ChurnStats", filters, func() ([]*subscriptions.ChurnStat, error) {
+		return r.repo.ChurnStats(ctx, filters)
+	})
+}
+
+func (r *Repository) MonthlySpend(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.MonthlySpend, bool, error) {
+	entry, err := cached(ctx, r, "MonthlySpend", filters, func() (cachedSpend, error) {
+		spend, ok, err := r.repo.MonthlySpend(ctx, filters)
+		return cachedSpend{Spend: spend, OK: ok}, err
+	})
+	return entry.Spend, entry.OK, err
+}
+
+// Остальные чтения не кешируются: они отдают отдельные подписки и списки, которые
+// должны отражать запись сразу.
+
+func (r *Repository) GetByID(ctx context.Context, id string) (*subscriptions.Subscription, error) {
+	return r.repo.GetByID(ctx, id)
+}
+
+func (r *Repository) List(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.Subscription, error) {
+	return r.repo.List(ctx, filters)
+}
+
+func (r *Repository) StreamList(ctx context.Context, filters map[string]interface{}, fn func(*subscriptions.Subscription) error) error {
+	return r.repo.StreamList(ctx, filters, fn)
+}
+
+func (r *Repository) FindDuplicates(ctx context.Context, userID string) ([]*subscriptions.Overlap, error) {
+	return r.repo.FindDuplicates(ctx, userID)
+}
+
+func (r *Repository) FindOverlapping(ctx context.Context, sub *subscriptions.Subscription) ([]*subscriptions.Subscription, error) {
+	return r.repo.FindOverlapping(ctx, sub)
+}
+
+func (r *Repository) ListPriceChanges(ctx context.Context, subscriptionID string) ([]*subscriptions.PriceChange, error) {
+	return r.repo.ListPriceChanges(ctx, subscriptionID)
+}
+
+// cachedSpend хранит и ответ агрегата, и признак того, что агрегат смог ответить.
+type cachedSpend struct {
+	Spend []*subscriptions.MonthlySpend `json:"spend"`
+	OK    bool                          `json:"ok"`
+}
+
+// cachedSubscription сохраняет график цен, который исключён из JSON подписки,
+// но нужен для расчёта стоимости.
+type cachedSubscription struct {
+	*subscriptions.Subscription
+	PriceChanges []*subscriptions.PriceChange `json:"price_changes,omitempty"`
+}
+
+// cached возвращает значение из хранилища или вычисляет его через load и сохраняет.
+// Значения хранятся в JSON, поэтому вызывающий всегда получает свою копию.
+func cached[T any](ctx context.Context, r *Repository, method string, filters map[string]interface{}, load func() (T, error)) (T, error) {
+	key, err := r.key(ctx, method, filters)
+	if err != nil {
+		r.fail(ctx, method, err)
+		return load()
+	}
+
+	data, ok, err := r.store.Get(ctx, key)
+	switch {
+	case err != nil:
+		r.fail(ctx, method, err)
+		return load()
+	case ok:
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			r.requests.WithLabelValues(method, "hit").Inc()
+			return value, nil
+		}
+		r.fail(ctx, method, fmt.Errorf("failed to decode cache entry: %w", err))
+	default:
+		r.requests.WithLabelValues(method, "miss").Inc()
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err != nil {
+		r.fail(ctx, method, err)
+	} else if err := r.store.Set(ctx, key, data, r.ttl); err != nil {
+		r.fail(ctx, method, err)
+	}
+	return value, nil
+}
+
+func (r *Repository) fail(ctx context.Context, method string, err error) {
+	r.requests.WithLabelValues(method, "error").Inc()
+	logging.FromContext(ctx, r.logger).Warn("cache unavailable, querying database",
+		zap.String("method", method), zap.Error(err))
+}
+
+// key строит ключ из метода, нормализованных фильтров и поколений данных, от которых
+// зависит результат.
+func (r *Repository) key(ctx context.Context, method string, filters map[string]interface{}) (string, error) {
+	genKeys := []string{epochKey}
+	users := filterUsers(filters)
+	if len(users) == 0 {
+		genKeys = append(genKeys, allKey)
+	}
+	for _, user := range users {
+		genKeys = append(genKeys, userKeyPrefix+user)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(normalizeFilters(filters)))
+	for _, genKey := range genKeys {
+		gen, err := r.generation(ctx, genKey)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(gen))
+	}
+	return "subs:" + method + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// generation возвращает текущее поколение, создавая новое, если его нет. Пустое
+// значение не используется: иначе после вытеснения поколения снова стали бы
+// доступны записи, созданные до первой инвалидации.
+func (r *Repository) generation(ctx context.Context, key string) (string, error) {
+	gen, ok, err := r.store.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return string(gen), nil
+	}
+	return r.bump(ctx, key)
+}
+
+func (r *Repository) bump(ctx context.Context, key string) (string, error) {
+	var b [8]byte
+	rand.Read(b[:])
+	gen := hex.EncodeToString(b[:])
+	if err := r.store.Set(ctx, key, []byte(gen), 0); err != nil {
+		return "", err
+	}
+	return gen, nil
+}
+
+// invalidate сбрасывает записи пользователей users и все запросы без фильтра по
+// пользователю. nil users означает, что затронутые пользователи неизвестны, и
+// сбрасывается весь кеш.
+func (r *Repository) invalidate(ctx context.Context, users []string) {
+	keys := []string{allKey}
+	if users == nil {
+		keys = []string{epochKey}
+	}
+	for _, user := range users {
+		keys = append(keys, userKeyPrefix+user)
+	}
+
+	for _, key := range keys {
+		if _, err := r.bump(ctx, key); err != nil {
+			// Записи устареют не позже чем через TTL
+			logging.FromContext(ctx, r.logger).Error("failed to invalidate cache",
+				zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// affectedUsers возвращает владельца и участников подписки, чьи расчёты зависят от неё.
+func (r *Repository) affectedUsers(ctx context.Context, subscriptionID string, extra ...string) []string {
+	sub, err := r.repo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		return nil
+	}
+	users := append([]string{sub.UserID}, extra...)
+	for _, m := range sub.Members {
+		users = append(users, m.UserID)
+	}
+	return users
+}
+
+func (r *Repository) Create(ctx context.Context, sub *subscriptions.Subscription) error {
+	if err := r.repo.Create(ctx, sub); err != nil {
+		return err
+	}
+	r.invalidate(ctx, []string{sub.UserID})
+	return nil
+}
+
+func (r *Repository) CreateNonOverlapping(ctx context.Context, sub *subscriptions.Subscription) ([]*subscriptions.Subscription, error) {
+	conflicts, err := r.repo.CreateNonOverlapping(ctx, sub)
+	if err != nil {
+		return conflicts, err
+	}
+	r.invalidate(ctx, []string{sub.UserID})
+	return nil, nil
+}
+
+func (r *Repository) Update(ctx context.Context, sub *subscriptions.Subscription) error {
+	// Владелец мог смениться, поэтому затронуты и прежние пользователи подписки
+	users := r.affectedUsers(ctx, sub.ID, sub.UserID)
+	if err := r.repo.Update(ctx, sub); err != nil {
+		return err
+	}
+	r.invalidate(ctx, users)
+	return nil
+}
+
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	users := r.affectedUsers(ctx, id)
+	if err := r.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx, users)
+	return nil
+}
+
+func (r *Repository) PurgeEnded(ctx context.Context, before time.Time) (int64, error) {
+	n, err := r.repo.PurgeEnded(ctx, before)
+	if err == nil && n > 0 {
+		r.invalidate(ctx, nil)
+	}
+	return n, err
+}
+
+func (r *Repository) Cancel(ctx context.Context, subscriptionID string, cancellation subscriptions.Cancellation) (*subscriptions.Subscription, error) {
+	sub, err := r.repo.Cancel(ctx, subscriptionID, cancellation)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, r.affectedUsers(ctx, subscriptionID))
+	return sub, nil
+}
+
+func (r *Repository) AddPriceChange(ctx context.Context, change *subscriptions.PriceChange) error {
+	if err := r.repo.AddPriceChange(ctx, change); err != nil {
+		return err
+	}
+	r.invalidate(ctx, r.affectedUsers(ctx, change.SubscriptionID))
+	return nil
+}
+
+func (r *Repository) Pause(ctx context.Context, subscriptionID string, from time.Time, to *time.Time) (*subscriptions.Pause, error) {
+	pause, err := r.repo.Pause(ctx, subscriptionID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, r.affectedUsers(ctx, subscriptionID))
+	return pause, nil
+}
+
+func (r *Repository) Resume(ctx context.Context, subscriptionID string, month time.Time) (*subscriptions.Pause, error) {
+	pause, err := r.repo.Resume(ctx, subscriptionID, month)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, r.affectedUsers(ctx, subscriptionID))
+	return pause, nil
+}
+
+func (r *Repository) AddDiscount(ctx context.Context, subscriptionID string, discount *subscriptions.Discount) error {
+	if err := r.repo.AddDiscount(ctx, subscriptionID, discount); err != nil {
+		return err
+	}
+	r.invalidate(ctx, r.affectedUsers(ctx, subscriptionID))
+	return nil
+}
+
+func (r *Repository) RemoveDiscount(ctx context.Context, subscriptionID, discountID string) error {
+	if err := r.repo.RemoveDiscount(ctx, subscriptionID, discountID); err != nil {
+		return err
+	}
+	r.invalidate(ctx, r.affectedUsers(ctx, subscriptionID))
+	return nil
+}
+
+func (r *Repository) AddMember(ctx context.Context, subscriptionID string, member *subscriptions.Member) error {
+	if err := r.repo.AddMember(ctx, subscriptionID, member); err != nil {
+		return err
+	}
+	r.invalidate(ctx, r.affectedUsers(ctx, subscriptionID))
+	return nil
+}
+
+func (r *Repository) RemoveMember(ctx context.Context, subscriptionID, userID string) error {
+	if err := r.repo.RemoveMember(ctx, subscriptionID, userID); err != nil {
+		return err
+	}
+	r.invalidate(ctx, r.affectedUsers(ctx, subscriptionID, userID))
+	return nil
+}
+
+// filterUsers возвращает пользователей, которыми ограничен запрос.
+func filterUsers(filters map[string]interface{}) []string {
+	var users []string
+	if v, ok := filters["user_id"].(string); ok {
+		users = append(users, v)
+	}
+	if v, ok := filters["user_ids"].([]string); ok {
+		users = append(users, v...)
+	}
+	return users
+}
+
+// normalizeFilters приводит фильтры к каноническому виду: ключи по алфавиту, даты в
+// UTC, списки отсортированы, — чтобы одинаковые запросы давали одинаковый ключ.
+func normalizeFilters(filters map[string]interface{}) string {
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		switch v := filters[k].(type) {
+		case time.Time:
+			b.WriteString(v.UTC().Format(time.RFC3339Nano))
+		case []string:
+			sorted := append([]string(nil), v...)
+			sort.Strings(sorted)
+			b.WriteString(strings.Join(sorted, ","))
+		default:
+			fmt.Fprintf(&b, "%T:%v", v, v)
+		}
+		b.WriteByte(';')
+	}
+	return b.String()
+}
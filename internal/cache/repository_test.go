@@ -0,0 +1,337 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	owner  = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+	member = "7b0d4a4e-3f36-4c0e-9a57-1f0f3bd0f2a1"
+	other  = "c2a4b3f0-6a51-4b3e-8b7e-5d4c3b2a1f00"
+)
+
+func month(year int, m time.Month) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// countingRepository считает обращения к кешируемым методам, чтобы отличать
+// попадания в кеш от запросов к репозиторию.
+type countingRepository struct {
+	*subscriptions.MemoryRepository
+	calls int
+}
+
+func (r *countingRepository) CalculateMonthlyCost(ctx context.Context, filters map[string]interface{}) (int, error) {
+	r.calls++
+	return r.MemoryRepository.CalculateMonthlyCost(ctx, filters)
+}
+
+func (r *countingRepository) ListForPeriod(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.Subscription, error) {
+	r.calls++
+	return r.MemoryRepository.ListForPeriod(ctx, filters)
+}
+
+func (r *countingRepository) ChurnStats(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.ChurnStat, error) {
+	r.calls++
+	return r.MemoryRepository.ChurnStats(ctx, filters)
+}
+
+type cacheEnv struct {
+	repo  *Repository
+	inner *countingRepository
+	sub   *subscriptions.Subscription
+}
+
+// newCacheEnv создаёт подписку владельца с участником и подписку другого пользователя.
+func newCacheEnv(t *testing.T) *cacheEnv {
+	t.Helper()
+	ctx := context.Background()
+	inner := &countingRepository{MemoryRepository: subscriptions.NewMemoryRepository()}
+	env := &cacheEnv{
+		repo:  NewRepository(inner, NewLRU(1000, time.Now), time.Hour, prometheus.NewRegistry(), zap.NewNop()),
+		inner: inner,
+	}
+
+	env.sub = &subscriptions.Subscription{ServiceName: "Netflix", Price: 500, UserID: owner, StartDate: month(2024, time.January)}
+	if err := inner.Create(ctx, env.sub); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	share := 40
+	if err := inner.AddMember(ctx, env.sub.ID, &subscriptions.Member{UserID: member, SharePercent: &share}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	if err := inner.Create(ctx, &subscriptions.Subscription{ServiceName: "Spotify", Price: 300, UserID: other, StartDate: month(2024, time.January)}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	return env
+}
+
+func (e *cacheEnv) generation(t *testing.T, key string) string {
+	t.Helper()
+	gen, err := e.repo.generation(context.Background(), key)
+	if err != nil {
+		t.Fatalf("generation: %v", err)
+	}
+	return gen
+}
+
+var userIDs = map[string]string{"owner": owner, "member": member, "other": other}
+
+// queries — кешируемые запросы: по каждому пользователю и без фильтра.
+var queries = map[string]map[string]interface{}{
+	"owner":  {"user_id": owner, "start_date_from": month(2024, time.January), "start_date_to": month(2024, time.December)},
+	"member": {"user_id": member, "start_date_from": month(2024, time.January), "start_date_to": month(2024, time.December)},
+	"other":  {"user_id": other, "start_date_from": month(2024, time.January), "start_date_to": month(2024, time.December)},
+	"all":    {"start_date_from": month(2024, time.January), "start_date_to": month(2024, time.December)},
+}
+
+// warm заполняет кеш всеми кешируемыми запросами.
+func (e *cacheEnv) warm(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	for _, filters := range queries {
+		if _, err := e.repo.CalculateMonthlyCost(ctx, filters); err != nil {
+			t.Fatalf("cost: %v", err)
+		}
+		if _, err := e.repo.ListForPeriod(ctx, filters); err != nil {
+			t.Fatalf("list for period: %v", err)
+		}
+	}
+	if _, err := e.repo.ChurnStats(ctx, map[string]interface{}{}); err != nil {
+		t.Fatalf("churn stats: %v", err)
+	}
+}
+
+// refetched возвращает, для каких запросов стоимость снова читалась из
+// репозитория, и проверяет, что ответ совпадает с репозиторием без кеша.
+func (e *cacheEnv) refetched(t *testing.T) map[string]bool {
+	t.Helper()
+	ctx := context.Background()
+	got := make(map[string]bool)
+	for name, filters := range queries {
+		before := e.inner.calls
+		cost, err := e.repo.CalculateMonthlyCost(ctx, filters)
+		if err != nil {
+			t.Fatalf("cost: %v", err)
+		}
+		got[name] = e.inner.calls > before
+
+		want, err := e.inner.MemoryRepository.CalculateMonthlyCost(ctx, filters)
+		if err != nil {
+			t.Fatalf("cost: %v", err)
+		}
+		if cost != want {
+			t.Fatalf("%s: cached cost %d, repository cost %d", name, cost, want)
+		}
+	}
+	return got
+}
+
+func TestRepositoryInvalidation(t *testing.T) {
+	ctx := context.Background()
+	share := 10
+
+	tests := []struct {
+		name string
+		// prepare готовит данные для изменения в обход кеша
+		prepare func(t *testing.T, e *cacheEnv) string
+		mutate  func(e *cacheEnv, arg string) error
+		// affected — пользователи, чьи записи должны сброситься; nil — сбрасывается весь кеш
+		affected []string
+	}{
+		{
+			name: "create",
+			mutate: func(e *cacheEnv, _ string) error {
+				return e.repo.Create(ctx, &subscriptions.Subscription{ServiceName: "Okko", Price: 200, UserID: owner, StartDate: month(2024, time.March)})
+			},
+			affected: []string{"owner"},
+		},
+		{
+			name: "create non-overlapping",
+			mutate: func(e *cacheEnv, _ string) error {
+				_, err := e.repo.CreateNonOverlapping(ctx, &subscriptions.Subscription{ServiceName: "Okko", Price: 200, UserID: owner, StartDate: month(2024, time.March)})
+				return err
+			},
+			affected: []string{"owner"},
+		},
+		{
+			name: "update",
+			mutate: func(e *cacheEnv, _ string) error {
+				end := month(2024, time.June)
+				return e.repo.Update(ctx, &subscriptions.Subscription{ID: e.sub.ID, ServiceName: "Netflix", Price: 500, UserID: owner, StartDate: month(2024, time.January), EndDate: &end})
+			},
+			affected: []string{"owner", "member"},
+		},
+		{
+			name:     "delete",
+			mutate:   func(e *cacheEnv, _ string) error { return e.repo.Delete(ctx, e.sub.ID) },
+			affected: []string{"owner", "member"},
+		},
+		{
+			name: "purge ended",
+			prepare: func(t *testing.T, e *cacheEnv) string {
+				end := month(2024, time.February)
+				if err := e.inner.Create(ctx, &subscriptions.Subscription{ServiceName: "Okko", Price: 200, UserID: other, StartDate: month(2024, time.January), EndDate: &end}); err != nil {
+					t.Fatalf("create: %v", err)
+				}
+				return ""
+			},
+			mutate: func(e *cacheEnv, _ string) error {
+				_, err := e.repo.PurgeEnded(ctx, month(2024, time.June))
+				return err
+			},
+		},
+		{
+			name: "cancel",
+			mutate: func(e *cacheEnv, _ string) error {
+				_, err := e.repo.Cancel(ctx, e.sub.ID, subscriptions.Cancellation{Effective: subscriptions.CancelImmediately, Reason: subscriptions.ReasonNotUsing})
+				return err
+			},
+			affected: []string{"owner", "member"},
+		},
+		{
+			name: "add price change",
+			mutate: func(e *cacheEnv, _ string) error {
+				return e.repo.AddPriceChange(ctx, &subscriptions.PriceChange{SubscriptionID: e.sub.ID, Price: 700, EffectiveFrom: month(2024, time.May)})
+			},
+			affected: []string{"owner", "member"},
+		},
+		{
+			name: "pause",
+			mutate: func(e *cacheEnv, _ string) error {
+				to := month(2024, time.April)
+				_, err := e.repo.Pause(ctx, e.sub.ID, month(2024, time.March), &to)
+				return err
+			},
+			affected: []string{"owner", "member"},
+		},
+		{
+			name: "resume",
+			prepare: func(t *testing.T, e *cacheEnv) string {
+				if _, err := e.inner.Pause(ctx, e.sub.ID, month(2024, time.March), nil); err != nil {
+					t.Fatalf("pause: %v", err)
+				}
+				return ""
+			},
+			mutate: func(e *cacheEnv, _ string) error {
+				_, err := e.repo.Resume(ctx, e.sub.ID, month(2024, time.May))
+				return err
+			},
+			affected: []string{"owner", "member"},
+		},
+		{
+			name: "add discount",
+			mutate: func(e *cacheEnv, _ string) error {
+				return e.repo.AddDiscount(ctx, e.sub.ID, &subscriptions.Discount{Kind: subscriptions.DiscountFixed, Value: 100, StartDate: month(2024, time.March), DurationPeriods: 2})
+			},
+			affected: []string{"owner", "member"},
+		},
+		{
+			name: "remove discount",
+			prepare: func(t *testing.T, e *cacheEnv) string {
+				d := &subscriptions.Discount{Kind: subscriptions.DiscountPercentage, Value: 50, StartDate: month(2024, time.March), DurationPeriods: 2}
+				if err := e.inner.AddDiscount(ctx, e.sub.ID, d); err != nil {
+					t.Fatalf("add discount: %v", err)
+				}
+				return d.ID
+			},
+			mutate:   func(e *cacheEnv, discountID string) error { return e.repo.RemoveDiscount(ctx, e.sub.ID, discountID) },
+			affected: []string{"owner", "member"},
+		},
+		{
+			name: "add member",
+			mutate: func(e *cacheEnv, _ string) error {
+				return e.repo.AddMember(ctx, e.sub.ID, &subscriptions.Member{UserID: other, SharePercent: &share})
+			},
+			affected: []string{"owner", "member", "other"},
+		},
+		{
+			name:     "remove member",
+			mutate:   func(e *cacheEnv, _ string) error { return e.repo.RemoveMember(ctx, e.sub.ID, member) },
+			affected: []string{"owner", "member"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newCacheEnv(t)
+			var arg string
+			if tt.prepare != nil {
+				arg = tt.prepare(t, e)
+			}
+
+			e.warm(t)
+			// Без записей повторные запросы обслуживаются кешем
+			for name, refetched := range e.refetched(t) {
+				if refetched {
+					t.Fatalf("%s was not cached", name)
+				}
+			}
+
+			epoch, all := e.generation(t, epochKey), e.generation(t, allKey)
+			users := map[string]string{}
+			for name, id := range userIDs {
+				users[name] = e.generation(t, userKeyPrefix+id)
+			}
+
+			if err := tt.mutate(e, arg); err != nil {
+				t.Fatalf("mutate: %v", err)
+			}
+
+			if tt.affected == nil {
+				if e.generation(t, epochKey) == epoch {
+					t.Fatal("epoch generation was not bumped")
+				}
+			} else if e.generation(t, allKey) == all {
+				t.Fatal("generation of unfiltered queries was not bumped")
+			}
+
+			want := map[string]bool{"all": true}
+			for _, name := range tt.affected {
+				want[name] = true
+				if e.generation(t, userKeyPrefix+userIDs[name]) == users[name] {
+					t.Fatalf("generation of %s was not bumped", name)
+				}
+			}
+			for name, refetched := range e.refetched(t) {
+				if tt.affected == nil {
+					want[name] = true
+				}
+				if refetched != want[name] {
+					t.Errorf("%s: refetched %v, want %v", name, refetched, want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoryFailedWriteKeepsCache(t *testing.T) {
+	ctx := context.Background()
+	e := newCacheEnv(t)
+	e.warm(t)
+	all := e.generation(t, allKey)
+
+	if err := e.repo.Delete(ctx, "00000000-0000-0000-0000-000000000000"); err == nil {
+		t.Fatal("deleting a missing subscription succeeded")
+	}
+	_, err := e.repo.CreateNonOverlapping(ctx, &subscriptions.Subscription{ServiceName: "Netflix", Price: 500, UserID: owner, StartDate: month(2024, time.June)})
+	if err == nil {
+		t.Fatal("overlapping subscription was created")
+	}
+
+	if e.generation(t, allKey) != all {
+		t.Fatal("failed writes invalidated the cache")
+	}
+	for name, refetched := range e.refetched(t) {
+		if refetched {
+			t.Errorf("%s was refetched after failed writes", name)
+		}
+	}
+}
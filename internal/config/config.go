@@ -0,0 +1,301 @@
+// Package config loads the service configuration from defaults, an optional
+// YAML file, an optional .env file and environment variables, in increasing
+// order of precedence.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"SubscriptionService/pkg/db"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete service configuration. The env tag names the
+// environment variable that overrides a field; secret fields are redacted
+// by Redacted.
+type Config struct {
+	HTTP      HTTPConfig      `yaml:"http"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Jobs      JobsConfig      `yaml:"jobs"`
+}
+
+type HTTPConfig struct {
+	Port            int           `yaml:"port" env:"PORT"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// ShutdownDelay — сколько сервер продолжает принимать запросы после перевода
+	// /readyz в ошибку; входит в ShutdownTimeout
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+	// HealthCheckTimeout ограничивает каждую проверку зависимостей в /readyz и /health
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	// TrustedProxies — адреса и подсети прокси, чьим X-Forwarded-For можно верить при
+	// определении IP клиента; по умолчанию заголовок игнорируется
+	TrustedProxies []string `yaml:"trusted_proxies" env:"HTTP_TRUSTED_PROXIES"`
+	// AdminAddr — адрес отдельного listener для /admin/*: эндпоинты без аутентификации,
+	// поэтому по умолчанию доступны только с localhost; пустое значение отключает их
+	AdminAddr string `yaml:"admin_addr" env:"HTTP_ADMIN_ADDR"`
+	// StreamWriteTimeout заменяет WriteTimeout для потоковых ответов (NDJSON, SSE):
+	// ограничивает запись каждой порции, а не всего ответа
+	StreamWriteTimeout time.Duration `yaml:"stream_write_timeout" env:"HTTP_STREAM_WRITE_TIMEOUT"`
+	// SSEHeartbeat — интервал комментариев в потоке событий, чтобы прокси не закрывали
+	// простаивающее соединение
+	SSEHeartbeat time.Duration `yaml:"sse_heartbeat" env:"HTTP_SSE_HEARTBEAT"`
+	// SSEBuffer — сколько событий может ждать отправки клиенту; отставший сильнее
+	// клиент отключается
+	SSEBuffer int `yaml:"sse_buffer" env:"HTTP_SSE_BUFFER"`
+}
+
+type GRPCConfig struct {
+	Port int `yaml:"port" env:"GRPC_PORT"`
+}
+
+type DatabaseConfig struct {
+	URL             string        `yaml:"url" env:"DB_URL" secret:"true"`
+	MaxConns        int32         `yaml:"max_conns" env:"DB_MAX_CONNS"`
+	MinConns        int32         `yaml:"min_conns" env:"DB_MIN_CONNS"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	AutoMigrate     bool          `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+	// ReplicaURLs — строки подключения к репликам для чтения; пулы создаются
+	// с теми же размерами, что и пул primary
+	ReplicaURLs []string `yaml:"replica_urls" env:"DB_REPLICA_URLS" secret:"true"`
+	// ReplicaMaxLag — отставание, при котором реплика исключается из чтения; 0 отключает проверку
+	ReplicaMaxLag        time.Duration `yaml:"replica_max_lag" env:"DB_REPLICA_MAX_LAG"`
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env:"DB_REPLICA_CHECK_INTERVAL"`
+	// RetryAttempts — число попыток запроса при временной ошибке, включая первую; 1 отключает повторы
+	RetryAttempts  int           `yaml:"retry_attempts" env:"DB_RETRY_ATTEMPTS"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay" env:"DB_RETRY_BASE_DELAY"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay" env:"DB_RETRY_MAX_DELAY"`
+	// После BreakerThreshold подряд ошибок недоступности БД запросы BreakerCooldown
+	// завершаются сразу с 503
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"DB_BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"DB_BREAKER_COOLDOWN"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"`
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+	// Access-логи сэмплируются: в секунду пишутся первые AccessSampleInitial
+	// одинаковых записей, затем каждая AccessSampleThereafter-я. 0 отключает сэмплирование.
+	AccessSampleInitial    int `yaml:"access_sample_initial" env:"LOG_ACCESS_SAMPLE_INITIAL"`
+	AccessSampleThereafter int `yaml:"access_sample_thereafter" env:"LOG_ACCESS_SAMPLE_THEREAFTER"`
+}
+
+type TracingConfig struct {
+	// Exporter — none, stdout или otlp (gRPC, обычно локальный OpenTelemetry Collector)
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER"`
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// Backend — memory (лимиты на каждую реплику) или postgres (общие для всех реплик)
+	Backend string `yaml:"backend" env:"RATE_LIMIT_BACKEND"`
+	// KeyBy — ip (по умолчанию), api_key (X-API-Key) или user (X-User-ID); без заголовка
+	// используется IP. Заголовки не проверяются, поэтому api_key и user безопасны только
+	// за аутентифицирующим шлюзом
+	KeyBy string  `yaml:"key_by" env:"RATE_LIMIT_KEY_BY"`
+	Rate  float64 `yaml:"rate" env:"RATE_LIMIT_RATE"`
+	Burst int     `yaml:"burst" env:"RATE_LIMIT_BURST"`
+	// Exempt — префиксы путей без ограничений (проверки состояния, метрики)
+	Exempt []string `yaml:"exempt" env:"RATE_LIMIT_EXEMPT"`
+	// Groups переопределяют лимит для маршрутов с заданным префиксом; задаются только в YAML
+	Groups []RateLimitGroup `yaml:"groups"`
+}
+
+// RateLimitGroup is a separate limit for routes whose template starts with
+// Prefix. Empty KeyBy inherits the top-level setting.
+type RateLimitGroup struct {
+	Name   string  `yaml:"name"`
+	Prefix string  `yaml:"prefix"`
+	Rate   float64 `yaml:"rate"`
+	Burst  int     `yaml:"burst"`
+	KeyBy  string  `yaml:"key_by"`
+}
+
+// CacheConfig controls the read-through cache of cost and report queries.
+type CacheConfig struct {
+	Enabled bool          `yaml:"enabled" env:"CACHE_ENABLED"`
+	Size    int           `yaml:"size" env:"CACHE_SIZE"`
+	TTL     time.Duration `yaml:"ttl" env:"CACHE_TTL"`
+}
+
+type JobsConfig struct {
+	BudgetInterval  time.Duration `yaml:"budget_interval" env:"BUDGET_CHECK_INTERVAL"`
+	AnomalyInterval time.Duration `yaml:"anomaly_interval" env:"ANOMALY_SCAN_INTERVAL"`
+	MetricsInterval time.Duration `yaml:"metrics_interval" env:"METRICS_REFRESH_INTERVAL"`
+	RollupInterval  time.Duration `yaml:"rollup_interval" env:"ROLLUP_INTERVAL"`
+	// RollupHorizon is how many months ahead monthly_spend is materialized.
+	RollupHorizon int `yaml:"rollup_horizon_months" env:"ROLLUP_HORIZON_MONTHS"`
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Port:               8080,
+			ReadTimeout:        5 * time.Second,
+			WriteTimeout:       10 * time.Second,
+			IdleTimeout:        60 * time.Second,
+			ShutdownTimeout:    5 * time.Second,
+			HealthCheckTimeout: 2 * time.Second,
+			AdminAddr:          "127.0.0.1:8081",
+			StreamWriteTimeout: 10 * time.Second,
+			SSEHeartbeat:       15 * time.Second,
+			SSEBuffer:          64,
+		},
+		GRPC: GRPCConfig{
+			Port: 9090,
+		},
+		Database: DatabaseConfig{
+			MaxConns:             10,
+			MinConns:             2,
+			MaxConnLifetime:      time.Hour,
+			MaxConnIdleTime:      30 * time.Minute,
+			ConnectTimeout:       10 * time.Second,
+			ReplicaMaxLag:        10 * time.Second,
+			ReplicaCheckInterval: 5 * time.Second,
+			RetryAttempts:        3,
+			RetryBaseDelay:       50 * time.Millisecond,
+			RetryMaxDelay:        time.Second,
+			BreakerThreshold:     5,
+			BreakerCooldown:      10 * time.Second,
+			AutoMigrate:          true,
+		},
+		Log: LogConfig{
+			Level:                  "info",
+			Format:                 "json",
+			AccessSampleInitial:    100,
+			AccessSampleThereafter: 100,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "localhost:4317",
+			Insecure:    true,
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Backend: "memory",
+			KeyBy:   "ip",
+			Rate:    10,
+			Burst:   20,
+			Exempt:  []string{"/healthz", "/readyz", "/health", "/metrics", "/swagger"},
+		},
+		Cache: CacheConfig{
+			Enabled: true,
+			Size:    10000,
+			TTL:     30 * time.Second,
+		},
+		Jobs: JobsConfig{
+			BudgetInterval:  time.Hour,
+			AnomalyInterval: 24 * time.Hour,
+			MetricsInterval: time.Minute,
+			RollupInterval:  time.Hour,
+			RollupHorizon:   24,
+		},
+	}
+}
+
+// Load builds the configuration. path is an optional YAML file; when empty,
+// CONFIG_FILE is used if set. Variables from .env are loaded if the file
+// exists but never override the real environment. All invalid fields are
+// reported together in a *ValidationError.
+func Load(path string) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env: %w", err)
+	}
+
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadYAML(path); err != nil {
+			return nil, err
+		}
+	}
+
+	problems := applyEnv(cfg, os.LookupEnv)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadYAML(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Неизвестные ключи — почти всегда опечатка, поэтому не игнорируем их
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// HTTPAddr is the listen address of the REST API.
+func (c *Config) HTTPAddr() string {
+	return fmt.Sprintf(":%d", c.HTTP.Port)
+}
+
+// GRPCAddr is the listen address of the gRPC API.
+func (c *Config) GRPCAddr() string {
+	return fmt.Sprintf(":%d", c.GRPC.Port)
+}
+
+// Pool returns the connection pool settings for db.NewPGXPool.
+func (d DatabaseConfig) Pool() db.PoolConfig {
+	return db.PoolConfig{
+		URL:             d.URL,
+		MaxConns:        d.MaxConns,
+		MinConns:        d.MinConns,
+		MaxConnLifetime: d.MaxConnLifetime,
+		MaxConnIdleTime: d.MaxConnIdleTime,
+	}
+}
+
+// Retry returns the retry policy for transient database errors.
+func (d DatabaseConfig) Retry() db.RetryPolicy {
+	return db.RetryPolicy{Attempts: d.RetryAttempts, BaseDelay: d.RetryBaseDelay, MaxDelay: d.RetryMaxDelay}
+}
+
+// ReplicaPools returns the pool settings of each read replica.
+func (d DatabaseConfig) ReplicaPools() []db.PoolConfig {
+	pools := make([]db.PoolConfig, len(d.ReplicaURLs))
+	for i, url := range d.ReplicaURLs {
+		pools[i] = d.Pool()
+		pools[i].URL = url
+	}
+	return pools
+}
+
+// YAML renders the configuration with secrets redacted, for --print-config.
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c.Redacted())
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field — лист структуры Config с путём в YAML и именем переменной окружения.
+type field struct {
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields обходит вложенные структуры Config и возвращает все листовые поля.
+func fields(v reflect.Value, prefix string) []field {
+	var out []field
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			out = append(out, fields(fv, path)...)
+			continue
+		}
+		out = append(out, field{
+			path:   path,
+			env:    sf.Tag.Get("env"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  fv,
+		})
+	}
+	return out
+}
+
+// applyEnv переопределяет поля значениями переменных окружения и возвращает
+// ошибки разбора по всем некорректным переменным.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) []Problem {
+	var problems []Problem
+	for _, f := range fields(reflect.ValueOf(cfg).Elem(), "") {
+		if f.env == "" {
+			continue
+		}
+		raw, ok := lookup(f.env)
+		if !ok {
+			continue
+		}
+		if err := setValue(f.value, strings.TrimSpace(raw)); err != nil {
+			problems = append(problems, Problem{Field: f.path, Env: f.env, Message: err.Error()})
+		}
+	}
+	return problems
+}
+
+func setValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, use values like 30s or 5m", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case v.CanInt():
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case v.CanFloat():
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration with secret fields masked:
+// the password of connection URLs is replaced, other secrets are hidden entirely.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	for _, f := range fields(reflect.ValueOf(&redacted).Elem(), "") {
+		if !f.secret {
+			continue
+		}
+		switch {
+		case f.value.Kind() == reflect.String && f.value.String() != "":
+			f.value.SetString(redactSecret(f.value.String()))
+		case f.value.Kind() == reflect.Slice && f.value.Type().Elem().Kind() == reflect.String:
+			// Срез общий с исходной конфигурацией, поэтому маскируется копия
+			values := make([]string, f.value.Len())
+			for i := range values {
+				values[i] = redactSecret(f.value.Index(i).String())
+			}
+			f.value.Set(reflect.ValueOf(values))
+		}
+	}
+	return &redacted
+}
+
+func redactSecret(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "REDACTED"
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	// Пароль может быть передан и параметром запроса
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", "REDACTED")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap/zapcore"
+)
+
+// Problem describes one invalid configuration field.
+type Problem struct {
+	Field   string
+	Env     string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Env != "" {
+		return fmt.Sprintf("%s (%s): %s", p.Field, p.Env, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Field, p.Message)
+}
+
+// ValidationError lists every invalid field, so all of them can be fixed at once.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, p := range e.Problems {
+		b.WriteString("\n  ")
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+// validator накапливает ошибки; имена переменных окружения берутся из тегов полей.
+type validator struct {
+	envs     map[string]string
+	problems []Problem
+}
+
+func (v *validator) check(ok bool, path, format string, args ...any) {
+	if !ok {
+		v.problems = append(v.problems, Problem{Field: path, Env: v.envs[path], Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func (v *validator) port(path string, port int) {
+	v.check(port >= 1 && port <= 65535, path, "must be between 1 and 65535, got %d", port)
+}
+
+func (v *validator) positive(path string, d time.Duration) {
+	v.check(d > 0, path, "must be positive, got %s", d)
+}
+
+func (v *validator) rateLimit(path string, rate float64, burst int, keyBy string) {
+	v.check(rate > 0, path+".rate", "must be positive, got %g", rate)
+	v.check(burst >= 1, path+".burst", "must be at least 1, got %d", burst)
+	v.check(keyBy == "ip" || keyBy == "api_key" || keyBy == "user",
+		path+".key_by", "must be ip, api_key or user, got %q", keyBy)
+}
+
+func (c *Config) validate() []Problem {
+	v := &validator{envs: make(map[string]string)}
+	for _, f := range fields(reflect.ValueOf(c).Elem(), "") {
+		v.envs[f.path] = f.env
+	}
+
+	v.port("http.port", c.HTTP.Port)
+	v.port("grpc.port", c.GRPC.Port)
+	v.check(c.HTTP.Port != c.GRPC.Port, "grpc.port", "must differ from http.port")
+	v.positive("http.read_timeout", c.HTTP.ReadTimeout)
+	v.positive("http.write_timeout", c.HTTP.WriteTimeout)
+	v.positive("http.idle_timeout", c.HTTP.IdleTimeout)
+	v.positive("http.shutdown_timeout", c.HTTP.ShutdownTimeout)
+	v.check(c.HTTP.ShutdownDelay >= 0 && c.HTTP.ShutdownDelay < c.HTTP.ShutdownTimeout,
+		"http.shutdown_delay", "must be between 0 and shutdown_timeout, got %s", c.HTTP.ShutdownDelay)
+	v.positive("http.health_check_timeout", c.HTTP.HealthCheckTimeout)
+	if c.HTTP.AdminAddr != "" {
+		_, _, err := net.SplitHostPort(c.HTTP.AdminAddr)
+		v.check(err == nil, "http.admin_addr", "must be host:port, got %q", c.HTTP.AdminAddr)
+	}
+	v.positive("http.stream_write_timeout", c.HTTP.StreamWriteTimeout)
+	v.positive("http.sse_heartbeat", c.HTTP.SSEHeartbeat)
+	v.check(c.HTTP.SSEBuffer >= 1, "http.sse_buffer", "must be at least 1, got %d", c.HTTP.SSEBuffer)
+	for _, proxy := range c.HTTP.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		v.check(cidrErr == nil || net.ParseIP(proxy) != nil,
+			"http.trusted_proxies", "must contain IP addresses or CIDR ranges, got %q", proxy)
+	}
+
+	if c.Database.URL == "" {
+		v.check(false, "database.url", "is required")
+	} else {
+		// Текст ошибки разбора может содержать строку подключения, поэтому не выводим его
+		_, err := pgxpool.ParseConfig(c.Database.URL)
+		v.check(err == nil, "database.url", "is not a valid PostgreSQL connection string")
+	}
+	v.check(c.Database.MaxConns >= 1, "database.max_conns", "must be at least 1, got %d", c.Database.MaxConns)
+	v.check(c.Database.MinConns >= 0 && c.Database.MinConns <= c.Database.MaxConns,
+		"database.min_conns", "must be between 0 and max_conns, got %d", c.Database.MinConns)
+	v.positive("database.max_conn_lifetime", c.Database.MaxConnLifetime)
+	v.positive("database.max_conn_idle_time", c.Database.MaxConnIdleTime)
+	v.positive("database.connect_timeout", c.Database.ConnectTimeout)
+	for i, url := range c.Database.ReplicaURLs {
+		_, err := pgxpool.ParseConfig(url)
+		v.check(err == nil, fmt.Sprintf("database.replica_urls[%d]", i), "is not a valid PostgreSQL connection string")
+	}
+	v.check(c.Database.ReplicaMaxLag >= 0, "database.replica_max_lag", "must not be negative, got %s", c.Database.ReplicaMaxLag)
+	v.positive("database.replica_check_interval", c.Database.ReplicaCheckInterval)
+	v.check(c.Database.RetryAttempts >= 1, "database.retry_attempts", "must be at least 1, got %d", c.Database.RetryAttempts)
+	v.positive("database.retry_base_delay", c.Database.RetryBaseDelay)
+	v.check(c.Database.RetryMaxDelay >= c.Database.RetryBaseDelay,
+		"database.retry_max_delay", "must not be less than retry_base_delay, got %s", c.Database.RetryMaxDelay)
+	v.check(c.Database.BreakerThreshold >= 1, "database.breaker_threshold", "must be at least 1, got %d", c.Database.BreakerThreshold)
+	v.positive("database.breaker_cooldown", c.Database.BreakerCooldown)
+
+	_, err := zapcore.ParseLevel(c.Log.Level)
+	v.check(err == nil, "log.level", "must be one of debug, info, warn, error, got %q", c.Log.Level)
+	v.check(c.Log.Format == "json" || c.Log.Format == "console", "log.format", "must be json or console, got %q", c.Log.Format)
+	v.check(c.Log.AccessSampleInitial >= 0, "log.access_sample_initial", "must not be negative, got %d", c.Log.AccessSampleInitial)
+	v.check(c.Log.AccessSampleThereafter >= 0, "log.access_sample_thereafter", "must not be negative, got %d", c.Log.AccessSampleThereafter)
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		v.check(c.Tracing.Endpoint != "", "tracing.endpoint", "is required for the otlp exporter")
+	default:
+		v.check(false, "tracing.exporter", "must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1,
+		"tracing.sample_ratio", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+
+	v.check(c.RateLimit.Backend == "memory" || c.RateLimit.Backend == "postgres",
+		"rate_limit.backend", "must be memory or postgres, got %q", c.RateLimit.Backend)
+	v.rateLimit("rate_limit", c.RateLimit.Rate, c.RateLimit.Burst, c.RateLimit.KeyBy)
+	names := make(map[string]bool)
+	for i, g := range c.RateLimit.Groups {
+		path := fmt.Sprintf("rate_limit.groups[%d]", i)
+		v.check(g.Name != "" && !names[g.Name], path+".name", "must be set and unique, got %q", g.Name)
+		v.check(strings.HasPrefix(g.Prefix, "/"), path+".prefix", "must start with /, got %q", g.Prefix)
+		keyBy := g.KeyBy
+		if keyBy == "" {
+			keyBy = c.RateLimit.KeyBy
+		}
+		v.rateLimit(path, g.Rate, g.Burst, keyBy)
+		names[g.Name] = true
+	}
+
+	v.check(c.Cache.Size >= 1, "cache.size", "must be at least 1, got %d", c.Cache.Size)
+	v.positive("cache.ttl", c.Cache.TTL)
+
+	v.positive("jobs.budget_interval", c.Jobs.BudgetInterval)
+	v.positive("jobs.anomaly_interval", c.Jobs.AnomalyInterval)
+	v.positive("jobs.metrics_interval", c.Jobs.MetricsInterval)
+	v.positive("jobs.rollup_interval", c.Jobs.RollupInterval)
+	v.check(c.Jobs.RollupHorizon >= 2, "jobs.rollup_horizon_months", "must be at least 2, got %d", c.Jobs.RollupHorizon)
+
+	return v.problems
+}
@@ -0,0 +1,122 @@
+package graphqlapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"SubscriptionService/internal/subscriptions"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"go.uber.org/zap"
+)
+
+// maxRequestBytes ограничивает размер тела запроса до разбора документа.
+const maxRequestBytes = 1 << 20
+
+// Request is a GraphQL request as sent by POST body or GET query parameters.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler serves /graphql on top of the same repository as the REST handlers.
+type Handler struct {
+	logger *zap.Logger
+	repo   subscriptions.ISubscriptionRepository
+	now    subscriptions.Clock
+	schema graphql.Schema
+}
+
+func NewHandler(logger *zap.Logger, repo subscriptions.ISubscriptionRepository, clock subscriptions.Clock) (*Handler, error) {
+	schema, err := newSchema(&resolver{
+		logger: logger,
+		repo:   repo,
+		now:    clock,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		logger: logger,
+		repo:   repo,
+		now:    clock,
+		schema: schema,
+	}, nil
+}
+
+func (h *Handler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/graphql", h.Serve)
+	router.POST("/graphql", h.Serve)
+}
+
+// Serve выполняет запрос. Ошибки разбора, валидации и лимитов возвращаются с
+// кодом 400 до выполнения; ошибки резолверов — в поле errors ответа 200.
+func (h *Handler) Serve(c *gin.Context) {
+	req, ok := h.decodeRequest(c)
+	if !ok {
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	if validation := graphql.ValidateDocument(&h.schema, doc, nil); !validation.IsValid {
+		c.JSON(http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	if err := checkQueryLimits(h.schema, doc, req.OperationName, req.Variables); err != nil {
+		c.JSON(http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	// Загрузчики создаются на каждый запрос, чтобы батчи и кэш не пересекались
+	ctx := withLoaders(c.Request.Context(), newLoaders(h.repo, h.now))
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) decodeRequest(c *gin.Context) (*Request, bool) {
+	var req Request
+
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if vars := c.Query("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variables, expected a JSON object"})
+				return nil, false
+			}
+		}
+	} else {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBytes)
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return nil, false
+		}
+	}
+
+	if req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return nil, false
+	}
+	return &req, true
+}
@@ -0,0 +1,111 @@
+package graphqlapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type response struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// serve выполняет запрос через Handler, как это делает роутер приложения.
+func serve(t *testing.T, repo subscriptions.ISubscriptionRepository, req Request) (int, response) {
+	t.Helper()
+	now := time.Date(2025, time.June, 17, 12, 0, 0, 0, time.UTC)
+	h, err := NewHandler(zap.NewNop(), repo, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h.RegisterRoutes(r)
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+
+	var resp response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+// nestedQuery строит запрос user → subscriptions → owner → ... из fields
+// вложенных полей; глубина самой внутренней выборки равна fields+1.
+func nestedQuery(fields int) string {
+	chain := []string{`user(id: "60601fee-2bf1-4721-ae6f-7636e79a0cba")`}
+	for i := 1; i < fields; i++ {
+		if i%2 == 1 {
+			chain = append(chain, "subscriptions")
+		} else {
+			chain = append(chain, "owner")
+		}
+	}
+	return "{ " + strings.Join(chain, " { ") + " { id" + strings.Repeat(" }", len(chain)) + " }"
+}
+
+func TestQueryLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		wantErr   string
+	}{
+		{name: "depth at the limit", query: nestedQuery(MaxQueryDepth - 1)},
+		{name: "depth over the limit", query: nestedQuery(MaxQueryDepth), wantErr: "query depth exceeds the limit of 8"},
+		{
+			name: "depth through a fragment",
+			query: `{ user(id: "60601fee-2bf1-4721-ae6f-7636e79a0cba") { subscriptions { owner { subscriptions { ...deep } } } } }
+				fragment deep on Subscription { owner { subscriptions { owner { subscriptions { id } } } } }`,
+			wantErr: "query depth exceeds the limit of 8",
+		},
+		// 1 + 200 * (1 + 10 * 2) = 4201
+		{name: "complexity under the limit", query: `{ subscriptions(limit: 200) { id members { userId sharePercent } } }`},
+		// 1 + 500 * (1 + 10 * 2) = 10501
+		{name: "complexity over the limit", query: `{ subscriptions(limit: 500) { id members { userId sharePercent } } }`, wantErr: "query complexity exceeds the limit of 5000"},
+		{
+			name:      "complexity with a limit variable",
+			query:     `query($n: Int) { subscriptions(limit: $n) { id members { userId sharePercent } } }`,
+			variables: map[string]interface{}{"n": 500},
+			wantErr:   "query complexity exceeds the limit of 5000",
+		},
+		{name: "limit above the page size is capped", query: `{ subscriptions(limit: 100000) { id } }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := serve(t, subscriptions.NewMemoryRepository(), Request{Query: tt.query, Variables: tt.variables})
+			if tt.wantErr == "" {
+				if code != http.StatusOK || len(resp.Errors) != 0 {
+					t.Fatalf("status %d, errors %+v", code, resp.Errors)
+				}
+				return
+			}
+			if code != http.StatusBadRequest || len(resp.Errors) != 1 || resp.Errors[0].Message != tt.wantErr {
+				t.Fatalf("status %d, errors %+v, want %q", code, resp.Errors, tt.wantErr)
+			}
+			if resp.Data != nil {
+				t.Fatalf("rejected query was executed: %v", resp.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// MaxQueryDepth ограничивает вложенность полей: user → subscriptions → members → user → ...
+	MaxQueryDepth = 8
+	// MaxQueryComplexity ограничивает оценку числа разрешаемых полей с учётом размеров списков.
+	MaxQueryComplexity = 5000
+	// defaultListSize — предполагаемый размер списка, если его нельзя вывести из аргументов.
+	defaultListSize = 10
+)
+
+// queryLimits оценивает глубину и сложность операции до её выполнения.
+// Каждое поле стоит 1, а стоимость выборки внутри списка умножается на
+// ожидаемое число элементов: limit, длину ids или defaultListSize.
+type queryLimits struct {
+	schema        graphql.Schema
+	fragments     map[string]*ast.FragmentDefinition
+	variables     map[string]interface{}
+	maxDepth      int
+	maxComplexity int
+}
+
+// checkQueryLimits возвращает ошибку, если операция слишком глубокая или сложная.
+// Документ должен быть уже провалидирован, иначе циклы фрагментов не исключены.
+func checkQueryLimits(schema graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) error {
+	l := &queryLimits{
+		schema:        schema,
+		fragments:     make(map[string]*ast.FragmentDefinition),
+		variables:     variables,
+		maxDepth:      MaxQueryDepth,
+		maxComplexity: MaxQueryComplexity,
+	}
+
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			l.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	// Отсутствующую или неподдерживаемую операцию отклонит graphql.Execute
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return nil
+	}
+
+	_, err := l.selectionSet(operation.SelectionSet, schema.QueryType(), 1)
+	return err
+}
+
+func (l *queryLimits) selectionSet(set *ast.SelectionSet, parent *graphql.Object, depth int) (int, error) {
+	if set == nil {
+		return 0, nil
+	}
+	if depth > l.maxDepth {
+		return 0, fmt.Errorf("query depth exceeds the limit of %d", l.maxDepth)
+	}
+
+	total := 0
+	for _, selection := range set.Selections {
+		var cost int
+		var err error
+
+		switch sel := selection.(type) {
+		case *ast.Field:
+			cost, err = l.field(sel, parent, depth)
+		case *ast.InlineFragment:
+			cost, err = l.selectionSet(sel.SelectionSet, l.fragmentType(sel.TypeCondition, parent), depth)
+		case *ast.FragmentSpread:
+			if frag, ok := l.fragments[sel.Name.Value]; ok {
+				cost, err = l.selectionSet(frag.SelectionSet, l.fragmentType(frag.TypeCondition, parent), depth)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		total += cost
+		if total > l.maxComplexity {
+			return 0, fmt.Errorf("query complexity exceeds the limit of %d", l.maxComplexity)
+		}
+	}
+
+	return total, nil
+}
+
+func (l *queryLimits) field(f *ast.Field, parent *graphql.Object, depth int) (int, error) {
+	// Интроспекция ограничена самой схемой и в оценке не участвует
+	if strings.HasPrefix(f.Name.Value, "__") {
+		return 0, nil
+	}
+	def, ok := parent.Fields()[f.Name.Value]
+	if !ok || f.SelectionSet == nil {
+		return 1, nil
+	}
+
+	fieldType, isList := unwrapType(def.Type)
+	object, ok := fieldType.(*graphql.Object)
+	if !ok {
+		return 1, nil
+	}
+
+	nested, err := l.selectionSet(f.SelectionSet, object, depth+1)
+	if err != nil {
+		return 0, err
+	}
+	if isList {
+		nested *= l.listSize(f)
+	}
+
+	return 1 + nested, nil
+}
+
+// listSize оценивает число элементов, которое вернёт списочное поле.
+func (l *queryLimits) listSize(f *ast.Field) int {
+	for _, arg := range f.Arguments {
+		switch arg.Name.Value {
+		case "limit":
+			if n, ok := l.intValue(arg.Value); ok {
+				return max(min(n, maxPageSize), 1)
+			}
+		case "ids":
+			if n, ok := l.listLen(arg.Value); ok {
+				return max(n, 1)
+			}
+		}
+	}
+	return defaultListSize
+}
+
+func (l *queryLimits) intValue(v ast.Value) (int, bool) {
+	switch v := v.(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(v.Value)
+		return n, err == nil
+	case *ast.Variable:
+		// Переменные из JSON приходят как float64
+		switch n := l.variables[v.Name.Value].(type) {
+		case float64:
+			return int(n), true
+		case int:
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func (l *queryLimits) listLen(v ast.Value) (int, bool) {
+	switch v := v.(type) {
+	case *ast.ListValue:
+		return len(v.Values), true
+	case *ast.Variable:
+		if list, ok := l.variables[v.Name.Value].([]interface{}); ok {
+			return len(list), true
+		}
+	}
+	return 0, false
+}
+
+func (l *queryLimits) fragmentType(cond *ast.Named, parent *graphql.Object) *graphql.Object {
+	if cond == nil {
+		return parent
+	}
+	if object, ok := l.schema.Type(cond.Name.Value).(*graphql.Object); ok {
+		return object
+	}
+	return parent
+}
+
+// unwrapType снимает обёртки NonNull/List и сообщает, был ли среди них список.
+func unwrapType(t graphql.Type) (graphql.Type, bool) {
+	isList := false
+	for {
+		switch wrapped := t.(type) {
+		case *graphql.NonNull:
+			t = wrapped.OfType
+		case *graphql.List:
+			isList = true
+			t = wrapped.OfType
+		default:
+			return t, isList
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package graphqlapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+)
+
+// batchLoader накапливает ключи, запрошенные резолверами одного уровня запроса,
+// и загружает их одним вызовом fetch при разрешении первого thunk.
+type batchLoader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	queued  map[K]bool
+	results map[K]V
+	errs    map[K]error
+}
+
+func newBatchLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *batchLoader[K, V] {
+	return &batchLoader[K, V]{
+		fetch:   fetch,
+		queued:  make(map[K]bool),
+		results: make(map[K]V),
+		errs:    make(map[K]error),
+	}
+}
+
+// Load ставит ключ в очередь и возвращает thunk, который graphql-go вызовет
+// после того, как все резолверы текущего уровня зарегистрируют свои ключи.
+func (l *batchLoader[K, V]) Load(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+
+			values, err := l.fetch(ctx, keys)
+			for _, k := range keys {
+				if err != nil {
+					l.errs[k] = err
+					continue
+				}
+				l.results[k] = values[k]
+			}
+		}
+
+		return l.results[key], l.errs[key]
+	}
+}
+
+// costKey определяет сумму расходов пользователя за период, при необходимости по одному сервису.
+type costKey struct {
+	UserID      string
+	ServiceName string
+	From        time.Time
+	To          time.Time
+}
+
+// loaders живут в течение одного GraphQL-запроса, чтобы не смешивать кэш разных клиентов.
+type loaders struct {
+	subscriptionsByUser *batchLoader[string, []*subscriptions.Subscription]
+	userCost            *batchLoader[costKey, int]
+}
+
+func newLoaders(repo subscriptions.ISubscriptionRepository, now subscriptions.Clock) *loaders {
+	return &loaders{
+		subscriptionsByUser: newBatchLoader(func(ctx context.Context, userIDs []string) (map[string][]*subscriptions.Subscription, error) {
+			subs, err := repo.List(ctx, map[string]interface{}{"user_ids": userIDs})
+			if err != nil {
+				return nil, err
+			}
+
+			byUser := make(map[string][]*subscriptions.Subscription, len(userIDs))
+			for _, sub := range subs {
+				byUser[sub.UserID] = append(byUser[sub.UserID], sub)
+			}
+			return byUser, nil
+		}),
+		userCost: newBatchLoader(func(ctx context.Context, keys []costKey) (map[costKey]int, error) {
+			return loadUserCosts(ctx, repo, keys, now())
+		}),
+	}
+}
+
+// loadUserCosts группирует ключи по периоду и сервису: на каждую группу
+// выполняется один запрос за подписками всех её пользователей (включая те,
+// где пользователь участник), после чего доля каждого считается в памяти.
+func loadUserCosts(ctx context.Context, repo subscriptions.ISubscriptionRepository, keys []costKey, now time.Time) (map[costKey]int, error) {
+	type group struct {
+		serviceName string
+		from, to    time.Time
+	}
+
+	users := make(map[group][]string)
+	for _, k := range keys {
+		g := group{serviceName: k.ServiceName, from: k.From, to: k.To}
+		users[g] = append(users[g], k.UserID)
+	}
+
+	costs := make(map[costKey]int, len(keys))
+	for g, userIDs := range users {
+		filters := periodFilters(g.serviceName, g.from, g.to)
+		filters["user_ids"] = userIDs
+
+		subs, err := repo.ListForPeriod(ctx, filters)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, userID := range userIDs {
+			userFilters := periodFilters(g.serviceName, g.from, g.to)
+			userFilters["user_id"] = userID
+			costs[costKey{UserID: userID, ServiceName: g.serviceName, From: g.from, To: g.to}] =
+				subscriptions.PeriodCost(subs, userFilters, now)
+		}
+	}
+
+	return costs, nil
+}
+
+// periodFilters строит фильтры репозитория для расчёта стоимости; нулевые даты не ограничивают период.
+func periodFilters(serviceName string, from, to time.Time) map[string]interface{} {
+	filters := make(map[string]interface{})
+	if serviceName != "" {
+		filters["service_name"] = serviceName
+	}
+	if !from.IsZero() {
+		filters["start_date_from"] = from
+	}
+	if !to.IsZero() {
+		filters["start_date_to"] = to
+	}
+	return filters
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,99 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+)
+
+// countingRepository считает вызовы List, через который загрузчик получает подписки пользователей.
+type countingRepository struct {
+	*subscriptions.MemoryRepository
+	lists atomic.Int32
+}
+
+func (r *countingRepository) List(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.Subscription, error) {
+	r.lists.Add(1)
+	return r.MemoryRepository.List(ctx, filters)
+}
+
+func TestSubscriptionsByUserBatching(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingRepository{MemoryRepository: subscriptions.NewMemoryRepository()}
+
+	userIDs := make([]string, 5)
+	for i := range userIDs {
+		userIDs[i] = fmt.Sprintf("60601fee-2bf1-4721-ae6f-7636e79a0c%02d", i)
+		for _, service := range []string{"Netflix", "Spotify"} {
+			sub := &subscriptions.Subscription{
+				ServiceName: service,
+				Price:       100 * (i + 1),
+				UserID:      userIDs[i],
+				StartDate:   time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			}
+			if err := repo.Create(ctx, sub); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+	}
+
+	ids, err := json.Marshal(userIDs)
+	if err != nil {
+		t.Fatalf("marshal ids: %v", err)
+	}
+	query := `{ users(ids: ` + string(ids) + `) { id subscriptions { serviceName userId } services { serviceName monthlyCost } } }`
+
+	code, resp := serve(t, repo, Request{Query: query})
+	if code != http.StatusOK || len(resp.Errors) != 0 {
+		t.Fatalf("status %d, errors %+v", code, resp.Errors)
+	}
+	// subscriptions и services всех пользователей разделяют один загрузчик
+	if n := repo.lists.Load(); n != 1 {
+		t.Fatalf("%d List calls for %d users, want 1", n, len(userIDs))
+	}
+
+	var users []struct {
+		ID            string
+		Subscriptions []struct{ ServiceName, UserID string }
+		Services      []struct {
+			ServiceName string
+			MonthlyCost int
+		}
+	}
+	if err := json.Unmarshal(resp.Data["users"], &users); err != nil {
+		t.Fatalf("decode users: %v", err)
+	}
+	if len(users) != len(userIDs) {
+		t.Fatalf("%d users, want %d", len(users), len(userIDs))
+	}
+	for i, u := range users {
+		if u.ID != userIDs[i] || len(u.Subscriptions) != 2 || len(u.Services) != 2 {
+			t.Fatalf("user %d: %+v", i, u)
+		}
+		for _, sub := range u.Subscriptions {
+			if !strings.EqualFold(sub.UserID, u.ID) {
+				t.Fatalf("user %s got a subscription of %s", u.ID, sub.UserID)
+			}
+		}
+		for _, s := range u.Services {
+			if s.MonthlyCost != 100*(i+1) {
+				t.Fatalf("user %d: %s costs %d", i, s.ServiceName, s.MonthlyCost)
+			}
+		}
+	}
+
+	// Каждый запрос получает свои загрузчики, поэтому кэш между запросами не переносится
+	if code, resp := serve(t, repo, Request{Query: query}); code != http.StatusOK || len(resp.Errors) != 0 {
+		t.Fatalf("second request: status %d, errors %+v", code, resp.Errors)
+	}
+	if n := repo.lists.Load(); n != 2 {
+		t.Fatalf("%d List calls after the second request, want 2", n)
+	}
+}
@@ -0,0 +1,471 @@
+package graphqlapi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+
+	"github.com/graphql-go/graphql"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// user — узел графа пользователя; все его поля вычисляются через загрузчики.
+type user struct {
+	ID string
+}
+
+// serviceSummary агрегирует подписки пользователя на один сервис.
+type serviceSummary struct {
+	ServiceName       string
+	SubscriptionCount int
+	MonthlyCost       int
+}
+
+type resolver struct {
+	logger *zap.Logger
+	repo   subscriptions.ISubscriptionRepository
+	now    subscriptions.Clock
+}
+
+// newSchema описывает граф: подписки с паузами, скидками, участниками и
+// историей цен, пользователей с агрегатами по сервисам и расчёт стоимости.
+func newSchema(r *resolver) (graphql.Schema, error) {
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "User",
+		Fields: graphql.Fields{},
+	})
+
+	pauseType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Pause",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(p *subscriptions.Pause) any { return p.ID })},
+			"startDate": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: field(func(p *subscriptions.Pause) any { return formatMonth(p.StartDate) })},
+			"endDate":   &graphql.Field{Type: graphql.String, Resolve: field(func(p *subscriptions.Pause) any { return formatOptionalMonth(p.EndDate) })},
+		},
+	})
+
+	discountType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Discount",
+		Fields: graphql.Fields{
+			"id":              &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(d *subscriptions.Discount) any { return d.ID })},
+			"kind":            &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: field(func(d *subscriptions.Discount) any { return string(d.Kind) })},
+			"value":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(d *subscriptions.Discount) any { return d.Value })},
+			"startDate":       &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: field(func(d *subscriptions.Discount) any { return formatMonth(d.StartDate) })},
+			"endDate":         &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: field(func(d *subscriptions.Discount) any { return formatMonth(d.EndDate()) })},
+			"durationPeriods": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(d *subscriptions.Discount) any { return d.DurationPeriods })},
+			"description":     &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: field(func(d *subscriptions.Discount) any { return d.Description })},
+		},
+	})
+
+	memberType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Member",
+		Fields: graphql.Fields{
+			"userId":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(m *subscriptions.Member) any { return m.UserID })},
+			"sharePercent": &graphql.Field{Type: graphql.Int, Resolve: field(func(m *subscriptions.Member) any { return optionalInt(m.SharePercent) })},
+			"fixedAmount":  &graphql.Field{Type: graphql.Int, Resolve: field(func(m *subscriptions.Member) any { return optionalInt(m.FixedAmount) })},
+			"user":         &graphql.Field{Type: graphql.NewNonNull(userType), Resolve: field(func(m *subscriptions.Member) any { return &user{ID: m.UserID} })},
+		},
+	})
+
+	priceSegmentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PriceSegment",
+		Fields: graphql.Fields{
+			"price":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(s subscriptions.PriceSegment) any { return s.Price })},
+			"effectiveFrom": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: field(func(s subscriptions.PriceSegment) any { return formatMonth(s.EffectiveFrom) })},
+			"effectiveTo":   &graphql.Field{Type: graphql.String, Resolve: field(func(s subscriptions.PriceSegment) any { return formatOptionalMonth(s.EffectiveTo) })},
+		},
+	})
+
+	subscriptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"id":                  &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(s *subscriptions.Subscription) any { return s.ID })},
+			"serviceName":         &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: field(func(s *subscriptions.Subscription) any { return s.ServiceName })},
+			"price":               &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(s *subscriptions.Subscription) any { return s.Price })},
+			"listPrice":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(s *subscriptions.Subscription) any { return s.ListPrice })},
+			"effectivePrice":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(s *subscriptions.Subscription) any { return s.EffectivePrice })},
+			"userId":              &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: field(func(s *subscriptions.Subscription) any { return s.UserID })},
+			"startDate":           &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: field(func(s *subscriptions.Subscription) any { return formatMonth(s.StartDate) })},
+			"endDate":             &graphql.Field{Type: graphql.String, Resolve: field(func(s *subscriptions.Subscription) any { return formatOptionalMonth(s.EndDate) })},
+			"cancelledAt":         &graphql.Field{Type: graphql.DateTime, Resolve: field(func(s *subscriptions.Subscription) any { return optionalTime(s.CancelledAt) })},
+			"cancellationReason":  &graphql.Field{Type: graphql.String, Resolve: field(func(s *subscriptions.Subscription) any { return optionalString(string(s.CancellationReason)) })},
+			"cancellationComment": &graphql.Field{Type: graphql.String, Resolve: field(func(s *subscriptions.Subscription) any { return optionalString(s.CancellationComment) })},
+			"pauses":              &graphql.Field{Type: listOf(pauseType), Resolve: field(func(s *subscriptions.Subscription) any { return s.Pauses })},
+			"discounts":           &graphql.Field{Type: listOf(discountType), Resolve: field(func(s *subscriptions.Subscription) any { return s.Discounts })},
+			"members":             &graphql.Field{Type: listOf(memberType), Resolve: field(func(s *subscriptions.Subscription) any { return s.Members })},
+			"priceTimeline":       &graphql.Field{Type: listOf(priceSegmentType), Resolve: field(func(s *subscriptions.Subscription) any { return s.PriceTimeline() })},
+			"owner":               &graphql.Field{Type: graphql.NewNonNull(userType), Resolve: field(func(s *subscriptions.Subscription) any { return &user{ID: s.UserID} })},
+			"cost": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Total cost of the subscription for the period; without from it starts with the subscription, without to it ends with the current month",
+				Args:        periodArgs(),
+				Resolve:     r.subscriptionCost,
+			},
+		},
+	})
+
+	serviceSummaryType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "ServiceSummary",
+		Description: "User's subscriptions to one service and their share of the current month's cost",
+		Fields: graphql.Fields{
+			"serviceName":       &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: field(func(s *serviceSummary) any { return s.ServiceName })},
+			"subscriptionCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(s *serviceSummary) any { return s.SubscriptionCount })},
+			"monthlyCost":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(s *serviceSummary) any { return s.MonthlyCost })},
+		},
+	})
+
+	userType.AddFieldConfig("id", &graphql.Field{
+		Type:    graphql.NewNonNull(graphql.ID),
+		Resolve: field(func(u *user) any { return u.ID }),
+	})
+	userType.AddFieldConfig("subscriptions", &graphql.Field{
+		Type:        listOf(subscriptionType),
+		Description: "Subscriptions owned by the user",
+		Args: graphql.FieldConfigArgument{
+			"serviceName": &graphql.ArgumentConfig{Type: graphql.String},
+		},
+		Resolve: r.userSubscriptions,
+	})
+	userType.AddFieldConfig("services", &graphql.Field{
+		Type:    listOf(serviceSummaryType),
+		Resolve: r.userServices,
+	})
+	userType.AddFieldConfig("monthlyCost", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.Int),
+		Description: "User's share of the current month's cost, including subscriptions shared with them",
+		Resolve:     r.userMonthlyCost,
+	})
+	userType.AddFieldConfig("cost", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.Int),
+		Description: "User's share of the cost for the period, including subscriptions shared with them",
+		Args:        withServiceName(periodArgs()),
+		Resolve:     r.userCost,
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"subscription": &graphql.Field{
+				Type: subscriptionType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.subscription,
+			},
+			"subscriptions": &graphql.Field{
+				Type: listOf(subscriptionType),
+				Args: graphql.FieldConfigArgument{
+					"userId":        &graphql.ArgumentConfig{Type: graphql.ID},
+					"serviceName":   &graphql.ArgumentConfig{Type: graphql.String},
+					"startDateFrom": &graphql.ArgumentConfig{Type: graphql.String},
+					"startDateTo":   &graphql.ArgumentConfig{Type: graphql.String},
+					"limit":         &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"offset":        &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: r.subscriptions,
+			},
+			"user": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return &user{ID: p.Args["id"].(string)}, nil
+				},
+			},
+			"users": &graphql.Field{
+				Type: listOf(userType),
+				Args: graphql.FieldConfigArgument{
+					"ids": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.ID)))},
+				},
+				Resolve: r.users,
+			},
+			"cost": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Total cost of matching subscriptions, same as GET /api/v1/subscriptions/cost",
+				Args: withServiceName(graphql.FieldConfigArgument{
+					"userId": &graphql.ArgumentConfig{Type: graphql.ID},
+					"from":   &graphql.ArgumentConfig{Type: graphql.String},
+					"to":     &graphql.ArgumentConfig{Type: graphql.String},
+				}),
+				Resolve: r.cost,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func (r *resolver) subscription(p graphql.ResolveParams) (interface{}, error) {
+	sub, err := r.repo.GetByID(p.Context, p.Args["id"].(string))
+	switch {
+	case err == nil:
+		return sub, nil
+	case errors.Is(err, subscriptions.ErrSubscriptionNotFound):
+		return nil, nil
+	default:
+		return nil, r.internalError("failed to get subscription", err)
+	}
+}
+
+func (r *resolver) subscriptions(p graphql.ResolveParams) (interface{}, error) {
+	filters := make(map[string]interface{})
+	if v, ok := p.Args["userId"].(string); ok {
+		filters["user_id"] = v
+	}
+	if v, ok := p.Args["serviceName"].(string); ok {
+		filters["service_name"] = v
+	}
+	for arg, key := range map[string]string{"startDateFrom": "start_date_from", "startDateTo": "start_date_to"} {
+		if v, ok := p.Args[arg].(string); ok {
+			date, err := parseMonth(arg, v)
+			if err != nil {
+				return nil, err
+			}
+			filters[key] = date
+		}
+	}
+
+	limit, ok := p.Args["limit"].(int)
+	if !ok {
+		limit = defaultPageSize
+	}
+	offset, _ := p.Args["offset"].(int)
+	if limit < 0 || offset < 0 {
+		return nil, errors.New("limit and offset must not be negative")
+	}
+	filters["limit"] = min(limit, maxPageSize)
+	filters["offset"] = offset
+
+	subs, err := r.repo.List(p.Context, filters)
+	if err != nil {
+		return nil, r.internalError("failed to list subscriptions", err)
+	}
+	return subs, nil
+}
+
+func (r *resolver) users(p graphql.ResolveParams) (interface{}, error) {
+	ids := p.Args["ids"].([]interface{})
+	if len(ids) > maxPageSize {
+		return nil, fmt.Errorf("at most %d ids can be requested at once", maxPageSize)
+	}
+
+	users := make([]*user, len(ids))
+	for i, id := range ids {
+		users[i] = &user{ID: id.(string)}
+	}
+	return users, nil
+}
+
+func (r *resolver) cost(p graphql.ResolveParams) (interface{}, error) {
+	serviceName, _ := p.Args["serviceName"].(string)
+	from, to, err := periodFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := periodFilters(serviceName, from, to)
+	if v, ok := p.Args["userId"].(string); ok {
+		filters["user_id"] = v
+	}
+
+	total, err := r.repo.CalculateMonthlyCost(p.Context, filters)
+	if err != nil {
+		return nil, r.internalError("failed to calculate monthly cost", err)
+	}
+	return total, nil
+}
+
+func (r *resolver) subscriptionCost(p graphql.ResolveParams) (interface{}, error) {
+	from, to, err := periodFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	if to.IsZero() {
+		to = r.now()
+	}
+	return p.Source.(*subscriptions.Subscription).CostBetween(from, to), nil
+}
+
+func (r *resolver) userSubscriptions(p graphql.ResolveParams) (interface{}, error) {
+	thunk := loadersFrom(p.Context).subscriptionsByUser.Load(p.Context, p.Source.(*user).ID)
+	serviceName, byService := p.Args["serviceName"].(string)
+
+	return func() (interface{}, error) {
+		subs, err := thunk()
+		if err != nil {
+			return nil, r.internalError("failed to load user subscriptions", err)
+		}
+		if !byService {
+			return subs, nil
+		}
+
+		filtered := make([]*subscriptions.Subscription, 0, len(subs))
+		for _, sub := range subs {
+			if sub.ServiceName == serviceName {
+				filtered = append(filtered, sub)
+			}
+		}
+		return filtered, nil
+	}, nil
+}
+
+func (r *resolver) userServices(p graphql.ResolveParams) (interface{}, error) {
+	userID := p.Source.(*user).ID
+	thunk := loadersFrom(p.Context).subscriptionsByUser.Load(p.Context, userID)
+	month := r.now()
+
+	return func() (interface{}, error) {
+		subs, err := thunk()
+		if err != nil {
+			return nil, r.internalError("failed to load user subscriptions", err)
+		}
+
+		byService := make(map[string]*serviceSummary)
+		for _, sub := range subs {
+			summary, ok := byService[sub.ServiceName]
+			if !ok {
+				summary = &serviceSummary{ServiceName: sub.ServiceName}
+				byService[sub.ServiceName] = summary
+			}
+			summary.SubscriptionCount++
+			summary.MonthlyCost += sub.SharesForMonth(month)[userID]
+		}
+
+		summaries := make([]*serviceSummary, 0, len(byService))
+		for _, summary := range byService {
+			summaries = append(summaries, summary)
+		}
+		sort.Slice(summaries, func(i, j int) bool {
+			return summaries[i].ServiceName < summaries[j].ServiceName
+		})
+		return summaries, nil
+	}, nil
+}
+
+func (r *resolver) userMonthlyCost(p graphql.ResolveParams) (interface{}, error) {
+	now := r.now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return r.loadUserCost(p, costKey{UserID: p.Source.(*user).ID, From: month, To: month}), nil
+}
+
+func (r *resolver) userCost(p graphql.ResolveParams) (interface{}, error) {
+	from, to, err := periodFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	serviceName, _ := p.Args["serviceName"].(string)
+	return r.loadUserCost(p, costKey{UserID: p.Source.(*user).ID, ServiceName: serviceName, From: from, To: to}), nil
+}
+
+func (r *resolver) loadUserCost(p graphql.ResolveParams, key costKey) func() (interface{}, error) {
+	thunk := loadersFrom(p.Context).userCost.Load(p.Context, key)
+	return func() (interface{}, error) {
+		total, err := thunk()
+		if err != nil {
+			return nil, r.internalError("failed to calculate user cost", err)
+		}
+		return total, nil
+	}
+}
+
+// internalError логирует ошибку репозитория и скрывает её детали от клиента,
+// как это делают HTTP-обработчики.
+func (r *resolver) internalError(msg string, err error) error {
+	r.logger.Error(msg, zap.Error(err))
+	return errors.New(msg)
+}
+
+// field адаптирует функцию над значением-источником к резолверу graphql-go.
+func field[T any](fn func(T) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return fn(p.Source.(T)), nil
+	}
+}
+
+func listOf(t graphql.Type) graphql.Output {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t)))
+}
+
+func periodArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"from": &graphql.ArgumentConfig{Type: graphql.String, Description: "First month MM-YYYY"},
+		"to":   &graphql.ArgumentConfig{Type: graphql.String, Description: "Last month MM-YYYY"},
+	}
+}
+
+func withServiceName(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["serviceName"] = &graphql.ArgumentConfig{Type: graphql.String}
+	return args
+}
+
+func periodFromArgs(args map[string]interface{}) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+
+	if v, ok := args["from"].(string); ok {
+		if from, err = parseMonth("from", v); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if v, ok := args["to"].(string); ok {
+		if to, err = parseMonth("to", v); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return time.Time{}, time.Time{}, subscriptions.ErrInvalidDateRange
+	}
+
+	return from, to, nil
+}
+
+func parseMonth(arg, value string) (time.Time, error) {
+	date, err := time.Parse("01-2006", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s format, use MM-YYYY", arg)
+	}
+	return date, nil
+}
+
+func formatMonth(t time.Time) string {
+	return t.Format("01-2006")
+}
+
+// Функции optional* возвращают nil без типа, чтобы graphql-go вернул null.
+
+func formatOptionalMonth(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return formatMonth(*t)
+}
+
+func optionalTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+func optionalInt(v *int) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func optionalString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
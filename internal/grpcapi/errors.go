@@ -0,0 +1,59 @@
+package grpcapi
+
+import (
+	"errors"
+
+	"SubscriptionService/internal/subscriptions"
+	"SubscriptionService/pkg/db"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus переводит ошибки домена в gRPC-статусы. Коды подобраны так, чтобы
+// grpc-gateway (runtime.HTTPStatusFromCode) давал тот же HTTP-статус, что и REST API:
+// NotFound → 404, InvalidArgument → 400, AlreadyExists/Aborted → 409, Unavailable → 503.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, subscriptions.ErrSubscriptionNotFound),
+		errors.Is(err, subscriptions.ErrDiscountNotFound),
+		errors.Is(err, subscriptions.ErrMemberNotFound):
+		return status.Error(codes.NotFound, err.Error())
+
+	case errors.Is(err, subscriptions.ErrInvalidServiceName),
+		errors.Is(err, subscriptions.ErrInvalidPrice),
+		errors.Is(err, subscriptions.ErrInvalidUserID),
+		errors.Is(err, subscriptions.ErrInvalidDateRange),
+		errors.Is(err, subscriptions.ErrInvalidEffectiveDate),
+		errors.Is(err, subscriptions.ErrInvalidCancellation),
+		errors.Is(err, subscriptions.ErrInvalidCancelDate):
+		return status.Error(codes.InvalidArgument, err.Error())
+
+	case errors.Is(err, subscriptions.ErrMemberExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+
+	case errors.Is(err, subscriptions.ErrSubscriptionEnded),
+		errors.Is(err, subscriptions.ErrAlreadyCancelled),
+		errors.Is(err, subscriptions.ErrCancelBeforeStart),
+		errors.Is(err, subscriptions.ErrPauseOverlap),
+		errors.Is(err, subscriptions.ErrDiscountOverlap),
+		errors.Is(err, subscriptions.ErrSharesExceedTotal),
+		errors.Is(err, subscriptions.ErrPriceChangeRequired),
+		errors.Is(err, subscriptions.ErrDetailsOutOfRange),
+		errors.Is(err, subscriptions.ErrTrialNotAtStart),
+		errors.Is(err, subscriptions.ErrMemberIsOwner):
+		return status.Error(codes.Aborted, err.Error())
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if db.IsUnavailable(err) {
+		return status.Error(codes.Unavailable, "database is unavailable")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,98 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"time"
+
+	subscriptionv1 "SubscriptionService/api/subscription/v1"
+	"SubscriptionService/internal/subscriptions"
+	"SubscriptionService/pkg/db"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server is the gRPC counterpart of subscriptions.Server.
+type Server struct {
+	grpcServer *grpc.Server
+	logger     *zap.Logger
+}
+
+func NewServer(logger *zap.Logger, repo subscriptions.ISubscriptionRepository) *Server {
+	s := &Server{logger: logger}
+
+	s.grpcServer = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			s.recoveryInterceptor(),
+			s.loggingInterceptor(),
+		),
+	)
+	subscriptionv1.RegisterSubscriptionServiceServer(s.grpcServer, NewSubscriptionService(logger, repo))
+
+	return s
+}
+
+// Serve принимает соединения на уже открытом listener (используется в тестах с bufconn).
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpcServer.Serve(lis)
+}
+
+func (s *Server) Start(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("Starting gRPC server", zap.String("address", addr))
+	return s.grpcServer.Serve(lis)
+}
+
+// Shutdown дожидается завершения активных вызовов, но не дольше, чем позволяет ctx.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.logger.Info("Shutting down gRPC server")
+
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) loggingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		// Каждый вызов — отдельная сессия read-your-writes, см. db.WithSession
+		resp, err := handler(db.WithSession(ctx), req)
+
+		s.logger.Info("gRPC request",
+			zap.String("method", info.FullMethod),
+			zap.String("code", status.Code(err).String()),
+			zap.Duration("duration", time.Since(start)),
+		)
+		return resp, err
+	}
+}
+
+func (s *Server) recoveryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				s.logger.Error("panic in gRPC handler",
+					zap.Any("panic", r),
+					zap.String("method", info.FullMethod))
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
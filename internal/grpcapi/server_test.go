@@ -0,0 +1,214 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	subscriptionv1 "SubscriptionService/api/subscription/v1"
+	"SubscriptionService/internal/subscriptions"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testUserID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func newTestClient(t *testing.T) subscriptionv1.SubscriptionServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := NewServer(zap.NewNop(), subscriptions.NewMemoryRepository())
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial bufconn: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	})
+
+	return subscriptionv1.NewSubscriptionServiceClient(conn)
+}
+
+func TestCreateAndGetSubscription(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	created, err := client.CreateSubscription(ctx, &subscriptionv1.CreateSubscriptionRequest{
+		ServiceName: "Yandex Plus",
+		Price:       400,
+		UserId:      testUserID,
+		StartDate:   "07-2025",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	if created.GetId() == "" {
+		t.Fatal("expected created subscription to have an ID")
+	}
+
+	got, err := client.GetSubscription(ctx, &subscriptionv1.GetSubscriptionRequest{Id: created.GetId()})
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if got.GetServiceName() != "Yandex Plus" || got.GetPrice() != 400 || got.GetStartDate() != "07-2025" {
+		t.Errorf("unexpected subscription: %v", got)
+	}
+}
+
+func TestErrorStatusMapping(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.GetSubscription(ctx, &subscriptionv1.GetSubscriptionRequest{Id: "missing"})
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("GetSubscription missing: got %v, want NotFound", code)
+	}
+
+	_, err = client.CreateSubscription(ctx, &subscriptionv1.CreateSubscriptionRequest{
+		ServiceName: "Netflix",
+		Price:       -1,
+		UserId:      testUserID,
+		StartDate:   "07-2025",
+	})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("CreateSubscription negative price: got %v, want InvalidArgument", code)
+	}
+
+	_, err = client.CreateSubscription(ctx, &subscriptionv1.CreateSubscriptionRequest{
+		ServiceName: "Netflix",
+		Price:       500,
+		UserId:      testUserID,
+		StartDate:   "2025-07",
+	})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("CreateSubscription bad date: got %v, want InvalidArgument", code)
+	}
+
+	_, err = client.DeleteSubscription(ctx, &subscriptionv1.DeleteSubscriptionRequest{Id: "missing"})
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("DeleteSubscription missing: got %v, want NotFound", code)
+	}
+}
+
+func TestCreateRejectsOverlap(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	req := &subscriptionv1.CreateSubscriptionRequest{
+		ServiceName: "Spotify",
+		Price:       300,
+		UserId:      testUserID,
+		StartDate:   "01-2025",
+	}
+	if _, err := client.CreateSubscription(ctx, req); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	_, err := client.CreateSubscription(ctx, req)
+	if code := status.Code(err); code != codes.AlreadyExists {
+		t.Errorf("duplicate CreateSubscription: got %v, want AlreadyExists", code)
+	}
+
+	req.AllowOverlap = true
+	if _, err := client.CreateSubscription(ctx, req); err != nil {
+		t.Errorf("CreateSubscription with allow_overlap: %v", err)
+	}
+}
+
+func TestListSubscriptionsPagination(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	for i := range 5 {
+		_, err := client.CreateSubscription(ctx, &subscriptionv1.CreateSubscriptionRequest{
+			ServiceName: fmt.Sprintf("Service %d", i),
+			Price:       100,
+			UserId:      testUserID,
+			StartDate:   "01-2025",
+		})
+		if err != nil {
+			t.Fatalf("CreateSubscription: %v", err)
+		}
+	}
+
+	var ids []string
+	token := ""
+	for {
+		resp, err := client.ListSubscriptions(ctx, &subscriptionv1.ListSubscriptionsRequest{
+			UserId:    testUserID,
+			PageSize:  2,
+			PageToken: token,
+		})
+		if err != nil {
+			t.Fatalf("ListSubscriptions: %v", err)
+		}
+		if len(resp.GetSubscriptions()) > 2 {
+			t.Fatalf("page has %d items, want at most 2", len(resp.GetSubscriptions()))
+		}
+		for _, sub := range resp.GetSubscriptions() {
+			ids = append(ids, sub.GetId())
+		}
+		token = resp.GetNextPageToken()
+		if token == "" {
+			break
+		}
+	}
+
+	if len(ids) != 5 {
+		t.Errorf("listed %d subscriptions across pages, want 5", len(ids))
+	}
+
+	_, err := client.ListSubscriptions(ctx, &subscriptionv1.ListSubscriptionsRequest{PageToken: "bogus"})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("ListSubscriptions bad token: got %v, want InvalidArgument", code)
+	}
+}
+
+func TestCalculateCost(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.CreateSubscription(ctx, &subscriptionv1.CreateSubscriptionRequest{
+		ServiceName: "Yandex Plus",
+		Price:       400,
+		UserId:      testUserID,
+		StartDate:   "01-2025",
+		EndDate:     "06-2025",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	resp, err := client.CalculateCost(ctx, &subscriptionv1.CalculateCostRequest{
+		UserId:        testUserID,
+		StartDateFrom: "03-2025",
+		StartDateTo:   "12-2025",
+	})
+	if err != nil {
+		t.Fatalf("CalculateCost: %v", err)
+	}
+	// март–июнь: 4 месяца по 400
+	if resp.GetTotalCost() != 1600 {
+		t.Errorf("total cost = %d, want 1600", resp.GetTotalCost())
+	}
+}
@@ -0,0 +1,266 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	subscriptionv1 "SubscriptionService/api/subscription/v1"
+	"SubscriptionService/internal/subscriptions"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// SubscriptionService реализует subscription.v1.SubscriptionService поверх того же
+// репозитория, что и HTTP-обработчики.
+type SubscriptionService struct {
+	subscriptionv1.UnimplementedSubscriptionServiceServer
+
+	logger *zap.Logger
+	repo   subscriptions.ISubscriptionRepository
+}
+
+func NewSubscriptionService(logger *zap.Logger, repo subscriptions.ISubscriptionRepository) *SubscriptionService {
+	return &SubscriptionService{
+		logger: logger,
+		repo:   repo,
+	}
+}
+
+func (s *SubscriptionService) CreateSubscription(ctx context.Context, req *subscriptionv1.CreateSubscriptionRequest) (*subscriptionv1.Subscription, error) {
+	startDate, err := parseMonth("start_date", req.GetStartDate())
+	if err != nil {
+		return nil, err
+	}
+	endDate, err := parseOptionalMonth("end_date", req.GetEndDate())
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := subscriptions.NewSubscription(
+		req.GetServiceName(),
+		int(req.GetPrice()),
+		req.GetUserId(),
+		startDate,
+		endDate,
+	)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if req.GetAllowOverlap() {
+		err = s.repo.Create(ctx, sub)
+	} else {
+		_, err = s.repo.CreateNonOverlapping(ctx, sub)
+	}
+	switch {
+	case errors.Is(err, subscriptions.ErrSubscriptionOverlaps):
+		return nil, status.Error(codes.AlreadyExists,
+			"subscription overlaps an existing subscription to the same service, set allow_overlap to create it anyway")
+	case err != nil:
+		s.logger.Error("failed to create subscription", zap.Error(err))
+		return nil, toStatus(err)
+	}
+
+	return toProto(sub), nil
+}
+
+func (s *SubscriptionService) GetSubscription(ctx context.Context, req *subscriptionv1.GetSubscriptionRequest) (*subscriptionv1.Subscription, error) {
+	sub, err := s.repo.GetByID(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *SubscriptionService) UpdateSubscription(ctx context.Context, req *subscriptionv1.UpdateSubscriptionRequest) (*subscriptionv1.Subscription, error) {
+	startDate, err := parseMonth("start_date", req.GetStartDate())
+	if err != nil {
+		return nil, err
+	}
+	endDate, err := parseOptionalMonth("end_date", req.GetEndDate())
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &subscriptions.Subscription{
+		ID:          req.GetId(),
+		ServiceName: req.GetServiceName(),
+		Price:       int(req.GetPrice()),
+		UserID:      req.GetUserId(),
+		StartDate:   startDate,
+		EndDate:     endDate,
+	}
+	if err := sub.Validate(); err != nil {
+		return nil, toStatus(err)
+	}
+
+	if err := s.repo.Update(ctx, sub); err != nil {
+		s.logger.Error("failed to update subscription", zap.Error(err))
+		return nil, toStatus(err)
+	}
+
+	updated, err := s.repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(updated), nil
+}
+
+func (s *SubscriptionService) DeleteSubscription(ctx context.Context, req *subscriptionv1.DeleteSubscriptionRequest) (*emptypb.Empty, error) {
+	if err := s.repo.Delete(ctx, req.GetId()); err != nil {
+		s.logger.Error("failed to delete subscription", zap.Error(err))
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *SubscriptionService) ListSubscriptions(ctx context.Context, req *subscriptionv1.ListSubscriptionsRequest) (*subscriptionv1.ListSubscriptionsResponse, error) {
+	filters, err := requestFilters(req.GetUserId(), req.GetServiceName(), req.GetStartDateFrom(), req.GetStartDateTo())
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	offset := 0
+	if token := req.GetPageToken(); token != "" {
+		offset, err = strconv.Atoi(token)
+		if err != nil || offset < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+	}
+
+	// Запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	filters["limit"] = pageSize + 1
+	filters["offset"] = offset
+
+	subs, err := s.repo.List(ctx, filters)
+	if err != nil {
+		s.logger.Error("failed to list subscriptions", zap.Error(err))
+		return nil, toStatus(err)
+	}
+
+	resp := &subscriptionv1.ListSubscriptionsResponse{}
+	if len(subs) > pageSize {
+		subs = subs[:pageSize]
+		resp.NextPageToken = strconv.Itoa(offset + pageSize)
+	}
+	for _, sub := range subs {
+		resp.Subscriptions = append(resp.Subscriptions, toProto(sub))
+	}
+
+	return resp, nil
+}
+
+func (s *SubscriptionService) CalculateCost(ctx context.Context, req *subscriptionv1.CalculateCostRequest) (*subscriptionv1.CalculateCostResponse, error) {
+	filters, err := requestFilters(req.GetUserId(), req.GetServiceName(), req.GetStartDateFrom(), req.GetStartDateTo())
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.repo.CalculateMonthlyCost(ctx, filters)
+	if err != nil {
+		s.logger.Error("failed to calculate monthly cost", zap.Error(err))
+		return nil, toStatus(err)
+	}
+
+	return &subscriptionv1.CalculateCostResponse{TotalCost: int64(total)}, nil
+}
+
+// requestFilters собирает фильтры репозитория так же, как HTTP-обработчики List и CalculateCost.
+func requestFilters(userID, serviceName, from, to string) (map[string]interface{}, error) {
+	filters := make(map[string]interface{})
+
+	if serviceName != "" {
+		filters["service_name"] = serviceName
+	}
+	if userID != "" {
+		filters["user_id"] = userID
+	}
+	if from != "" {
+		date, err := parseMonth("start_date_from", from)
+		if err != nil {
+			return nil, err
+		}
+		filters["start_date_from"] = date
+	}
+	if to != "" {
+		date, err := parseMonth("start_date_to", to)
+		if err != nil {
+			return nil, err
+		}
+		filters["start_date_to"] = date
+	}
+
+	return filters, nil
+}
+
+func parseMonth(field, value string) (time.Time, error) {
+	date, err := time.Parse("01-2006", value)
+	if err != nil {
+		return time.Time{}, status.Errorf(codes.InvalidArgument, "invalid %s format, use MM-YYYY", field)
+	}
+	return date, nil
+}
+
+func parseOptionalMonth(field, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := parseMonth(field, value)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+func formatMonth(t time.Time) string {
+	return t.Format("01-2006")
+}
+
+func toProto(sub *subscriptions.Subscription) *subscriptionv1.Subscription {
+	pb := &subscriptionv1.Subscription{
+		Id:                 sub.ID,
+		ServiceName:        sub.ServiceName,
+		Price:              int64(sub.Price),
+		ListPrice:          int64(sub.ListPrice),
+		EffectivePrice:     int64(sub.EffectivePrice),
+		UserId:             sub.UserID,
+		StartDate:          formatMonth(sub.StartDate),
+		CancellationReason: string(sub.CancellationReason),
+	}
+
+	if sub.EndDate != nil {
+		pb.EndDate = formatMonth(*sub.EndDate)
+	}
+	if sub.CancelledAt != nil {
+		pb.CancelledAt = timestamppb.New(*sub.CancelledAt)
+	}
+	if !sub.CreatedAt.IsZero() {
+		pb.CreatedAt = timestamppb.New(sub.CreatedAt)
+	}
+	if !sub.UpdatedAt.IsZero() {
+		pb.UpdatedAt = timestamppb.New(sub.UpdatedAt)
+	}
+
+	return pb
+}
@@ -0,0 +1,228 @@
+// Package health serves liveness, readiness and detailed health endpoints
+// for orchestrators and operators.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"SubscriptionService/pkg/db"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CheckFunc checks one dependency; a nil error means it is healthy.
+type CheckFunc func(ctx context.Context) error
+
+// DependencyStatus is the result of one check in the /health response.
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the /health and /readyz response body.
+type Report struct {
+	Status       string                      `json:"status"`
+	ShuttingDown bool                        `json:"shutting_down"`
+	Uptime       string                      `json:"uptime,omitempty"`
+	Checks       map[string]DependencyStatus `json:"checks,omitempty"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs the registered dependency checks, each with its own timeout.
+type Checker struct {
+	logger       *zap.Logger
+	timeout      time.Duration
+	started      time.Time
+	checks       []check
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(logger *zap.Logger, timeout time.Duration) *Checker {
+	return &Checker{logger: logger, timeout: timeout, started: time.Now()}
+}
+
+// Register adds a dependency check. Checks must be registered before the
+// routes start serving.
+func (h *Checker) Register(name string, fn CheckFunc) {
+	h.checks = append(h.checks, check{name: name, fn: fn})
+}
+
+// SetShuttingDown makes readiness fail from now on. It is called before the
+// HTTP server stops accepting connections, so the orchestrator stops routing
+// new traffic while in-flight requests drain.
+func (h *Checker) SetShuttingDown() {
+	if !h.shuttingDown.Swap(true) {
+		h.logger.Info("Readiness switched to failing: shutting down")
+	}
+}
+
+func (h *Checker) RegisterRoutes(router *gin.Engine) {
+	router.GET("/healthz", h.liveness)
+	router.GET("/readyz", h.readiness)
+	router.GET("/health", h.health)
+}
+
+// liveness отвечает, пока процесс способен обрабатывать запросы; зависимости не проверяются,
+// чтобы недоступная БД не приводила к перезапуску контейнера
+func (h *Checker) liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, Report{Status: "ok"})
+}
+
+func (h *Checker) readiness(c *gin.Context) {
+	if h.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, Report{Status: "unavailable", ShuttingDown: true})
+		return
+	}
+
+	report := h.run(c.Request.Context())
+	if report.Status != "ok" {
+		h.logFailures("Readiness check failed", report)
+		c.JSON(http.StatusServiceUnavailable, report.public())
+		return
+	}
+	c.JSON(http.StatusOK, report.public())
+}
+
+// health отдаёт отчёт без текстов ошибок: они могут содержать адреса и
+// параметры подключения, поэтому пишутся в лог, а полностью видны только
+// в AdminHandler на административном адресе.
+func (h *Checker) health(c *gin.Context) {
+	report := h.detailed(c.Request.Context())
+	if report.Status != "ok" {
+		h.logFailures("Health check failed", report)
+	}
+	c.JSON(statusCode(report), report.public())
+}
+
+// AdminHandler serves the detailed report including check errors. It is
+// meant for the admin listener, not the public API port.
+func (h *Checker) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.detailed(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(statusCode(report))
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			h.logger.Error("failed to write health report", zap.Error(err))
+		}
+	})
+}
+
+func (h *Checker) detailed(ctx context.Context) Report {
+	report := h.run(ctx)
+	report.Uptime = time.Since(h.started).Round(time.Second).String()
+	return report
+}
+
+func (h *Checker) logFailures(msg string, report Report) {
+	for name, s := range report.Checks {
+		if s.Error != "" {
+			h.logger.Warn(msg, zap.String("check", name), zap.String("error", s.Error))
+		}
+	}
+}
+
+func statusCode(report Report) int {
+	if report.Status != "ok" {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// public возвращает копию отчёта без текстов ошибок проверок.
+func (r Report) public() Report {
+	checks := make(map[string]DependencyStatus, len(r.Checks))
+	for name, s := range r.Checks {
+		s.Error = ""
+		checks[name] = s
+	}
+	r.Checks = checks
+	return r
+}
+
+// run выполняет все проверки параллельно, каждую со своим таймаутом.
+func (h *Checker) run(ctx context.Context) Report {
+	report := Report{
+		Status:       "ok",
+		ShuttingDown: h.shuttingDown.Load(),
+		Checks:       make(map[string]DependencyStatus, len(h.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := chk.fn(checkCtx)
+			result := DependencyStatus{
+				Status:    "up",
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = "down"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Checks[chk.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, s := range report.Checks {
+		if s.Status != "up" {
+			report.Status = "unavailable"
+		}
+	}
+	if report.ShuttingDown {
+		report.Status = "unavailable"
+	}
+	return report
+}
+
+// Pinger is satisfied by *pgxpool.Pool.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping checks that the database answers.
+func Ping(p Pinger) CheckFunc {
+	return p.Ping
+}
+
+// PendingLister is satisfied by *db.Migrator.
+type PendingLister interface {
+	Pending(ctx context.Context) ([]db.Migration, error)
+}
+
+// Migrations checks that every embedded migration has been applied.
+func Migrations(m PendingLister) CheckFunc {
+	return func(ctx context.Context) error {
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, first is %06d_%s", len(pending), pending[0].Version, pending[0].Name)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"SubscriptionService/pkg/db"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// secret имитирует текст ошибки pgx с параметрами подключения.
+const secret = "failed to connect to `host=db.internal user=app`"
+
+type pendingMigrations []db.Migration
+
+func (p pendingMigrations) Pending(context.Context) ([]db.Migration, error) {
+	return p, nil
+}
+
+func newTestRouter(h *Checker) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h.RegisterRoutes(r)
+	return r
+}
+
+func get(t *testing.T, handler http.Handler, path string) (int, Report, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	return w.Code, report, w.Body.String()
+}
+
+func TestReadinessShuttingDown(t *testing.T) {
+	h := NewChecker(zap.NewNop(), time.Second)
+	h.Register("database", func(context.Context) error { return nil })
+	r := newTestRouter(h)
+
+	if code, report, _ := get(t, r, "/readyz"); code != http.StatusOK || report.Status != "ok" || report.Checks["database"].Status != "up" {
+		t.Fatalf("ready: %d %+v", code, report)
+	}
+
+	h.SetShuttingDown()
+	if code, report, _ := get(t, r, "/readyz"); code != http.StatusServiceUnavailable || !report.ShuttingDown {
+		t.Fatalf("shutting down: %d %+v", code, report)
+	}
+	// Liveness не зависит от завершения работы, иначе контейнер перезапустят посреди drain
+	if code, _, _ := get(t, r, "/healthz"); code != http.StatusOK {
+		t.Fatalf("liveness while shutting down: %d", code)
+	}
+	if code, report, _ := get(t, r, "/health"); code != http.StatusServiceUnavailable || !report.ShuttingDown || report.Checks["database"].Status != "up" {
+		t.Fatalf("health while shutting down: %d %+v", code, report)
+	}
+}
+
+func TestPendingMigrationFailsReadiness(t *testing.T) {
+	h := NewChecker(zap.NewNop(), time.Second)
+	h.Register("database", func(context.Context) error { return errors.New(secret) })
+	h.Register("migrations", Migrations(pendingMigrations{{Version: 14, Name: "budget_notified_month"}}))
+	r := newTestRouter(h)
+
+	for _, path := range []string{"/readyz", "/health"} {
+		code, report, body := get(t, r, path)
+		if code != http.StatusServiceUnavailable || report.Status != "unavailable" || report.Checks["migrations"].Status != "down" {
+			t.Fatalf("%s: %d %+v", path, code, report)
+		}
+		// Публичные ответы не раскрывают тексты ошибок
+		if strings.Contains(body, "db.internal") || strings.Contains(body, "pending") {
+			t.Fatalf("%s exposes check errors: %s", path, body)
+		}
+	}
+	if code, _, _ := get(t, r, "/healthz"); code != http.StatusOK {
+		t.Fatalf("liveness with failing checks: %d", code)
+	}
+
+	code, report, _ := get(t, h.AdminHandler(), "/admin/health")
+	if code != http.StatusServiceUnavailable || report.Checks["database"].Error != secret ||
+		report.Checks["migrations"].Error != "1 pending migrations, first is 000014_budget_notified_month" || report.Uptime == "" {
+		t.Fatalf("admin report: %d %+v", code, report)
+	}
+
+	h = NewChecker(zap.NewNop(), time.Second)
+	h.Register("migrations", Migrations(pendingMigrations{}))
+	if code, _, _ := get(t, newTestRouter(h), "/readyz"); code != http.StatusOK {
+		t.Fatalf("readiness without pending migrations: %d", code)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	h := NewChecker(zap.NewNop(), 20*time.Millisecond)
+	// Зависшая зависимость отвечает только после отмены контекста проверки
+	h.Register("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h.Register("cache", func(context.Context) error { return nil })
+	r := newTestRouter(h)
+
+	start := time.Now()
+	code, report, _ := get(t, r, "/readyz")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("readiness took %v with a 20ms check timeout", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || report.Checks["database"].Status != "down" || report.Checks["cache"].Status != "up" {
+		t.Fatalf("readiness with a hanging check: %d %+v", code, report)
+	}
+	if latency := report.Checks["database"].LatencyMS; latency < 20 || latency > 1000 {
+		t.Fatalf("hanging check latency %vms", latency)
+	}
+
+	_, report, _ = get(t, h.AdminHandler(), "/admin/health")
+	if !strings.Contains(report.Checks["database"].Error, context.DeadlineExceeded.Error()) {
+		t.Fatalf("admin report: %+v", report)
+	}
+}
@@ -0,0 +1,87 @@
+// Package logging builds the service logger and carries request-scoped
+// loggers through context.Context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"SubscriptionService/internal/config"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New builds the root logger from the configuration. The returned level can
+// be changed at runtime; it implements http.Handler for GET and PUT requests.
+func New(cfg config.LogConfig) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, zap.AtomicLevel{}, fmt.Errorf("invalid log level: %w", err)
+	}
+
+	var zc zap.Config
+	if cfg.Format == "console" {
+		zc = zap.NewDevelopmentConfig()
+	} else {
+		zc = zap.NewProductionConfig()
+		zc.EncoderConfig.TimeKey = "time"
+		zc.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	}
+	zc.Level = zap.NewAtomicLevelAt(level)
+	// Сэмплируются только access-логи (см. Sampled), остальные сообщения пишутся полностью
+	zc.Sampling = nil
+
+	logger, err := zc.Build()
+	if err != nil {
+		return nil, zap.AtomicLevel{}, err
+	}
+	return logger, zc.Level, nil
+}
+
+// Sampled returns a logger that writes the first initial entries with the same
+// message and level every second and then every thereafter-th one. initial 0
+// disables sampling.
+func Sampled(logger *zap.Logger, initial, thereafter int) *zap.Logger {
+	if initial <= 0 {
+		return logger
+	}
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, time.Second, initial, thereafter)
+	}))
+}
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// WithRequest stores the request ID and a logger annotated with it in ctx.
+// When ctx carries a span, its trace and span IDs are added to the logger too.
+func WithRequest(ctx context.Context, logger *zap.Logger, requestID string) context.Context {
+	fields := []zap.Field{zap.String("request_id", requestID)}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields,
+			zap.String("trace_id", sc.TraceID().String()),
+			zap.String("span_id", sc.SpanID().String()))
+	}
+
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	return context.WithValue(ctx, loggerKey{}, logger.With(fields...))
+}
+
+// FromContext returns the request-scoped logger from ctx, or fallback outside
+// of a request.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// BusinessMetrics exports subscription KPIs. They are recomputed by Run on a
+// fixed interval rather than on scrape, so scrapes never query the database.
+type BusinessMetrics struct {
+	subs   subscriptions.ISubscriptionRepository
+	logger *zap.Logger
+	clock  subscriptions.Clock
+
+	active      *prometheus.GaugeVec
+	monthlyCost *prometheus.GaugeVec
+	refreshedAt prometheus.Gauge
+}
+
+func NewBusinessMetrics(reg prometheus.Registerer, subs subscriptions.ISubscriptionRepository, logger *zap.Logger, clock subscriptions.Clock) *BusinessMetrics {
+	m := &BusinessMetrics{
+		subs:   subs,
+		logger: logger,
+		clock:  clock,
+		active: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "active",
+			Help:      "Number of subscriptions active in the current month by service.",
+		}, []string{"service_name"}),
+		monthlyCost: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "monthly_recurring_cost",
+			Help:      "Total cost of the current month by service, in the currency of subscription prices, after pauses and discounts.",
+		}, []string{"service_name"}),
+		refreshedAt: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "business_metrics_refreshed_timestamp_seconds",
+			Help:      "Unix time of the last successful refresh of business metrics.",
+		}),
+	}
+	reg.MustRegister(m.active, m.monthlyCost, m.refreshedAt)
+	return m
+}
+
+// Refresh пересчитывает показатели за текущий месяц.
+func (m *BusinessMetrics) Refresh(ctx context.Context) error {
+	now := m.clock().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	subs, err := m.subs.ListForPeriod(ctx, map[string]interface{}{
+		"start_date_from": month,
+		"start_date_to":   month,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to refresh business metrics: %w", err)
+	}
+
+	active := make(map[string]int)
+	cost := make(map[string]int)
+	for _, sub := range subs {
+		if !sub.ActiveIn(month) {
+			continue
+		}
+		active[sub.ServiceName]++
+		cost[sub.ServiceName] += sub.CostForMonth(month)
+	}
+
+	// Сбрасываем, чтобы исчезали сервисы без активных подписок
+	m.active.Reset()
+	m.monthlyCost.Reset()
+	for service, n := range active {
+		m.active.WithLabelValues(service).Set(float64(n))
+		m.monthlyCost.WithLabelValues(service).Set(float64(cost[service]))
+	}
+	m.refreshedAt.Set(float64(m.clock().Unix()))
+	return nil
+}
+
+// Run периодически вызывает Refresh до отмены контекста.
+func (m *BusinessMetrics) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Refresh(ctx); err != nil {
+			m.logger.Error("business metrics refresh failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Package ratelimit limits requests per client with token buckets. Buckets
+// live in a Store: in memory for a single replica or in PostgreSQL when
+// several replicas must share limits.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket: Rate tokens per second are added up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result describes the bucket after a request has taken a token or been denied.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a denied client should wait for the next token.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps token buckets by key.
+type Store interface {
+	// Take removes one token from the bucket key, creating a full bucket if needed.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Cleanup deletes buckets untouched for idle; such buckets are full anyway.
+	Cleanup(ctx context.Context, idle time.Duration) error
+}
+
+// take пополняет корзину за прошедшее время и пытается забрать один токен.
+// Возвращает новое число токенов; общая логика для всех хранилищ.
+func take(tokens float64, elapsed time.Duration, limit Limit) (float64, Result) {
+	if elapsed > 0 {
+		tokens = math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate)
+	}
+
+	res := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	res.Remaining = int(tokens)
+	res.Reset = seconds((float64(limit.Burst) - tokens) / limit.Rate)
+	return tokens, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// fullAfter — время, за которое пустая корзина наполняется полностью.
+func (l Limit) fullAfter() time.Duration {
+	return seconds(float64(l.Burst) / l.Rate)
+}
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"SubscriptionService/internal/config"
+	"SubscriptionService/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+	UserHeader   = "X-User-ID"
+)
+
+// defaultGroup — имя группы для маршрутов, не попавших ни в одну из настроенных
+const defaultGroup = "default"
+
+type group struct {
+	name   string
+	prefix string
+	limit  Limit
+	keyBy  string
+}
+
+// Limiter applies per-client token bucket limits to HTTP requests.
+type Limiter struct {
+	store  Store
+	logger *zap.Logger
+	exempt []string
+	// groups отсортированы по убыванию длины префикса, чтобы выигрывал самый точный
+	groups []group
+	def    group
+}
+
+func NewLimiter(store Store, cfg config.RateLimitConfig, logger *zap.Logger) *Limiter {
+	l := &Limiter{
+		store:  store,
+		logger: logger,
+		exempt: cfg.Exempt,
+		def: group{
+			name:  defaultGroup,
+			limit: Limit{Rate: cfg.Rate, Burst: cfg.Burst},
+			keyBy: cfg.KeyBy,
+		},
+	}
+	for _, g := range cfg.Groups {
+		keyBy := g.KeyBy
+		if keyBy == "" {
+			keyBy = cfg.KeyBy
+		}
+		l.groups = append(l.groups, group{
+			name:   g.Name,
+			prefix: g.Prefix,
+			limit:  Limit{Rate: g.Rate, Burst: g.Burst},
+			keyBy:  keyBy,
+		})
+	}
+	sort.SliceStable(l.groups, func(i, j int) bool {
+		return len(l.groups[i].prefix) > len(l.groups[j].prefix)
+	})
+	return l
+}
+
+// Middleware rejects requests over the limit with 429 Too Many Requests.
+// Every limited response carries RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset; rejected ones also carry Retry-After. If the store fails,
+// the request is let through: an outage of the limiter must not take the API down.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Шаблон маршрута, а для неизвестных путей — сам путь
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		for _, prefix := range l.exempt {
+			if strings.HasPrefix(path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		g := l.match(path)
+		res, err := l.store.Take(c.Request.Context(), g.name+":"+clientKey(c, g.keyBy), g.limit)
+		if err != nil {
+			logging.FromContext(c.Request.Context(), l.logger).Error("rate limiter unavailable, request allowed", zap.Error(err))
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(res.Reset))
+		if !res.Allowed {
+			c.Header("Retry-After", ceilSeconds(res.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (l *Limiter) match(path string) group {
+	for _, g := range l.groups {
+		if strings.HasPrefix(path, g.prefix) {
+			return g
+		}
+	}
+	return l.def
+}
+
+// clientKey определяет клиента. Заголовки X-API-Key и X-User-ID имеют смысл только за
+// аутентифицирующим шлюзом; без них клиент определяется по IP.
+func clientKey(c *gin.Context, keyBy string) string {
+	switch keyBy {
+	case "api_key":
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			// Ключ хранится только в виде хеша, в том числе в таблице PostgreSQL
+			sum := sha256.Sum256([]byte(key))
+			return "key:" + hex.EncodeToString(sum[:16])
+		}
+	case "user":
+		if user := c.GetHeader(UserHeader); user != "" {
+			return "user:" + user
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Run периодически удаляет простаивающие корзины до отмены контекста. Корзина,
+// не использовавшаяся дольше времени полного наполнения, равна новой.
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+	idle := l.def.limit.fullAfter()
+	for _, g := range l.groups {
+		idle = max(idle, g.limit.fullAfter())
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := l.store.Cleanup(ctx, idle); err != nil {
+			l.logger.Error("rate limit cleanup failed", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"SubscriptionService/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// newTestRouter подключает limiter к роутеру с маршрутами из разных групп.
+func newTestRouter(store Store, cfg config.RateLimitConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(NewLimiter(store, cfg, zap.NewNop()).Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	for _, path := range []string{
+		"/healthz",
+		"/api/v1/subscriptions/:id",
+		"/api/v1/reports/monthly",
+		"/api/v1/reports/forecast",
+		"/api/v1/reports/forecast/services",
+	} {
+		r.GET(path, ok)
+	}
+	return r
+}
+
+type request struct {
+	path    string
+	ip      string
+	headers map[string]string
+}
+
+func (req request) do(r http.Handler) *httptest.ResponseRecorder {
+	httpReq := httptest.NewRequest(http.MethodGet, req.path, nil)
+	httpReq.RemoteAddr = req.ip + ":41000"
+	for k, v := range req.headers {
+		httpReq.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httpReq)
+	return w
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	cfg := config.RateLimitConfig{
+		KeyBy:  "ip",
+		Rate:   1,
+		Burst:  3,
+		Exempt: []string{"/healthz"},
+		Groups: []config.RateLimitGroup{
+			{Name: "reports", Prefix: "/api/v1/reports", Rate: 1, Burst: 2},
+			{Name: "forecast", Prefix: "/api/v1/reports/forecast", Rate: 1, Burst: 1, KeyBy: "user"},
+		},
+	}
+	now := time.Date(2025, time.May, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore(func() time.Time { return now })
+	r := newTestRouter(store, cfg)
+
+	sub := request{path: "/api/v1/subscriptions/42", ip: "192.0.2.1"}
+	for i := range 3 {
+		w := sub.do(r)
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "3" ||
+			w.Header().Get("RateLimit-Remaining") != strconv.Itoa(2-i) || w.Header().Get("Retry-After") != "" {
+			t.Fatalf("request %d: %d %v", i, w.Code, w.Header())
+		}
+	}
+	w := sub.do(r)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" || w.Header().Get("RateLimit-Reset") != "3" {
+		t.Fatalf("request over the limit: %d %v", w.Code, w.Header())
+	}
+
+	// Другой IP и другая группа считаются отдельно, исключённые пути не ограничены
+	if w := (request{path: "/api/v1/subscriptions/42", ip: "192.0.2.2"}).do(r); w.Code != http.StatusOK {
+		t.Fatalf("other client: %d", w.Code)
+	}
+	reports := request{path: "/api/v1/reports/monthly", ip: "192.0.2.1"}
+	for range 2 {
+		if w := reports.do(r); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("reports: %d %v", w.Code, w.Header())
+		}
+	}
+	if w := reports.do(r); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("reports over the limit: %d", w.Code)
+	}
+	for range 10 {
+		if w := (request{path: "/healthz", ip: "192.0.2.1"}).do(r); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("exempt path: %d %v", w.Code, w.Header())
+		}
+	}
+
+	// Самый длинный префикс выигрывает и задаёт способ определения клиента
+	for _, path := range []string{"/api/v1/reports/forecast", "/api/v1/reports/forecast/services"} {
+		alice := request{path: path, ip: "192.0.2.1", headers: map[string]string{UserHeader: "alice"}}
+		bob := request{path: path, ip: "192.0.2.1", headers: map[string]string{UserHeader: "bob"}}
+		if w := alice.do(r); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+			t.Fatalf("%s: %d %v", path, w.Code, w.Header())
+		}
+		if w := alice.do(r); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("%s over the limit: %d", path, w.Code)
+		}
+		if w := bob.do(r); w.Code != http.StatusOK {
+			t.Fatalf("%s for another user: %d", path, w.Code)
+		}
+		now = now.Add(time.Second)
+	}
+
+	// Корзины пополняются со временем
+	now = now.Add(time.Second)
+	if w := sub.do(r); w.Code != http.StatusOK {
+		t.Fatalf("request after a refill: %d", w.Code)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyBy   string
+		headers map[string]string
+		want    string
+	}{
+		{name: "ip", keyBy: "ip", want: "ip:192.0.2.1"},
+		{name: "ip ignores headers", keyBy: "ip", headers: map[string]string{UserHeader: "alice", APIKeyHeader: "secret"}, want: "ip:192.0.2.1"},
+		{name: "user", keyBy: "user", headers: map[string]string{UserHeader: "alice"}, want: "user:alice"},
+		{name: "user without header", keyBy: "user", want: "ip:192.0.2.1"},
+		{name: "api key is hashed", keyBy: "api_key", headers: map[string]string{APIKeyHeader: "secret"}, want: "key:2bb80d537b1da3e38bd30361aa855686"},
+		{name: "api key without header", keyBy: "api_key", headers: map[string]string{UserHeader: "alice"}, want: "ip:192.0.2.1"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.RemoteAddr = "192.0.2.1:41000"
+			for k, v := range tt.headers {
+				c.Request.Header.Set(k, v)
+			}
+			if got := clientKey(c, tt.keyBy); got != tt.want {
+				t.Fatalf("key %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (Result, error) {
+	return Result{}, errors.New("connection refused")
+}
+
+func (failingStore) Cleanup(context.Context, time.Duration) error {
+	return nil
+}
+
+func TestLimiterStoreFailure(t *testing.T) {
+	r := newTestRouter(failingStore{}, config.RateLimitConfig{KeyBy: "ip", Rate: 1, Burst: 1})
+
+	// Недоступное хранилище не должно останавливать API
+	for range 3 {
+		if w := (request{path: "/api/v1/subscriptions/42", ip: "192.0.2.1"}).do(r); w.Code != http.StatusOK {
+			t.Fatalf("request with a failing store: %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryStore keeps buckets in process memory; limits are per replica.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewMemoryStore(now func() time.Time) *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: now}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	var res Result
+	b.tokens, res = take(b.tokens, now.Sub(b.updated), limit)
+	b.updated = now
+	return res, nil
+}
+
+func (s *MemoryStore) Cleanup(_ context.Context, idle time.Duration) error {
+	threshold := s.now().Add(-idle)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, b := range s.buckets {
+		if b.updated.Before(threshold) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
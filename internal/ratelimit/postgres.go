@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so that all
+// replicas share the same limits. The database clock is used for refills,
+// so clock skew between replicas does not matter.
+type PostgresStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	var res Result
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		// Новая корзина создаётся полной; конкурирующие запросы ждут блокировку строки
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+			VALUES ($1, $2, clock_timestamp())
+			ON CONFLICT (key) DO NOTHING`, key, float64(limit.Burst)); err != nil {
+			return err
+		}
+
+		var tokens float64
+		var updated, now time.Time
+		if err := tx.QueryRow(ctx, `
+			SELECT tokens, updated_at, clock_timestamp()
+			FROM rate_limit_buckets
+			WHERE key = $1
+			FOR UPDATE`, key).Scan(&tokens, &updated, &now); err != nil {
+			return err
+		}
+
+		tokens, res = take(tokens, now.Sub(updated), limit)
+
+		_, err := tx.Exec(ctx, `
+			UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3
+			WHERE key = $1`, key, tokens, now)
+		return err
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return res, nil
+}
+
+func (s *PostgresStore) Cleanup(ctx context.Context, idle time.Duration) error {
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM rate_limit_buckets
+		WHERE updated_at < clock_timestamp() - make_interval(secs => $1)`, idle.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to clean up rate limit buckets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"SubscriptionService/migrations"
+	"SubscriptionService/pkg/db"
+
+	"go.uber.org/zap"
+)
+
+func TestTake(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 4}
+
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		wantTokens float64
+		want       Result
+	}{
+		{
+			name: "full bucket", tokens: 4, wantTokens: 3,
+			want: Result{Allowed: true, Limit: 4, Remaining: 3, Reset: 500 * time.Millisecond},
+		},
+		{
+			name: "last token", tokens: 1, wantTokens: 0,
+			want: Result{Allowed: true, Limit: 4, Remaining: 0, Reset: 2 * time.Second},
+		},
+		{
+			name: "empty bucket", tokens: 0.5, wantTokens: 0.5,
+			want: Result{Limit: 4, Remaining: 0, RetryAfter: 250 * time.Millisecond, Reset: 1750 * time.Millisecond},
+		},
+		{
+			name: "refill", tokens: 0, elapsed: time.Second, wantTokens: 1,
+			want: Result{Allowed: true, Limit: 4, Remaining: 1, Reset: 1500 * time.Millisecond},
+		},
+		{
+			name: "refill stops at burst", tokens: 1, elapsed: time.Hour, wantTokens: 3,
+			want: Result{Allowed: true, Limit: 4, Remaining: 3, Reset: 500 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, res := take(tt.tokens, tt.elapsed, limit)
+			if tokens != tt.wantTokens || res != tt.want {
+				t.Fatalf("tokens %v, result %+v, want %v and %+v", tokens, res, tt.wantTokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2025, time.May, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore(func() time.Time { return now })
+	testStoreContract(t, store, func(d time.Duration) { now = now.Add(d) })
+}
+
+// TestPostgresStore проверяет PostgresStore на базе из TEST_DB_URL. Корзины
+// пополняются по часам базы, поэтому время здесь идёт по-настоящему.
+func TestPostgresStore(t *testing.T) {
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	ctx := context.Background()
+	logger := zap.NewNop()
+	pool, err := db.NewPGXPool(ctx, db.PoolConfig{URL: url, MaxConns: 4}, logger)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err := db.NewMigrator(pool, migrations.FS, logger).Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `TRUNCATE rate_limit_buckets`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	testStoreContract(t, NewPostgresStore(pool), time.Sleep)
+}
+
+// testStoreContract проверяет поведение, общее для всех хранилищ. wait сдвигает
+// часы хранилища; лимит подобран так, чтобы задержки самих вызовов к базе
+// не меняли число токенов.
+func testStoreContract(t *testing.T, store Store, wait func(time.Duration)) {
+	ctx := context.Background()
+	limit := Limit{Rate: 10, Burst: 3}
+
+	takeN := func(key string, n int) Result {
+		t.Helper()
+		var res Result
+		for range n {
+			var err error
+			if res, err = store.Take(ctx, key, limit); err != nil {
+				t.Fatalf("take: %v", err)
+			}
+		}
+		return res
+	}
+
+	if res := takeN("a", 3); !res.Allowed || res.Remaining != 0 || res.Limit != 3 {
+		t.Fatalf("third request %+v", res)
+	}
+	res := takeN("a", 1)
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > 100*time.Millisecond {
+		t.Fatalf("request over the burst %+v", res)
+	}
+
+	// Корзины разных клиентов независимы
+	if res := takeN("b", 1); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("other key %+v", res)
+	}
+
+	wait(150 * time.Millisecond)
+	if res := takeN("a", 1); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("request after a refill %+v", res)
+	}
+
+	// Простаивающие корзины удаляются, активные остаются
+	wait(time.Second)
+	takeN("b", 1)
+	if err := store.Cleanup(ctx, 500*time.Millisecond); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if ms, ok := store.(*MemoryStore); ok {
+		if _, ok := ms.buckets["a"]; ok || len(ms.buckets) != 1 {
+			t.Fatalf("buckets after cleanup: %d", len(ms.buckets))
+		}
+	}
+	if res := takeN("a", 1); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("request after cleanup %+v", res)
+	}
+}
@@ -0,0 +1,271 @@
+// Package resilience protects the subscription repository from transient
+// database failures with retries and a circuit breaker.
+package resilience
+
+import (
+	"context"
+	"time"
+
+	"SubscriptionService/internal/logging"
+	"SubscriptionService/internal/metrics"
+	"SubscriptionService/internal/subscriptions"
+	"SubscriptionService/pkg/db"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Repository decorates ISubscriptionRepository. Reads are retried on any
+// transient error (see db.IsTransient); writes only when nothing can have been
+// committed (see db.IsRolledBack), so a retry never applies a change twice.
+// All calls go through a circuit breaker that fails fast with db.ErrCircuitOpen
+// while the database is down.
+//
+// Интерфейс не встраивается намеренно: новый метод репозитория без обёртки
+// не должен молча обходить breaker.
+type Repository struct {
+	repo    subscriptions.ISubscriptionRepository
+	policy  db.RetryPolicy
+	breaker *db.Breaker
+	logger  *zap.Logger
+	retries *prometheus.CounterVec
+}
+
+// NewRepository wraps repo. The breaker opens after threshold consecutive
+// failed calls and lets a probe through after cooldown.
+func NewRepository(repo subscriptions.ISubscriptionRepository, policy db.RetryPolicy, threshold int, cooldown time.Duration, reg prometheus.Registerer, logger *zap.Logger) *Repository {
+	state := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "db",
+		Name:      "circuit_breaker_state",
+		Help:      "State of the database circuit breaker: 0 closed, 1 open, 2 half-open.",
+	})
+	r := &Repository{
+		repo:   repo,
+		policy: policy,
+		logger: logger,
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "db",
+			Name:      "retries_total",
+			Help:      "Repeated repository calls after a transient database error, by method.",
+		}, []string{"method"}),
+	}
+	r.breaker = db.NewBreaker(threshold, cooldown, time.Now, func(s db.BreakerState) {
+		state.Set(float64(s))
+		if s == db.BreakerOpen {
+			logger.Warn("database circuit breaker opened", zap.Duration("cooldown", cooldown))
+		} else {
+			logger.Info("database circuit breaker state changed", zap.Stringer("state", s))
+		}
+	})
+	reg.MustRegister(state, r.retries)
+	return r
+}
+
+// call выполняет fn через breaker с повторами. idempotent определяет, какие
+// ошибки можно повторять.
+func call[T any](ctx context.Context, r *Repository, method string, idempotent bool, fn func() (T, error)) (T, error) {
+	retryable := db.IsRolledBack
+	if idempotent {
+		retryable = db.IsTransient
+	}
+
+	var result T
+	err := r.policy.Do(ctx, retryable, r.retried(ctx, method),
+		func() error {
+			if err := r.breaker.Allow(); err != nil {
+				return err
+			}
+			var err error
+			result, err = fn()
+			r.breaker.Record(err)
+			return err
+		})
+	return result, err
+}
+
+// retried учитывает и логирует повтор вызова method.
+func (r *Repository) retried(ctx context.Context, method string) func(error) {
+	return func(err error) {
+		r.retries.WithLabelValues(method).Inc()
+		logging.FromContext(ctx, r.logger).Warn("retrying after transient database error",
+			zap.String("method", method), zap.Error(err))
+	}
+}
+
+// exec — call для методов, возвращающих только ошибку.
+func exec(ctx context.Context, r *Repository, method string, idempotent bool, fn func() error) error {
+	_, err := call(ctx, r, method, idempotent, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+func (r *Repository) Create(ctx context.Context, sub *subscriptions.Subscription) error {
+	return exec(ctx, r, "Create", false, func() error {
+		return r.repo.Create(ctx, sub)
+	})
+}
+
+// CreateNonOverlapping повторяется только после отката: пересечения проверяются
+// заново в той же транзакции, что и вставка.
+func (r *Repository) CreateNonOverlapping(ctx context.Context, sub *subscriptions.Subscription) ([]*subscriptions.Subscription, error) {
+	return call(ctx, r, "CreateNonOverlapping", false, func() ([]*subscriptions.Subscription, error) {
+		return r.repo.CreateNonOverlapping(ctx, sub)
+	})
+}
+
+func (r *Repository) GetByID(ctx context.Context, id string) (*subscriptions.Subscription, error) {
+	return call(ctx, r, "GetByID", true, func() (*subscriptions.Subscription, error) {
+		return r.repo.GetByID(ctx, id)
+	})
+}
+
+func (r *Repository) Update(ctx context.Context, sub *subscriptions.Subscription) error {
+	return exec(ctx, r, "Update", false, func() error {
+		return r.repo.Update(ctx, sub)
+	})
+}
+
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	return exec(ctx, r, "Delete", false, func() error {
+		return r.repo.Delete(ctx, id)
+	})
+}
+
+func (r *Repository) List(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.Subscription, error) {
+	return call(ctx, r, "List", true, func() ([]*subscriptions.Subscription, error) {
+		return r.repo.List(ctx, filters)
+	})
+}
+
+// StreamList повторяется, только пока fn не получила ни одной подписки: иначе
+// получатель увидел бы их дважды. Ошибка fn (например, клиент закрыл
+// соединение) не относится к БД и не учитывается breaker.
+func (r *Repository) StreamList(ctx context.Context, filters map[string]interface{}, fn func(*subscriptions.Subscription) error) error {
+	delivered := false
+	var fnErr error
+	retryable := func(err error) bool {
+		return !delivered && db.IsTransient(err)
+	}
+	return r.policy.Do(ctx, retryable, r.retried(ctx, "StreamList"), func() error {
+		if err := r.breaker.Allow(); err != nil {
+			return err
+		}
+		err := r.repo.StreamList(ctx, filters, func(sub *subscriptions.Subscription) error {
+			delivered = true
+			fnErr = fn(sub)
+			return fnErr
+		})
+		if fnErr != nil {
+			r.breaker.Record(nil)
+		} else {
+			r.breaker.Record(err)
+		}
+		return err
+	})
+}
+
+func (r *Repository) CalculateMonthlyCost(ctx context.Context, filters map[string]interface{}) (int, error) {
+	return call(ctx, r, "CalculateMonthlyCost", true, func() (int, error) {
+		return r.repo.CalculateMonthlyCost(ctx, filters)
+	})
+}
+
+func (r *Repository) ListForPeriod(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.Subscription, error) {
+	return call(ctx, r, "ListForPeriod", true, func() ([]*subscriptions.Subscription, error) {
+		return r.repo.ListForPeriod(ctx, filters)
+	})
+}
+
+func (r *Repository) FindDuplicates(ctx context.Context, userID string) ([]*subscriptions.Overlap, error) {
+	return call(ctx, r, "FindDuplicates", true, func() ([]*subscriptions.Overlap, error) {
+		return r.repo.FindDuplicates(ctx, userID)
+	})
+}
+
+func (r *Repository) FindOverlapping(ctx context.Context, sub *subscriptions.Subscription) ([]*subscriptions.Subscription, error) {
+	return call(ctx, r, "FindOverlapping", true, func() ([]*subscriptions.Subscription, error) {
+		return r.repo.FindOverlapping(ctx, sub)
+	})
+}
+
+func (r *Repository) Cancel(ctx context.Context, subscriptionID string, cancellation subscriptions.Cancellation) (*subscriptions.Subscription, error) {
+	return call(ctx, r, "Cancel", false, func() (*subscriptions.Subscription, error) {
+		return r.repo.Cancel(ctx, subscriptionID, cancellation)
+	})
+}
+
+func (r *Repository) ChurnStats(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.ChurnStat, error) {
+	return call(ctx, r, "ChurnStats", true, func() ([]*subscriptions.ChurnStat, error) {
+		return r.repo.ChurnStats(ctx, filters)
+	})
+}
+
+func (r *Repository) AddPriceChange(ctx context.Context, change *subscriptions.PriceChange) error {
+	return exec(ctx, r, "AddPriceChange", false, func() error {
+		return r.repo.AddPriceChange(ctx, change)
+	})
+}
+
+func (r *Repository) ListPriceChanges(ctx context.Context, subscriptionID string) ([]*subscriptions.PriceChange, error) {
+	return call(ctx, r, "ListPriceChanges", true, func() ([]*subscriptions.PriceChange, error) {
+		return r.repo.ListPriceChanges(ctx, subscriptionID)
+	})
+}
+
+func (r *Repository) Pause(ctx context.Context, subscriptionID string, from time.Time, to *time.Time) (*subscriptions.Pause, error) {
+	return call(ctx, r, "Pause", false, func() (*subscriptions.Pause, error) {
+		return r.repo.Pause(ctx, subscriptionID, from, to)
+	})
+}
+
+func (r *Repository) Resume(ctx context.Context, subscriptionID string, month time.Time) (*subscriptions.Pause, error) {
+	return call(ctx, r, "Resume", false, func() (*subscriptions.Pause, error) {
+		return r.repo.Resume(ctx, subscriptionID, month)
+	})
+}
+
+func (r *Repository) AddDiscount(ctx context.Context, subscriptionID string, discount *subscriptions.Discount) error {
+	return exec(ctx, r, "AddDiscount", false, func() error {
+		return r.repo.AddDiscount(ctx, subscriptionID, discount)
+	})
+}
+
+func (r *Repository) RemoveDiscount(ctx context.Context, subscriptionID, discountID string) error {
+	return exec(ctx, r, "RemoveDiscount", false, func() error {
+		return r.repo.RemoveDiscount(ctx, subscriptionID, discountID)
+	})
+}
+
+func (r *Repository) AddMember(ctx context.Context, subscriptionID string, member *subscriptions.Member) error {
+	return exec(ctx, r, "AddMember", false, func() error {
+		return r.repo.AddMember(ctx, subscriptionID, member)
+	})
+}
+
+func (r *Repository) RemoveMember(ctx context.Context, subscriptionID, userID string) error {
+	return exec(ctx, r, "RemoveMember", false, func() error {
+		return r.repo.RemoveMember(ctx, subscriptionID, userID)
+	})
+}
+
+func (r *Repository) PurgeEnded(ctx context.Context, before time.Time) (int64, error) {
+	// Повтор после потерянного ответа на COMMIT вернул бы 0 вместо числа удалённых
+	return call(ctx, r, "PurgeEnded", false, func() (int64, error) {
+		return r.repo.PurgeEnded(ctx, before)
+	})
+}
+
+func (r *Repository) MonthlySpend(ctx context.Context, filters map[string]interface{}) ([]*subscriptions.MonthlySpend, bool, error) {
+	type spend struct {
+		rows []*subscriptions.MonthlySpend
+		ok   bool
+	}
+	result, err := call(ctx, r, "MonthlySpend", true, func() (spend, error) {
+		rows, ok, err := r.repo.MonthlySpend(ctx, filters)
+		return spend{rows: rows, ok: ok}, err
+	})
+	return result.rows, result.ok, err
+}
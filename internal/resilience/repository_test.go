@@ -0,0 +1,123 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"SubscriptionService/internal/subscriptions"
+	"SubscriptionService/pkg/db"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// flakyRepository обрывает StreamList с ошибкой соединения после failAfter
+// подписок, пока не исчерпает failures.
+type flakyRepository struct {
+	*subscriptions.MemoryRepository
+	failures  int
+	failAfter int
+	calls     int
+}
+
+func (r *flakyRepository) StreamList(ctx context.Context, filters map[string]interface{}, fn func(*subscriptions.Subscription) error) error {
+	r.calls++
+	if r.failures == 0 {
+		return r.MemoryRepository.StreamList(ctx, filters, fn)
+	}
+	r.failures--
+
+	sent := 0
+	err := r.MemoryRepository.StreamList(ctx, filters, func(sub *subscriptions.Subscription) error {
+		if sent == r.failAfter {
+			return errConnectionLost
+		}
+		sent++
+		return fn(sub)
+	})
+	if err == nil {
+		err = errConnectionLost
+	}
+	return err
+}
+
+var errConnectionLost = &pgconn.PgError{Code: "08006"}
+
+func newStreamRepository(t *testing.T, failures, failAfter int) (*Repository, *flakyRepository) {
+	t.Helper()
+	flaky := &flakyRepository{MemoryRepository: subscriptions.NewMemoryRepository(), failures: failures, failAfter: failAfter}
+	for _, service := range []string{"Netflix", "Spotify", "Yandex Plus"} {
+		sub := &subscriptions.Subscription{
+			ServiceName: service,
+			Price:       500,
+			UserID:      "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+			StartDate:   time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		}
+		if err := flaky.Create(context.Background(), sub); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	policy := db.RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	repo := NewRepository(flaky, policy, 5, time.Minute, prometheus.NewRegistry(), zap.NewNop())
+	return repo, flaky
+}
+
+func TestStreamListRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		failAfter int
+		wantCalls int
+		wantSubs  int
+		wantErr   error
+	}{
+		{name: "retried before the first row", failures: 2, failAfter: 0, wantCalls: 3, wantSubs: 3},
+		{name: "not retried after a row was delivered", failures: 1, failAfter: 1, wantCalls: 1, wantSubs: 1, wantErr: errConnectionLost},
+		{name: "not retried after all rows were delivered", failures: 1, failAfter: 3, wantCalls: 1, wantSubs: 3, wantErr: errConnectionLost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, flaky := newStreamRepository(t, tt.failures, tt.failAfter)
+
+			seen := make(map[string]int)
+			err := repo.StreamList(context.Background(), nil, func(sub *subscriptions.Subscription) error {
+				seen[sub.ID]++
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if flaky.calls != tt.wantCalls || len(seen) != tt.wantSubs {
+				t.Fatalf("%d calls, %d subscriptions, want %d and %d", flaky.calls, len(seen), tt.wantCalls, tt.wantSubs)
+			}
+			for id, n := range seen {
+				if n != 1 {
+					t.Fatalf("subscription %s delivered %d times", id, n)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamListConsumerError(t *testing.T) {
+	repo, flaky := newStreamRepository(t, 0, 0)
+
+	// Ошибка получателя не повторяется и не открывает breaker
+	errClosed := errors.New("client went away")
+	for range 10 {
+		err := repo.StreamList(context.Background(), nil, func(*subscriptions.Subscription) error {
+			return errClosed
+		})
+		if !errors.Is(err, errClosed) {
+			t.Fatalf("got error %v, want %v", err, errClosed)
+		}
+	}
+	if flaky.calls != 10 || repo.breaker.State() != db.BreakerClosed {
+		t.Fatalf("%d calls, breaker %s", flaky.calls, repo.breaker.State())
+	}
+}
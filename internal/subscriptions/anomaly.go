@@ -0,0 +1,275 @@
+package subscriptions
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	AnomalySpendSpike   = "spend_spike"
+	AnomalyPriceOutlier = "price_outlier"
+
+	EventAnomalyDetected = "anomaly.detected"
+)
+
+const (
+	// anomalyLookbackMonths — сколько предыдущих месяцев образуют историю расходов пользователя.
+	anomalyLookbackMonths = 6
+	// minSpendHistory — минимум месяцев с расходами, при котором z-оценка имеет смысл.
+	minSpendHistory  = 3
+	spendZScoreLimit = 3.0
+	// minPriceSamples — минимум цен по сервису для расчёта межквартильного размаха.
+	minPriceSamples = 4
+	iqrFactor       = 1.5
+)
+
+// Anomaly is an unusual month-over-month spend jump of a user (spend_spike)
+// or a subscription price far from what other users pay for the service
+// (price_outlier).
+type Anomaly struct {
+	ID             string    `json:"id"`
+	Kind           string    `json:"kind"`
+	UserID         string    `json:"user_id"`
+	ServiceName    string    `json:"service_name,omitempty"`
+	SubscriptionID *string   `json:"subscription_id,omitempty"`
+	Month          time.Time `json:"month"`
+	Value          int       `json:"value"`
+	// Baseline is the mean historic spend for spend_spike and the median
+	// service price for price_outlier.
+	Baseline float64 `json:"baseline"`
+	// Score is the z-score for spend_spike and the distance from the nearest
+	// quartile in IQRs for price_outlier.
+	Score      float64   `json:"score"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// DetectSpendSpikes ищет пользователей, чьи расходы за month аномально выросли
+// относительно предыдущих месяцев (z-оценка не ниже spendZScoreLimit).
+func DetectSpendSpikes(subs []*Subscription, month time.Time) []*Anomaly {
+	month = monthStart(month)
+	from := month.AddDate(0, -anomalyLookbackMonths, 0)
+
+	// Помесячные расходы пользователя: индекс 0 — самый ранний месяц, последний — month
+	spend := make(map[string][]int)
+	for _, sub := range subs {
+		for i := 0; i <= anomalyLookbackMonths; i++ {
+			for userID, amount := range sub.SharesForMonth(from.AddDate(0, i, 0)) {
+				if _, ok := spend[userID]; !ok {
+					spend[userID] = make([]int, anomalyLookbackMonths+1)
+				}
+				spend[userID][i] += amount
+			}
+		}
+	}
+
+	var anomalies []*Anomaly
+	for userID, series := range spend {
+		history := make([]float64, 0, anomalyLookbackMonths)
+		for _, amount := range series[:anomalyLookbackMonths] {
+			if amount > 0 {
+				history = append(history, float64(amount))
+			}
+		}
+		if len(history) < minSpendHistory {
+			continue
+		}
+
+		current := series[anomalyLookbackMonths]
+		mean, std := meanStd(history)
+		if std == 0 {
+			continue
+		}
+
+		z := (float64(current) - mean) / std
+		if z < spendZScoreLimit {
+			continue
+		}
+
+		anomalies = append(anomalies, &Anomaly{
+			Kind:     AnomalySpendSpike,
+			UserID:   userID,
+			Month:    month,
+			Value:    current,
+			Baseline: mean,
+			Score:    z,
+		})
+	}
+
+	sortAnomalies(anomalies)
+	return anomalies
+}
+
+// DetectPriceOutliers ищет подписки, цена которых в month выходит за пределы
+// [Q1 - 1.5·IQR, Q3 + 1.5·IQR] цен других пользователей того же сервиса.
+func DetectPriceOutliers(subs []*Subscription, month time.Time) []*Anomaly {
+	month = monthStart(month)
+
+	byService := make(map[string][]*Subscription)
+	for _, sub := range subs {
+		if sub.ActiveIn(month) {
+			byService[sub.ServiceName] = append(byService[sub.ServiceName], sub)
+		}
+	}
+
+	var anomalies []*Anomaly
+	for service, serviceSubs := range byService {
+		if len(serviceSubs) < minPriceSamples {
+			continue
+		}
+
+		prices := make([]float64, len(serviceSubs))
+		for i, sub := range serviceSubs {
+			prices[i] = float64(sub.PriceAt(month))
+		}
+		sort.Float64s(prices)
+
+		q1, median, q3 := quantile(prices, 0.25), quantile(prices, 0.5), quantile(prices, 0.75)
+		iqr := q3 - q1
+		if iqr == 0 {
+			continue
+		}
+		low, high := q1-iqrFactor*iqr, q3+iqrFactor*iqr
+
+		for _, sub := range serviceSubs {
+			price := float64(sub.PriceAt(month))
+			var score float64
+			switch {
+			case price > high:
+				score = (price - q3) / iqr
+			case price < low:
+				score = (q1 - price) / iqr
+			default:
+				continue
+			}
+
+			id := sub.ID
+			anomalies = append(anomalies, &Anomaly{
+				Kind:           AnomalyPriceOutlier,
+				UserID:         sub.UserID,
+				ServiceName:    service,
+				SubscriptionID: &id,
+				Month:          month,
+				Value:          int(price),
+				Baseline:       median,
+				Score:          score,
+			})
+		}
+	}
+
+	sortAnomalies(anomalies)
+	return anomalies
+}
+
+// AnomalyDetector периодически ищет аномалии, сохраняет новые и уведомляет о них.
+type AnomalyDetector struct {
+	subs      ISubscriptionRepository
+	anomalies IAnomalyRepository
+	notifier  Notifier
+	logger    *zap.Logger
+	now       Clock
+}
+
+func NewAnomalyDetector(subs ISubscriptionRepository, anomalies IAnomalyRepository, notifier Notifier, logger *zap.Logger, clock Clock) *AnomalyDetector {
+	return &AnomalyDetector{
+		subs:      subs,
+		anomalies: anomalies,
+		notifier:  notifier,
+		logger:    logger,
+		now:       clock,
+	}
+}
+
+// Scan анализирует текущий месяц и возвращает впервые обнаруженные аномалии.
+func (d *AnomalyDetector) Scan(ctx context.Context) ([]*Anomaly, error) {
+	month := monthStart(d.now())
+
+	subs, err := d.subs.ListForPeriod(ctx, map[string]interface{}{
+		"start_date_from": month.AddDate(0, -anomalyLookbackMonths, 0),
+		"start_date_to":   month,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := append(DetectSpendSpikes(subs, month), DetectPriceOutliers(subs, month)...)
+
+	var created []*Anomaly
+	for _, a := range found {
+		a.DetectedAt = d.now().UTC()
+		inserted, err := d.anomalies.Save(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		if !inserted {
+			continue
+		}
+		created = append(created, a)
+
+		event := Event{
+			Type:       EventAnomalyDetected,
+			UserID:     a.UserID,
+			Payload:    a,
+			OccurredAt: a.DetectedAt,
+		}
+		if err := d.notifier.Notify(ctx, event); err != nil {
+			d.logger.Error("failed to notify about anomaly",
+				zap.Error(err),
+				zap.String("anomaly_id", a.ID))
+		}
+	}
+
+	return created, nil
+}
+
+// Run периодически вызывает Scan до отмены контекста.
+func (d *AnomalyDetector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Scan(ctx); err != nil {
+			d.logger.Error("anomaly scan failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func meanStd(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
+
+// quantile возвращает квантиль отсортированной выборки с линейной интерполяцией.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+func sortAnomalies(anomalies []*Anomaly) {
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].UserID != anomalies[j].UserID {
+			return anomalies[i].UserID < anomalies[j].UserID
+		}
+		return anomalies[i].ServiceName < anomalies[j].ServiceName
+	})
+}
@@ -0,0 +1,97 @@
+package subscriptions
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type AnomalyHandler struct {
+	logger   *zap.Logger
+	repo     IAnomalyRepository
+	detector *AnomalyDetector
+}
+
+func NewAnomalyHandler(logger *zap.Logger, repo IAnomalyRepository, detector *AnomalyDetector) *AnomalyHandler {
+	return &AnomalyHandler{
+		logger:   logger,
+		repo:     repo,
+		detector: detector,
+	}
+}
+
+func (h *AnomalyHandler) RegisterRoutes(router *gin.Engine) {
+	api := router.Group("/api/v1")
+	{
+		anomalies := api.Group("/anomalies")
+		{
+			anomalies.GET("", h.List)
+			anomalies.POST("/scan", h.Scan)
+		}
+	}
+}
+
+// List godoc
+// @Summary List detected anomalies
+// @Tags Anomalies
+// @Produce json
+// @Param kind query string false "spend_spike or price_outlier"
+// @Param user_id query string false "User ID"
+// @Param service_name query string false "Service Name"
+// @Param month query string false "Month MM-YYYY"
+// @Success 200 {array} Anomaly
+// @Failure 400,500 {object} gin.H
+// @Router /anomalies [get]
+func (h *AnomalyHandler) List(c *gin.Context) {
+	filters := make(map[string]interface{})
+
+	if kind := c.Query("kind"); kind != "" {
+		filters["kind"] = kind
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		filters["user_id"] = userID
+	}
+	if serviceName := c.Query("service_name"); serviceName != "" {
+		filters["service_name"] = serviceName
+	}
+	if month := c.Query("month"); month != "" {
+		date, err := time.Parse("01-2006", month)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month format, use MM-YYYY"})
+			return
+		}
+		filters["month"] = date
+	}
+
+	anomalies, err := h.repo.List(c.Request.Context(), filters)
+	if err != nil {
+		h.logger.Error("failed to list anomalies", zap.Error(err))
+		serverError(c, err, "failed to list anomalies")
+		return
+	}
+	c.JSON(http.StatusOK, anomalies)
+}
+
+// Scan godoc
+// @Summary Run anomaly detection now
+// @Description Returns anomalies detected for the first time by this run
+// @Tags Anomalies
+// @Produce json
+// @Success 200 {array} Anomaly
+// @Failure 500 {object} gin.H
+// @Router /anomalies/scan [post]
+func (h *AnomalyHandler) Scan(c *gin.Context) {
+	created, err := h.detector.Scan(c.Request.Context())
+	if err != nil {
+		h.logger.Error("failed to scan for anomalies", zap.Error(err))
+		serverError(c, err, "failed to scan for anomalies")
+		return
+	}
+
+	if created == nil {
+		created = []*Anomaly{}
+	}
+	c.JSON(http.StatusOK, created)
+}
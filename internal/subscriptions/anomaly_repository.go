@@ -0,0 +1,135 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"SubscriptionService/internal/logging"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type IAnomalyRepository interface {
+	// Save сохраняет аномалию и сообщает, была ли она новой.
+	Save(ctx context.Context, anomaly *Anomaly) (bool, error)
+	List(ctx context.Context, filters map[string]interface{}) ([]*Anomaly, error)
+	// Purge удаляет аномалии за месяцы раньше before и возвращает их количество.
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+type AnomalyRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewAnomalyRepository(db *pgxpool.Pool, logger *zap.Logger) *AnomalyRepository {
+	return &AnomalyRepository{db: db, logger: logger}
+}
+
+// log возвращает логгер запроса из ctx, чтобы ошибки БД содержали request_id
+func (r *AnomalyRepository) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, r.logger)
+}
+
+// Save не сохраняет повтор уже найденной аномалии: ключ — вид, пользователь,
+// сервис и месяц, а для выбросов цены ещё и подписка (см. миграцию 000012).
+func (r *AnomalyRepository) Save(ctx context.Context, anomaly *Anomaly) (bool, error) {
+	query := `
+		INSERT INTO anomalies (kind, user_id, service_name, subscription_id, month, value, baseline, score, detected_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT DO NOTHING
+		RETURNING id`
+
+	err := r.db.QueryRow(ctx, query,
+		anomaly.Kind,
+		anomaly.UserID,
+		anomaly.ServiceName,
+		anomaly.SubscriptionID,
+		anomaly.Month,
+		anomaly.Value,
+		anomaly.Baseline,
+		anomaly.Score,
+		anomaly.DetectedAt,
+	).Scan(&anomaly.ID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		r.log(ctx).Error("failed to save anomaly",
+			zap.Error(err),
+			zap.String("kind", anomaly.Kind),
+			zap.String("user_id", anomaly.UserID))
+		return false, fmt.Errorf("failed to save anomaly: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *AnomalyRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM anomalies WHERE month < $1`
+
+	result, err := r.db.Exec(ctx, query, before)
+	if err != nil {
+		r.log(ctx).Error("failed to purge anomalies",
+			zap.Error(err),
+			zap.Time("before", before))
+		return 0, fmt.Errorf("failed to purge anomalies: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r *AnomalyRepository) List(ctx context.Context, filters map[string]interface{}) ([]*Anomaly, error) {
+	baseQuery := `
+		SELECT id, kind, user_id, service_name, subscription_id, month, value, baseline, score, detected_at
+		FROM anomalies`
+
+	var conditions []string
+	var args []any
+	for _, field := range []string{"kind", "user_id", "service_name", "month"} {
+		if v, ok := filters[field]; ok {
+			args = append(args, v)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", field, len(args)))
+		}
+	}
+
+	if len(conditions) > 0 {
+		baseQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	baseQuery += " ORDER BY detected_at DESC, id"
+
+	rows, err := r.db.Query(ctx, baseQuery, args...)
+	if err != nil {
+		r.log(ctx).Error("failed to list anomalies", zap.Error(err))
+		return nil, fmt.Errorf("failed to list anomalies: %w", err)
+	}
+
+	anomalies, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Anomaly, error) {
+		var a Anomaly
+		err := row.Scan(
+			&a.ID,
+			&a.Kind,
+			&a.UserID,
+			&a.ServiceName,
+			&a.SubscriptionID,
+			&a.Month,
+			&a.Value,
+			&a.Baseline,
+			&a.Score,
+			&a.DetectedAt,
+		)
+		return &a, err
+	})
+	if err != nil {
+		r.log(ctx).Error("failed to scan anomalies", zap.Error(err))
+		return nil, fmt.Errorf("failed to list anomalies: %w", err)
+	}
+
+	return anomalies, nil
+}
@@ -0,0 +1,131 @@
+package subscriptions
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+var anomalyMonth = time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+// monthlySpend строит по одной месячной подписке на каждую сумму: amounts[0] —
+// самый ранний месяц, последняя сумма приходится на anomalyMonth; 0 — месяц без расходов.
+func monthlySpend(userID string, amounts ...int) []*Subscription {
+	var subs []*Subscription
+	for i, amount := range amounts {
+		if amount == 0 {
+			continue
+		}
+		month := anomalyMonth.AddDate(0, i-len(amounts)+1, 0)
+		subs = append(subs, &Subscription{
+			ID:          fmt.Sprintf("%s-%d", userID, i),
+			ServiceName: "Netflix",
+			Price:       amount,
+			UserID:      userID,
+			StartDate:   month,
+			EndDate:     &month,
+		})
+	}
+	return subs
+}
+
+func TestDetectSpendSpikes(t *testing.T) {
+	tests := []struct {
+		name      string
+		amounts   []int
+		wantScore float64
+	}{
+		{name: "spike", amounts: []int{100, 110, 90, 100, 110, 90, 400}, wantScore: (400 - 100) / math.Sqrt(200.0/3)},
+		{name: "growth below the z-score limit", amounts: []int{100, 110, 90, 100, 110, 90, 120}},
+		{name: "too short history", amounts: []int{0, 0, 0, 0, 100, 110, 1000}},
+		{name: "minimal history", amounts: []int{0, 0, 0, 90, 100, 110, 1000}, wantScore: (1000 - 100) / math.Sqrt(200.0/3)},
+		{name: "zero variance", amounts: []int{100, 100, 100, 100, 100, 100, 1000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := DetectSpendSpikes(monthlySpend(testUsers[0], tt.amounts...), anomalyMonth)
+			if tt.wantScore == 0 {
+				if len(found) != 0 {
+					t.Fatalf("unexpected anomalies: %+v", found[0])
+				}
+				return
+			}
+
+			if len(found) != 1 {
+				t.Fatalf("got %d anomalies, want 1", len(found))
+			}
+			a := found[0]
+			current := tt.amounts[len(tt.amounts)-1]
+			if a.Kind != AnomalySpendSpike || a.UserID != testUsers[0] || !a.Month.Equal(anomalyMonth) ||
+				a.Value != current || a.Baseline != 100 || a.Score < tt.wantScore-0.05 || a.SubscriptionID != nil {
+				t.Fatalf("unexpected anomaly %+v, want score %.2f", a, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestDetectPriceOutliers(t *testing.T) {
+	tests := []struct {
+		name   string
+		prices []int
+		// want — индексы подписок-выбросов и их оценки
+		want   map[int]float64
+		median float64
+		// owners переопределяют владельцев подписок по индексу
+		owners map[int]string
+	}{
+		{name: "above the upper fence", prices: []int{100, 100, 110, 120, 1000}, want: map[int]float64{4: 44}, median: 110},
+		{name: "below the lower fence", prices: []int{500, 500, 510, 520, 10}, want: map[int]float64{4: 49}, median: 500},
+		{name: "right at the fence", prices: []int{100, 100, 110, 120, 150}},
+		{name: "just past the fence", prices: []int{100, 100, 110, 120, 151}, want: map[int]float64{4: 1.55}, median: 110},
+		{name: "too few samples", prices: []int{100, 100, 1000}},
+		{name: "zero spread", prices: []int{100, 100, 100, 100, 100, 900}},
+		{
+			name:   "several outliers of one user",
+			prices: []int{100, 100, 100, 105, 110, 110, 115, 120, 1000, 2000},
+			want:   map[int]float64{8: 50.357, 9: 107.5},
+			median: 110,
+			owners: map[int]string{8: testUsers[3], 9: testUsers[3]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := make([]*Subscription, len(tt.prices))
+			for i, price := range tt.prices {
+				subs[i] = &Subscription{
+					ID:          fmt.Sprintf("sub-%d", i),
+					ServiceName: "Netflix",
+					Price:       price,
+					UserID:      testUsers[i%2],
+					StartDate:   anomalyMonth.AddDate(0, -2, 0),
+				}
+			}
+			for i, owner := range tt.owners {
+				subs[i].UserID = owner
+			}
+
+			found := DetectPriceOutliers(subs, anomalyMonth)
+			if len(found) != len(tt.want) {
+				t.Fatalf("got %d anomalies, want %d", len(found), len(tt.want))
+			}
+			for _, a := range found {
+				var index int
+				if a.SubscriptionID == nil {
+					t.Fatalf("price outlier without subscription: %+v", a)
+				}
+				fmt.Sscanf(*a.SubscriptionID, "sub-%d", &index)
+				score, ok := tt.want[index]
+				if !ok {
+					t.Fatalf("unexpected outlier %s", *a.SubscriptionID)
+				}
+				if a.Kind != AnomalyPriceOutlier || a.UserID != subs[index].UserID || a.ServiceName != "Netflix" ||
+					a.Value != tt.prices[index] || a.Baseline != tt.median || math.Abs(a.Score-score) > 0.01 {
+					t.Fatalf("unexpected anomaly %+v, want score %.2f", a, score)
+				}
+			}
+		})
+	}
+}
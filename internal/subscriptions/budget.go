@@ -0,0 +1,238 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidBudgetAmount = errors.New("budget amount must be positive")
+	ErrBudgetNotFound      = errors.New("budget not found")
+	ErrBudgetExists        = errors.New("budget for this scope already exists")
+)
+
+// Budget is a monthly spending limit for a user, either overall (nil
+// ServiceName) or for a single service. NotifiedMonth is the last month a
+// budget.exceeded event was sent for.
+type Budget struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"user_id"`
+	ServiceName   *string    `json:"service_name,omitempty"`
+	Amount        int        `json:"amount"`
+	NotifiedMonth *time.Time `json:"notified_month,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// BudgetStatus compares a budget with the projected spend for a month.
+type BudgetStatus struct {
+	Budget    *Budget   `json:"budget"`
+	Month     time.Time `json:"month"`
+	Spent     int       `json:"spent"`
+	Remaining int       `json:"remaining"`
+	Exceeded  bool      `json:"exceeded"`
+}
+
+func (b *Budget) Validate() error {
+	b.UserID = strings.TrimSpace(b.UserID)
+	if len(b.UserID) != 36 {
+		return ErrInvalidUserID
+	}
+
+	if b.ServiceName != nil {
+		name := strings.TrimSpace(*b.ServiceName)
+		if len(name) < 2 || len(name) > 100 {
+			return ErrInvalidServiceName
+		}
+		b.ServiceName = &name
+	}
+
+	if b.Amount <= 0 {
+		return ErrInvalidBudgetAmount
+	}
+
+	return nil
+}
+
+// budgetScope отличает общий бюджет от бюджетов по сервису.
+type budgetScope struct {
+	serviceName string
+	byService   bool
+}
+
+func (b *Budget) scope() budgetScope {
+	if b.ServiceName == nil {
+		return budgetScope{}
+	}
+	return budgetScope{serviceName: *b.ServiceName, byService: true}
+}
+
+// BudgetEvaluator сравнивает бюджеты с прогнозом расходов, рассчитанным движком стоимости.
+type BudgetEvaluator struct {
+	budgets  IBudgetRepository
+	subs     ISubscriptionRepository
+	notifier Notifier
+	logger   *zap.Logger
+	now      func() time.Time
+}
+
+func NewBudgetEvaluator(budgets IBudgetRepository, subs ISubscriptionRepository, notifier Notifier, logger *zap.Logger) *BudgetEvaluator {
+	return &BudgetEvaluator{
+		budgets:  budgets,
+		subs:     subs,
+		notifier: notifier,
+		logger:   logger,
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Evaluate возвращает состояние всех бюджетов пользователя за месяц.
+func (e *BudgetEvaluator) Evaluate(ctx context.Context, userID string, month time.Time) ([]*BudgetStatus, error) {
+	budgets, err := e.budgets.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return e.evaluate(ctx, budgets, month)
+}
+
+// CheckAll проверяет бюджеты всех пользователей за текущий месяц и отправляет
+// событие budget.exceeded по каждому превышенному бюджету не чаще раза в месяц.
+func (e *BudgetEvaluator) CheckAll(ctx context.Context) error {
+	budgets, err := e.budgets.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	month := monthStart(e.now())
+	statuses, err := e.evaluate(ctx, budgets, month)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if !status.Exceeded || notifiedIn(status.Budget, month) {
+			continue
+		}
+		e.notify(ctx, status)
+	}
+
+	return nil
+}
+
+// notify отправляет событие о превышении бюджета. Месяц уведомления сначала
+// занимается в строке бюджета, поэтому из нескольких экземпляров сервиса
+// событие отправит только один; при ошибке отправки он освобождается, и
+// следующая проверка повторит попытку.
+func (e *BudgetEvaluator) notify(ctx context.Context, status *BudgetStatus) {
+	b := status.Budget
+	previous := b.NotifiedMonth
+	month := status.Month
+
+	claimed, err := e.budgets.SwapNotifiedMonth(ctx, b.ID, previous, &month)
+	if err != nil {
+		e.logger.Error("failed to mark budget as notified",
+			zap.Error(err),
+			zap.String("budget_id", b.ID))
+		return
+	}
+	if !claimed {
+		return
+	}
+	b.NotifiedMonth = &month
+
+	event := Event{
+		Type:       EventBudgetExceeded,
+		UserID:     b.UserID,
+		Payload:    status,
+		OccurredAt: e.now(),
+	}
+	if err := e.notifier.Notify(ctx, event); err != nil {
+		e.logger.Error("failed to notify about exceeded budget",
+			zap.Error(err),
+			zap.String("budget_id", b.ID))
+		if _, err := e.budgets.SwapNotifiedMonth(ctx, b.ID, &month, previous); err != nil {
+			e.logger.Error("failed to release budget notification",
+				zap.Error(err),
+				zap.String("budget_id", b.ID))
+		}
+		b.NotifiedMonth = previous
+	}
+}
+
+// Run периодически вызывает CheckAll до отмены контекста.
+func (e *BudgetEvaluator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.CheckAll(ctx); err != nil {
+			e.logger.Error("budget check failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evaluate считает расходы по бюджетам одним запросом на каждый сервис из
+// бюджетов (и один на общие бюджеты) для всех их пользователей сразу: доля
+// каждого пользователя, в том числе в подписках, где он участник, считается
+// в памяти тем же движком стоимости, что и CalculateMonthlyCost.
+func (e *BudgetEvaluator) evaluate(ctx context.Context, budgets []*Budget, month time.Time) ([]*BudgetStatus, error) {
+	month = monthStart(month)
+
+	users := make(map[budgetScope][]string)
+	for _, b := range budgets {
+		users[b.scope()] = append(users[b.scope()], b.UserID)
+	}
+
+	subs := make(map[budgetScope][]*Subscription, len(users))
+	for sc, userIDs := range users {
+		filters := map[string]interface{}{
+			"user_ids":        userIDs,
+			"start_date_from": month,
+			"start_date_to":   month,
+		}
+		if sc.byService {
+			filters["service_name"] = sc.serviceName
+		}
+
+		list, err := e.subs.ListForPeriod(ctx, filters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate budgets: %w", err)
+		}
+		subs[sc] = list
+	}
+
+	statuses := make([]*BudgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		spent := PeriodCost(subs[b.scope()], map[string]interface{}{
+			"user_id":         b.UserID,
+			"start_date_from": month,
+			"start_date_to":   month,
+		}, month)
+
+		statuses = append(statuses, &BudgetStatus{
+			Budget:    b,
+			Month:     month,
+			Spent:     spent,
+			Remaining: b.Amount - spent,
+			Exceeded:  spent > b.Amount,
+		})
+	}
+
+	return statuses, nil
+}
+
+// notifiedIn сообщает, отправлялось ли уже уведомление по бюджету за месяц.
+func notifiedIn(b *Budget, month time.Time) bool {
+	return b.NotifiedMonth != nil && !monthStart(*b.NotifiedMonth).Before(month)
+}
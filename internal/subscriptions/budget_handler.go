@@ -0,0 +1,215 @@
+package subscriptions
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type BudgetHandler struct {
+	logger    *zap.Logger
+	repo      IBudgetRepository
+	evaluator *BudgetEvaluator
+}
+
+func NewBudgetHandler(logger *zap.Logger, repo IBudgetRepository, evaluator *BudgetEvaluator) *BudgetHandler {
+	return &BudgetHandler{
+		logger:    logger,
+		repo:      repo,
+		evaluator: evaluator,
+	}
+}
+
+func (h *BudgetHandler) RegisterRoutes(router *gin.Engine) {
+	api := router.Group("/api/v1")
+	{
+		budgets := api.Group("/users/:user_id/budgets")
+		{
+			budgets.POST("", h.Create)
+			budgets.GET("", h.List)
+			budgets.GET("/status", h.Status)
+			budgets.GET("/:budget_id", h.Get)
+			budgets.PUT("/:budget_id", h.Update)
+			budgets.DELETE("/:budget_id", h.Delete)
+		}
+	}
+}
+
+// Create godoc
+// @Summary Create budget
+// @Description Monthly budget, overall or for a single service when service_name is set. There are no separate categories: a category budget is a budget for a service name
+// @Tags Budgets
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param budget body BudgetRequest true "Budget"
+// @Success 201 {object} Budget
+// @Failure 400,409,500 {object} gin.H
+// @Router /users/{user_id}/budgets [post]
+func (h *BudgetHandler) Create(c *gin.Context) {
+	var req BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget := &Budget{
+		UserID:      c.Param("user_id"),
+		ServiceName: req.ServiceName,
+		Amount:      req.Amount,
+	}
+	if err := budget.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.repo.Create(c.Request.Context(), budget)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, budget)
+	case errors.Is(err, ErrBudgetExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("failed to create budget", zap.Error(err))
+		serverError(c, err, "failed to create budget")
+	}
+}
+
+// List godoc
+// @Summary List user budgets
+// @Tags Budgets
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {array} Budget
+// @Failure 500 {object} gin.H
+// @Router /users/{user_id}/budgets [get]
+func (h *BudgetHandler) List(c *gin.Context) {
+	budgets, err := h.repo.ListByUser(c.Request.Context(), c.Param("user_id"))
+	if err != nil {
+		h.logger.Error("failed to list budgets", zap.Error(err))
+		serverError(c, err, "failed to list budgets")
+		return
+	}
+	c.JSON(http.StatusOK, budgets)
+}
+
+// Get godoc
+// @Summary Get budget by ID
+// @Tags Budgets
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param budget_id path string true "Budget ID"
+// @Success 200 {object} Budget
+// @Failure 404,500 {object} gin.H
+// @Router /users/{user_id}/budgets/{budget_id} [get]
+func (h *BudgetHandler) Get(c *gin.Context) {
+	budget, err := h.repo.GetByID(c.Request.Context(), c.Param("user_id"), c.Param("budget_id"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, budget)
+	case errors.Is(err, ErrBudgetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "budget not found"})
+	default:
+		h.logger.Error("failed to get budget", zap.Error(err))
+		serverError(c, err, "failed to get budget")
+	}
+}
+
+// Update godoc
+// @Summary Update budget
+// @Tags Budgets
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param budget_id path string true "Budget ID"
+// @Param budget body BudgetRequest true "Budget"
+// @Success 200 {object} Budget
+// @Failure 400,404,409,500 {object} gin.H
+// @Router /users/{user_id}/budgets/{budget_id} [put]
+func (h *BudgetHandler) Update(c *gin.Context) {
+	var req BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget := &Budget{
+		ID:          c.Param("budget_id"),
+		UserID:      c.Param("user_id"),
+		ServiceName: req.ServiceName,
+		Amount:      req.Amount,
+	}
+	if err := budget.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.repo.Update(c.Request.Context(), budget)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, budget)
+	case errors.Is(err, ErrBudgetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "budget not found"})
+	case errors.Is(err, ErrBudgetExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("failed to update budget", zap.Error(err))
+		serverError(c, err, "failed to update budget")
+	}
+}
+
+// Delete godoc
+// @Summary Delete budget
+// @Tags Budgets
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param budget_id path string true "Budget ID"
+// @Success 204
+// @Failure 404,500 {object} gin.H
+// @Router /users/{user_id}/budgets/{budget_id} [delete]
+func (h *BudgetHandler) Delete(c *gin.Context) {
+	err := h.repo.Delete(c.Request.Context(), c.Param("user_id"), c.Param("budget_id"))
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, ErrBudgetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "budget not found"})
+	default:
+		h.logger.Error("failed to delete budget", zap.Error(err))
+		serverError(c, err, "failed to delete budget")
+	}
+}
+
+// Status godoc
+// @Summary Compare budgets with projected spend
+// @Tags Budgets
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param month query string false "Month MM-YYYY (defaults to current month)"
+// @Success 200 {array} BudgetStatus
+// @Failure 400,500 {object} gin.H
+// @Router /users/{user_id}/budgets/status [get]
+func (h *BudgetHandler) Status(c *gin.Context) {
+	month := time.Now().UTC()
+	if m := c.Query("month"); m != "" {
+		date, err := time.Parse("01-2006", m)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month format, use MM-YYYY"})
+			return
+		}
+		month = date
+	}
+
+	statuses, err := h.evaluator.Evaluate(c.Request.Context(), c.Param("user_id"), month)
+	if err != nil {
+		h.logger.Error("failed to evaluate budgets", zap.Error(err))
+		serverError(c, err, "failed to evaluate budgets")
+		return
+	}
+	c.JSON(http.StatusOK, statuses)
+}
@@ -0,0 +1,202 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"SubscriptionService/internal/logging"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type IBudgetRepository interface {
+	Create(ctx context.Context, budget *Budget) error
+	GetByID(ctx context.Context, userID, id string) (*Budget, error)
+	Update(ctx context.Context, budget *Budget) error
+	Delete(ctx context.Context, userID, id string) error
+	ListByUser(ctx context.Context, userID string) ([]*Budget, error)
+	List(ctx context.Context) ([]*Budget, error)
+	// SwapNotifiedMonth sets the last notified month to next if it still
+	// equals previous and reports whether it did.
+	SwapNotifiedMonth(ctx context.Context, id string, previous, next *time.Time) (bool, error)
+}
+
+type BudgetRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewBudgetRepository(db *pgxpool.Pool, logger *zap.Logger) *BudgetRepository {
+	return &BudgetRepository{db: db, logger: logger}
+}
+
+// log возвращает логгер запроса из ctx, чтобы ошибки БД содержали request_id
+func (r *BudgetRepository) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, r.logger)
+}
+
+const budgetColumns = `id, user_id, service_name, amount, notified_month, created_at, updated_at`
+
+func (r *BudgetRepository) Create(ctx context.Context, budget *Budget) error {
+	query := `
+		INSERT INTO budgets (user_id, service_name, amount)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRow(ctx, query,
+		budget.UserID,
+		budget.ServiceName,
+		budget.Amount,
+	).Scan(&budget.ID, &budget.CreatedAt, &budget.UpdatedAt)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrBudgetExists
+		}
+		r.log(ctx).Error("failed to create budget",
+			zap.Error(err),
+			zap.String("user", budget.UserID))
+		return fmt.Errorf("failed to create budget: %w", err)
+	}
+
+	return nil
+}
+
+func (r *BudgetRepository) GetByID(ctx context.Context, userID, id string) (*Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets WHERE id = $1 AND user_id = $2`
+
+	rows, err := r.db.Query(ctx, query, id, userID)
+	if err != nil {
+		r.log(ctx).Error("failed to get budget",
+			zap.Error(err),
+			zap.String("id", id))
+		return nil, fmt.Errorf("failed to get budget: %w", err)
+	}
+
+	budget, err := pgx.CollectExactlyOneRow(rows, scanBudget)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrBudgetNotFound
+		}
+		r.log(ctx).Error("failed to get budget",
+			zap.Error(err),
+			zap.String("id", id))
+		return nil, fmt.Errorf("failed to get budget: %w", err)
+	}
+
+	return budget, nil
+}
+
+func (r *BudgetRepository) Update(ctx context.Context, budget *Budget) error {
+	// После изменения бюджета превышение нового лимита снова заслуживает уведомления
+	query := `
+		UPDATE budgets
+		SET service_name = $1, amount = $2, notified_month = NULL, updated_at = NOW()
+		WHERE id = $3 AND user_id = $4
+		RETURNING created_at, updated_at`
+
+	err := r.db.QueryRow(ctx, query,
+		budget.ServiceName,
+		budget.Amount,
+		budget.ID,
+		budget.UserID,
+	).Scan(&budget.CreatedAt, &budget.UpdatedAt)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrBudgetNotFound
+		}
+		if isUniqueViolation(err) {
+			return ErrBudgetExists
+		}
+		r.log(ctx).Error("failed to update budget",
+			zap.Error(err),
+			zap.String("id", budget.ID))
+		return fmt.Errorf("failed to update budget: %w", err)
+	}
+
+	return nil
+}
+
+func (r *BudgetRepository) Delete(ctx context.Context, userID, id string) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM budgets WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		r.log(ctx).Error("failed to delete budget",
+			zap.Error(err),
+			zap.String("id", id))
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrBudgetNotFound
+	}
+
+	return nil
+}
+
+func (r *BudgetRepository) SwapNotifiedMonth(ctx context.Context, id string, previous, next *time.Time) (bool, error) {
+	query := `
+		UPDATE budgets
+		SET notified_month = $3
+		WHERE id = $1 AND notified_month IS NOT DISTINCT FROM $2`
+
+	result, err := r.db.Exec(ctx, query, id, previous, next)
+	if err != nil {
+		r.log(ctx).Error("failed to update budget notification",
+			zap.Error(err),
+			zap.String("id", id))
+		return false, fmt.Errorf("failed to update budget notification: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *BudgetRepository) ListByUser(ctx context.Context, userID string) ([]*Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets WHERE user_id = $1 ORDER BY service_name NULLS FIRST`
+	return r.list(ctx, query, userID)
+}
+
+func (r *BudgetRepository) List(ctx context.Context) ([]*Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets ORDER BY user_id, service_name NULLS FIRST`
+	return r.list(ctx, query)
+}
+
+func (r *BudgetRepository) list(ctx context.Context, query string, args ...any) ([]*Budget, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		r.log(ctx).Error("failed to list budgets", zap.Error(err))
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+
+	budgets, err := pgx.CollectRows(rows, scanBudget)
+	if err != nil {
+		r.log(ctx).Error("failed to scan budgets", zap.Error(err))
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+
+	return budgets, nil
+}
+
+func scanBudget(row pgx.CollectableRow) (*Budget, error) {
+	var b Budget
+	err := row.Scan(
+		&b.ID,
+		&b.UserID,
+		&b.ServiceName,
+		&b.Amount,
+		&b.NotifiedMonth,
+		&b.CreatedAt,
+		&b.UpdatedAt,
+	)
+	return &b, err
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,433 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"SubscriptionService/migrations"
+	"SubscriptionService/pkg/db"
+
+	"go.uber.org/zap"
+)
+
+// memoryBudgetRepository хранит бюджеты в памяти с теми же правилами, что и таблица budgets.
+type memoryBudgetRepository struct {
+	mu      sync.Mutex
+	budgets map[string]*Budget
+}
+
+func newMemoryBudgetRepository() *memoryBudgetRepository {
+	return &memoryBudgetRepository{budgets: make(map[string]*Budget)}
+}
+
+func copyBudget(b *Budget) *Budget {
+	copied := *b
+	if b.ServiceName != nil {
+		name := *b.ServiceName
+		copied.ServiceName = &name
+	}
+	if b.NotifiedMonth != nil {
+		notified := *b.NotifiedMonth
+		copied.NotifiedMonth = &notified
+	}
+	return &copied
+}
+
+// conflicts сообщает, есть ли у пользователя другой бюджет с той же областью.
+func (r *memoryBudgetRepository) conflicts(b *Budget) bool {
+	for _, existing := range r.budgets {
+		if existing.ID != b.ID && existing.UserID == b.UserID && existing.scope() == b.scope() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryBudgetRepository) Create(_ context.Context, budget *Budget) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	budget.ID = newUUID()
+	if r.conflicts(budget) {
+		return ErrBudgetExists
+	}
+	budget.CreatedAt = time.Now().UTC()
+	budget.UpdatedAt = budget.CreatedAt
+	r.budgets[budget.ID] = copyBudget(budget)
+	return nil
+}
+
+func (r *memoryBudgetRepository) GetByID(_ context.Context, userID, id string) (*Budget, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.budgets[id]
+	if !ok || b.UserID != userID {
+		return nil, ErrBudgetNotFound
+	}
+	return copyBudget(b), nil
+}
+
+func (r *memoryBudgetRepository) Update(_ context.Context, budget *Budget) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.budgets[budget.ID]
+	if !ok || stored.UserID != budget.UserID {
+		return ErrBudgetNotFound
+	}
+	if r.conflicts(budget) {
+		return ErrBudgetExists
+	}
+	budget.CreatedAt = stored.CreatedAt
+	budget.UpdatedAt = time.Now().UTC()
+	budget.NotifiedMonth = nil
+	r.budgets[budget.ID] = copyBudget(budget)
+	return nil
+}
+
+func (r *memoryBudgetRepository) Delete(_ context.Context, userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if b, ok := r.budgets[id]; !ok || b.UserID != userID {
+		return ErrBudgetNotFound
+	}
+	delete(r.budgets, id)
+	return nil
+}
+
+func (r *memoryBudgetRepository) ListByUser(_ context.Context, userID string) ([]*Budget, error) {
+	return r.list(func(b *Budget) bool { return b.UserID == userID }), nil
+}
+
+func (r *memoryBudgetRepository) List(context.Context) ([]*Budget, error) {
+	return r.list(func(*Budget) bool { return true }), nil
+}
+
+// list возвращает бюджеты в порядке запросов BudgetRepository: по пользователю,
+// общий бюджет перед бюджетами по сервисам.
+func (r *memoryBudgetRepository) list(match func(*Budget) bool) []*Budget {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	budgets := []*Budget{}
+	for _, b := range r.budgets {
+		if match(b) {
+			budgets = append(budgets, copyBudget(b))
+		}
+	}
+	sort.Slice(budgets, func(i, j int) bool {
+		a, b := budgets[i], budgets[j]
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		if a.ServiceName == nil || b.ServiceName == nil {
+			return a.ServiceName == nil && b.ServiceName != nil
+		}
+		return *a.ServiceName < *b.ServiceName
+	})
+	return budgets
+}
+
+func (r *memoryBudgetRepository) SwapNotifiedMonth(_ context.Context, id string, previous, next *time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.budgets[id]
+	if !ok {
+		return false, nil
+	}
+	sameMonth := func(a, b *time.Time) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && a.Equal(*b))
+	}
+	if !sameMonth(b.NotifiedMonth, previous) {
+		return false, nil
+	}
+	b.NotifiedMonth = nil
+	if next != nil {
+		notified := *next
+		b.NotifiedMonth = &notified
+	}
+	return true, nil
+}
+
+func TestMemoryBudgetRepository(t *testing.T) {
+	testBudgetRepository(t, newMemoryBudgetRepository())
+}
+
+// TestPostgresBudgetRepository проверяет BudgetRepository на базе из TEST_DB_URL.
+func TestPostgresBudgetRepository(t *testing.T) {
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	ctx := context.Background()
+	logger := zap.NewNop()
+	pool, err := db.NewPGXPool(ctx, db.PoolConfig{URL: url, MaxConns: 4}, logger)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err := db.NewMigrator(pool, migrations.FS, logger).Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `TRUNCATE budgets`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	testBudgetRepository(t, NewBudgetRepository(pool, logger))
+}
+
+// testBudgetRepository проверяет поведение, общее для реализаций IBudgetRepository.
+func testBudgetRepository(t *testing.T, repo IBudgetRepository) {
+	ctx := context.Background()
+	netflix, spotify := "Netflix", "Spotify"
+
+	add := func(b *Budget) *Budget {
+		t.Helper()
+		if err := repo.Create(ctx, b); err != nil {
+			t.Fatalf("create budget: %v", err)
+		}
+		if b.ID == "" || b.CreatedAt.IsZero() {
+			t.Fatalf("created budget %+v", b)
+		}
+		return b
+	}
+
+	overall := add(&Budget{UserID: testUsers[0], Amount: 1000})
+	byService := add(&Budget{UserID: testUsers[0], ServiceName: &spotify, Amount: 300})
+	add(&Budget{UserID: testUsers[0], ServiceName: &netflix, Amount: 500})
+	add(&Budget{UserID: testUsers[1], Amount: 200})
+
+	// На пользователя один общий бюджет и один бюджет на сервис
+	wantErr(t, repo.Create(ctx, &Budget{UserID: testUsers[0], Amount: 10}), ErrBudgetExists)
+	wantErr(t, repo.Create(ctx, &Budget{UserID: testUsers[0], ServiceName: &spotify, Amount: 10}), ErrBudgetExists)
+
+	budgets, err := repo.ListByUser(ctx, testUsers[0])
+	if err != nil {
+		t.Fatalf("list budgets: %v", err)
+	}
+	if len(budgets) != 3 || budgets[0].ServiceName != nil || *budgets[1].ServiceName != netflix || *budgets[2].ServiceName != spotify {
+		t.Fatalf("budgets of the user should start with the overall one, then by service: %+v", budgets)
+	}
+	if all, err := repo.List(ctx); err != nil || len(all) != 4 {
+		t.Fatalf("all budgets %d, %v", len(all), err)
+	}
+
+	got, err := repo.GetByID(ctx, testUsers[0], byService.ID)
+	if err != nil || got.Amount != 300 || *got.ServiceName != spotify {
+		t.Fatalf("get budget %+v, %v", got, err)
+	}
+	// Чужой бюджет не виден
+	wantErr(t, func() error { _, err := repo.GetByID(ctx, testUsers[1], byService.ID); return err }(), ErrBudgetNotFound)
+	wantErr(t, func() error { _, err := repo.GetByID(ctx, testUsers[0], missingID); return err }(), ErrBudgetNotFound)
+
+	// Месяц уведомления меняется, только если не изменился с прочитанного значения
+	june := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+	july := june.AddDate(0, 1, 0)
+	for _, tt := range []struct {
+		previous, next *time.Time
+		want           bool
+	}{
+		{nil, &june, true},
+		{nil, &june, false},
+		{&june, &july, true},
+		{&june, &july, false},
+		{&july, nil, true},
+		{nil, &july, true},
+	} {
+		if ok, err := repo.SwapNotifiedMonth(ctx, overall.ID, tt.previous, tt.next); err != nil || ok != tt.want {
+			t.Fatalf("swap %v -> %v: %v, %v, want %v", tt.previous, tt.next, ok, err, tt.want)
+		}
+	}
+	if got, err := repo.GetByID(ctx, testUsers[0], overall.ID); err != nil || got.NotifiedMonth == nil || !got.NotifiedMonth.Equal(july) {
+		t.Fatalf("notified month %+v, %v", got, err)
+	}
+
+	// Изменение бюджета сбрасывает месяц уведомления
+	overall.Amount = 1200
+	if err := repo.Update(ctx, overall); err != nil {
+		t.Fatalf("update budget: %v", err)
+	}
+	if got, err := repo.GetByID(ctx, testUsers[0], overall.ID); err != nil || got.Amount != 1200 || got.NotifiedMonth != nil {
+		t.Fatalf("updated budget %+v, %v", got, err)
+	}
+	wantErr(t, repo.Update(ctx, &Budget{ID: byService.ID, UserID: testUsers[0], ServiceName: &netflix, Amount: 10}), ErrBudgetExists)
+	wantErr(t, repo.Update(ctx, &Budget{ID: byService.ID, UserID: testUsers[1], Amount: 10}), ErrBudgetNotFound)
+	wantErr(t, repo.Update(ctx, &Budget{ID: missingID, UserID: testUsers[0], Amount: 10}), ErrBudgetNotFound)
+
+	if err := repo.Delete(ctx, testUsers[0], byService.ID); err != nil {
+		t.Fatalf("delete budget: %v", err)
+	}
+	wantErr(t, repo.Delete(ctx, testUsers[0], byService.ID), ErrBudgetNotFound)
+	if budgets, err := repo.ListByUser(ctx, testUsers[0]); err != nil || len(budgets) != 2 {
+		t.Fatalf("budgets after delete %d, %v", len(budgets), err)
+	}
+}
+
+// periodCountingRepository считает запросы подписок за период.
+type periodCountingRepository struct {
+	*MemoryRepository
+	calls int
+}
+
+func (r *periodCountingRepository) ListForPeriod(ctx context.Context, filters map[string]interface{}) ([]*Subscription, error) {
+	r.calls++
+	return r.MemoryRepository.ListForPeriod(ctx, filters)
+}
+
+func (r *periodCountingRepository) CalculateMonthlyCost(context.Context, map[string]interface{}) (int, error) {
+	return 0, errors.New("budgets must be evaluated in one batch")
+}
+
+// recordingNotifier запоминает события и может отказывать в доставке.
+type recordingNotifier struct {
+	events []Event
+	err    error
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, event Event) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.events = append(n.events, event)
+	return nil
+}
+
+func TestBudgetEvaluator(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, time.June, 17, 12, 0, 0, 0, time.UTC)
+	june := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+	owner, member := testUsers[0], testUsers[1]
+	netflix := "Netflix"
+
+	subs := &periodCountingRepository{MemoryRepository: NewMemoryRepository()}
+	// Участник платит 100 из 500 за Netflix владельца
+	shared := create(t, subs, "Netflix", 500, owner, june.AddDate(0, -5, 0), nil)
+	if err := subs.AddMember(ctx, shared.ID, &Member{UserID: member, FixedAmount: intPtr(100)}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	create(t, subs, "Spotify", 300, owner, june.AddDate(0, -5, 0), nil)
+	create(t, subs, "Spotify", 200, member, june, nil)
+	// Закончилась до проверяемого месяца
+	ended := june.AddDate(0, -1, 0)
+	create(t, subs, "Netflix", 900, member, june.AddDate(0, -3, 0), &ended)
+
+	budgets := newMemoryBudgetRepository()
+	add := func(userID string, serviceName *string, amount int) *Budget {
+		t.Helper()
+		b := &Budget{UserID: userID, ServiceName: serviceName, Amount: amount}
+		if err := budgets.Create(ctx, b); err != nil {
+			t.Fatalf("create budget: %v", err)
+		}
+		return b
+	}
+	ownerOverall := add(owner, nil, 1000)
+	ownerNetflix := add(owner, &netflix, 300)
+	memberOverall := add(member, nil, 250)
+	memberNetflix := add(member, &netflix, 100)
+
+	notifier := &recordingNotifier{}
+	newEvaluator := func() *BudgetEvaluator {
+		e := NewBudgetEvaluator(budgets, subs, notifier, zap.NewNop())
+		e.now = func() time.Time { return now }
+		return e
+	}
+	evaluator := newEvaluator()
+
+	t.Run("evaluate", func(t *testing.T) {
+		want := map[string]BudgetStatus{
+			ownerOverall.ID:  {Spent: 700, Remaining: 300},
+			ownerNetflix.ID:  {Spent: 400, Remaining: -100, Exceeded: true},
+			memberOverall.ID: {Spent: 300, Remaining: -50, Exceeded: true},
+			memberNetflix.ID: {Spent: 100, Remaining: 0},
+		}
+		for _, userID := range []string{owner, member} {
+			statuses, err := evaluator.Evaluate(ctx, userID, now)
+			if err != nil {
+				t.Fatalf("evaluate: %v", err)
+			}
+			if len(statuses) != 2 {
+				t.Fatalf("%d statuses for %s, want 2", len(statuses), userID)
+			}
+			for _, s := range statuses {
+				w := want[s.Budget.ID]
+				if !s.Month.Equal(june) || s.Spent != w.Spent || s.Remaining != w.Remaining || s.Exceeded != w.Exceeded {
+					t.Errorf("budget %s: %+v, want %+v", s.Budget.ID, s, w)
+				}
+			}
+		}
+	})
+
+	t.Run("one query per scope", func(t *testing.T) {
+		subs.calls = 0
+		all, err := budgets.List(ctx)
+		if err != nil {
+			t.Fatalf("list budgets: %v", err)
+		}
+		if _, err := evaluator.evaluate(ctx, all, now); err != nil {
+			t.Fatalf("evaluate: %v", err)
+		}
+		// Общие бюджеты и бюджеты на Netflix
+		if subs.calls != 2 {
+			t.Fatalf("%d period queries for %d budgets, want 2", subs.calls, len(all))
+		}
+	})
+
+	t.Run("notifies once a month", func(t *testing.T) {
+		// Отказ доставки не отмечает бюджет, следующая проверка повторит попытку
+		notifier.err = errors.New("smtp is down")
+		if err := evaluator.CheckAll(ctx); err != nil {
+			t.Fatalf("check all: %v", err)
+		}
+		for _, b := range []*Budget{ownerNetflix, memberOverall} {
+			if got, err := budgets.GetByID(ctx, b.UserID, b.ID); err != nil || got.NotifiedMonth != nil {
+				t.Fatalf("budget %+v marked as notified after a failed delivery, %v", got, err)
+			}
+		}
+
+		notifier.err = nil
+		if err := evaluator.CheckAll(ctx); err != nil {
+			t.Fatalf("check all: %v", err)
+		}
+		notified := make(map[string]bool)
+		for _, event := range notifier.events {
+			status := event.Payload.(*BudgetStatus)
+			if event.Type != EventBudgetExceeded || event.UserID != status.Budget.UserID || !event.OccurredAt.Equal(now) {
+				t.Fatalf("event %+v", event)
+			}
+			notified[status.Budget.ID] = true
+		}
+		if len(notifier.events) != 2 || !notified[ownerNetflix.ID] || !notified[memberOverall.ID] {
+			t.Fatalf("events %+v", notifier.events)
+		}
+
+		// Повторная проверка и новый экземпляр после перезапуска не уведомляют снова
+		if err := evaluator.CheckAll(ctx); err != nil {
+			t.Fatalf("check all: %v", err)
+		}
+		if err := newEvaluator().CheckAll(ctx); err != nil {
+			t.Fatalf("check all: %v", err)
+		}
+		if len(notifier.events) != 2 {
+			t.Fatalf("%d events after repeated checks, want 2", len(notifier.events))
+		}
+
+		// В следующем месяце превышение снова заслуживает уведомления
+		now = now.AddDate(0, 1, 0)
+		if err := evaluator.CheckAll(ctx); err != nil {
+			t.Fatalf("check all: %v", err)
+		}
+		if len(notifier.events) != 4 {
+			t.Fatalf("%d events in the next month, want 4", len(notifier.events))
+		}
+	})
+}
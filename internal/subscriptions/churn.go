@@ -0,0 +1,48 @@
+package subscriptions
+
+// ChurnStat is the number of cancellations of a service for one reason.
+type ChurnStat struct {
+	ServiceName string             `json:"service_name"`
+	Reason      CancellationReason `json:"reason"`
+	Count       int                `json:"count"`
+}
+
+// ServiceChurn is the cancellation breakdown of a single service.
+type ServiceChurn struct {
+	ServiceName string                     `json:"service_name"`
+	Total       int                        `json:"total"`
+	Reasons     map[CancellationReason]int `json:"reasons"`
+}
+
+// ChurnReport is the cancellation statistics by service and reason.
+type ChurnReport struct {
+	Total     int                        `json:"total"`
+	ByReason  map[CancellationReason]int `json:"by_reason"`
+	ByService []*ServiceChurn            `json:"by_service"`
+}
+
+// BuildChurnReport сводит статистику отмен; stats должны быть отсортированы по сервису.
+func BuildChurnReport(stats []*ChurnStat) *ChurnReport {
+	report := &ChurnReport{
+		ByReason:  make(map[CancellationReason]int),
+		ByService: []*ServiceChurn{},
+	}
+
+	var current *ServiceChurn
+	for _, stat := range stats {
+		if current == nil || current.ServiceName != stat.ServiceName {
+			current = &ServiceChurn{
+				ServiceName: stat.ServiceName,
+				Reasons:     make(map[CancellationReason]int),
+			}
+			report.ByService = append(report.ByService, current)
+		}
+
+		current.Reasons[stat.Reason] += stat.Count
+		current.Total += stat.Count
+		report.ByReason[stat.Reason] += stat.Count
+		report.Total += stat.Count
+	}
+
+	return report
+}
@@ -0,0 +1,148 @@
+package subscriptions
+
+import "time"
+
+// monthStart нормализует дату до первого числа месяца (UTC).
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ActiveIn сообщает, действует ли подписка в указанном месяце.
+func (s *Subscription) ActiveIn(month time.Time) bool {
+	month = monthStart(month)
+	if month.Before(monthStart(s.StartDate)) {
+		return false
+	}
+	if s.EndDate != nil && month.After(monthStart(*s.EndDate)) {
+		return false
+	}
+	return true
+}
+
+// PriceAt возвращает цену, действующую в указанном месяце.
+func (s *Subscription) PriceAt(month time.Time) int {
+	month = monthStart(month)
+	price := s.Price
+	for _, pc := range s.PriceChanges {
+		if pc.EffectiveFrom.After(month) {
+			break
+		}
+		price = pc.Price
+	}
+	return price
+}
+
+// DiscountedPriceAt возвращает цену месяца с учётом действующей скидки.
+func (s *Subscription) DiscountedPriceAt(month time.Time) int {
+	price := s.PriceAt(month)
+	for _, d := range s.Discounts {
+		if d.Covers(month) {
+			return d.Apply(price)
+		}
+	}
+	return price
+}
+
+// CostForMonth возвращает сумму списания за месяц (0, если подписка не активна
+// или приостановлена).
+func (s *Subscription) CostForMonth(month time.Time) int {
+	if !s.ActiveIn(month) || s.PausedIn(month) {
+		return 0
+	}
+	return s.DiscountedPriceAt(month)
+}
+
+// PeriodCost суммирует стоимость подписок помесячно за период
+// start_date_from..start_date_to из фильтров с учётом цены, действовавшей в каждом
+// месяце. Без start_date_from период начинается с начала подписки, без
+// start_date_to заканчивается месяцем now. С фильтром user_id считается только
+// доля пользователя в совместных подписках.
+func PeriodCost(subs []*Subscription, filters map[string]interface{}, now time.Time) int {
+	from, _ := filters["start_date_from"].(time.Time)
+	to, ok := filters["start_date_to"].(time.Time)
+	if !ok {
+		to = now
+	}
+
+	userID, byUser := filters["user_id"].(string)
+
+	total := 0
+	for _, sub := range subs {
+		if byUser {
+			total += sub.UserCostBetween(userID, from, to)
+		} else {
+			total += sub.CostBetween(from, to)
+		}
+	}
+	return total
+}
+
+// CostBetween суммирует стоимость подписки помесячно за период [from, to] включительно.
+// Нулевое значение from означает начало подписки.
+func (s *Subscription) CostBetween(from, to time.Time) int {
+	total := 0
+	from, to = s.clampPeriod(from, to)
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		total += s.CostForMonth(month)
+	}
+	return total
+}
+
+// UserCostBetween суммирует долю пользователя в стоимости подписки за период [from, to].
+func (s *Subscription) UserCostBetween(userID string, from, to time.Time) int {
+	total := 0
+	from, to = s.clampPeriod(from, to)
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		total += s.SharesForMonth(month)[userID]
+	}
+	return total
+}
+
+// SharesForMonth распределяет стоимость месяца между участниками подписки.
+// Сначала списываются фиксированные суммы, остаток делится по процентам,
+// а всё, что не покрыто участниками (включая округление), оплачивает владелец.
+func (s *Subscription) SharesForMonth(month time.Time) map[string]int {
+	shares := make(map[string]int, len(s.Members)+1)
+
+	remaining := s.CostForMonth(month)
+	if remaining == 0 {
+		return shares
+	}
+
+	for _, m := range s.Members {
+		if m.FixedAmount != nil {
+			amount := min(*m.FixedAmount, remaining)
+			shares[m.UserID] += amount
+			remaining -= amount
+		}
+	}
+
+	base := remaining
+	for _, m := range s.Members {
+		if m.SharePercent != nil {
+			amount := base * *m.SharePercent / 100
+			shares[m.UserID] += amount
+			remaining -= amount
+		}
+	}
+
+	shares[s.UserID] += remaining
+	return shares
+}
+
+// clampPeriod нормализует период до месяцев и ограничивает его сроком действия подписки.
+func (s *Subscription) clampPeriod(from, to time.Time) (time.Time, time.Time) {
+	from = monthStart(from)
+	to = monthStart(to)
+
+	if start := monthStart(s.StartDate); from.Before(start) {
+		from = start
+	}
+	if s.EndDate != nil {
+		if end := monthStart(*s.EndDate); to.After(end) {
+			to = end
+		}
+	}
+
+	return from, to
+}
@@ -0,0 +1,145 @@
+package subscriptions
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EventsChannel is the PostgreSQL NOTIFY channel the subscription triggers
+// publish changes to.
+const EventsChannel = "subscription_events"
+
+const (
+	EventSubscriptionCreated = "subscription.created"
+	EventSubscriptionUpdated = "subscription.updated"
+	EventSubscriptionDeleted = "subscription.deleted"
+)
+
+// SubscriptionChange is the payload of subscription change events. Changes of
+// prices, pauses, discounts and members are sent as updates of the subscription.
+type SubscriptionChange struct {
+	SubscriptionID string `json:"subscription_id"`
+}
+
+// changeNotification — полезная нагрузка NOTIFY из триггеров миграции 000011
+type changeNotification struct {
+	Type           string    `json:"type"`
+	SubscriptionID string    `json:"subscription_id"`
+	UserID         string    `json:"user_id"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// EventHub fans subscription change events out to the SSE clients of one
+// instance. Events come from PostgreSQL NOTIFY (see db.Listener), so a client
+// sees changes made through any instance.
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	buffer      int
+	closed      bool
+	logger      *zap.Logger
+}
+
+type eventSubscriber struct {
+	userID string
+	events chan Event
+}
+
+// NewEventHub creates a hub. buffer is the number of events a subscriber may
+// fall behind before it is disconnected.
+func NewEventHub(buffer int, logger *zap.Logger) *EventHub {
+	return &EventHub{
+		subscribers: make(map[*eventSubscriber]struct{}),
+		buffer:      buffer,
+		logger:      logger,
+	}
+}
+
+// Subscribe returns the events of the subscriptions owned by userID. The
+// channel is closed when the subscriber falls behind, on Reset and on Close;
+// the client is expected to reconnect and reload what it shows. cancel must
+// be called when the subscriber is done.
+func (h *EventHub) Subscribe(userID string) (<-chan Event, func()) {
+	s := &eventSubscriber{userID: userID, events: make(chan Event, h.buffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(s.events)
+		return s.events, func() {}
+	}
+	h.subscribers[s] = struct{}{}
+
+	return s.events, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.removeLocked(s)
+	}
+}
+
+// Publish delivers event to the subscribers of its user without blocking.
+func (h *EventHub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subscribers {
+		if !strings.EqualFold(s.userID, event.UserID) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			// Медленный клиент отключается, а не задерживает остальных
+			h.logger.Warn("dropping slow event subscriber", zap.String("user_id", s.userID))
+			h.removeLocked(s)
+		}
+	}
+}
+
+// HandleNotification publishes a change sent by the database triggers.
+func (h *EventHub) HandleNotification(payload string) {
+	var n changeNotification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		h.logger.Error("invalid subscription event notification", zap.String("payload", payload), zap.Error(err))
+		return
+	}
+	h.Publish(Event{
+		Type:       n.Type,
+		UserID:     n.UserID,
+		Payload:    SubscriptionChange{SubscriptionID: n.SubscriptionID},
+		OccurredAt: n.OccurredAt.UTC(),
+	})
+}
+
+// Reset disconnects all subscribers. It is called when the database
+// connection was lost and events may have been missed.
+func (h *EventHub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		h.removeLocked(s)
+	}
+}
+
+// Close disconnects all subscribers and rejects new ones, so that open event
+// streams do not hold up the HTTP server shutdown.
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subscribers {
+		h.removeLocked(s)
+	}
+}
+
+// removeLocked закрывает канал подписчика ровно один раз
+func (h *EventHub) removeLocked(s *eventSubscriber) {
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.events)
+	}
+}
@@ -0,0 +1,173 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+const MaxForecastMonths = 60
+
+var ErrInvalidForecastMonths = errors.New("months must be between 1 and 60")
+
+// Clock returns the current time; tests substitute a fixed one.
+type Clock func() time.Time
+
+// ForecastMonth is the projected spend for a single month.
+type ForecastMonth struct {
+	Month    time.Time      `json:"month"`
+	Total    int            `json:"total"`
+	Services map[string]int `json:"services"`
+}
+
+// ServiceForecast is the projected spend series for one service.
+type ServiceForecast struct {
+	ServiceName string `json:"service_name"`
+	Amounts     []int  `json:"amounts"`
+	Total       int    `json:"total"`
+}
+
+// ForecastReport is the projected spend for the next months, starting with
+// the current one.
+type ForecastReport struct {
+	From     time.Time          `json:"from"`
+	Months   []*ForecastMonth   `json:"months"`
+	Services []*ServiceForecast `json:"services"`
+	Total    int                `json:"total"`
+}
+
+// Forecaster прогнозирует расходы по действующим подпискам.
+type Forecaster struct {
+	repo ISubscriptionRepository
+	now  Clock
+}
+
+func NewForecaster(repo ISubscriptionRepository, clock Clock) *Forecaster {
+	return &Forecaster{repo: repo, now: clock}
+}
+
+// Forecast прогнозирует расходы на months месяцев вперёд, начиная с текущего.
+// Поддерживаются фильтры user_id и service_name.
+func (f *Forecaster) Forecast(ctx context.Context, filters map[string]interface{}, months int) (*ForecastReport, error) {
+	if months < 1 || months > MaxForecastMonths {
+		return nil, ErrInvalidForecastMonths
+	}
+
+	from := monthStart(f.now())
+	to := from.AddDate(0, months-1, 0)
+
+	periodFilters := map[string]interface{}{
+		"start_date_from": from,
+		"start_date_to":   to,
+	}
+	for _, key := range []string{"user_id", "service_name"} {
+		if v, ok := filters[key]; ok {
+			periodFilters[key] = v
+		}
+	}
+
+	spend, ok, err := f.repo.MonthlySpend(ctx, periodFilters)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return BuildForecastFromSpend(spend, from, months), nil
+	}
+
+	subs, err := f.repo.ListForPeriod(ctx, periodFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, _ := filters["user_id"].(string)
+	return BuildForecast(subs, from, months, userID), nil
+}
+
+// BuildForecast строит прогноз по подпискам помесячно: учитываются даты окончания,
+// запланированные изменения цен, паузы и скидки. Пробный период — скидка вида
+// DiscountTrial, поэтому месяцы после него прогнозируются по обычной цене.
+// Если задан userID, считается только доля пользователя в совместных подписках.
+func BuildForecast(subs []*Subscription, from time.Time, months int, userID string) *ForecastReport {
+	b := newForecastBuilder(from, months)
+	for i := range months {
+		month := b.report.From.AddDate(0, i, 0)
+		for _, sub := range subs {
+			if userID != "" {
+				b.add(i, sub.ServiceName, sub.SharesForMonth(month)[userID])
+			} else {
+				b.add(i, sub.ServiceName, sub.CostForMonth(month))
+			}
+		}
+	}
+	return b.build()
+}
+
+// BuildForecastFromSpend строит тот же прогноз по суммам из агрегата monthly_spend.
+func BuildForecastFromSpend(spend []*MonthlySpend, from time.Time, months int) *ForecastReport {
+	b := newForecastBuilder(from, months)
+	for _, m := range spend {
+		i := monthsBetween(b.report.From, m.Month)
+		if i < 0 || i >= months {
+			continue
+		}
+		b.add(i, m.ServiceName, m.Amount)
+	}
+	return b.build()
+}
+
+// forecastBuilder накапливает суммы по месяцам и сервисам.
+type forecastBuilder struct {
+	report    *ForecastReport
+	byService map[string]*ServiceForecast
+	months    int
+}
+
+func newForecastBuilder(from time.Time, months int) *forecastBuilder {
+	from = monthStart(from)
+	b := &forecastBuilder{
+		report: &ForecastReport{
+			From:     from,
+			Months:   make([]*ForecastMonth, months),
+			Services: []*ServiceForecast{},
+		},
+		byService: make(map[string]*ServiceForecast),
+		months:    months,
+	}
+	for i := range months {
+		b.report.Months[i] = &ForecastMonth{Month: from.AddDate(0, i, 0), Services: make(map[string]int)}
+	}
+	return b
+}
+
+func (b *forecastBuilder) add(i int, service string, amount int) {
+	if amount == 0 {
+		return
+	}
+
+	fm := b.report.Months[i]
+	fm.Services[service] += amount
+	fm.Total += amount
+	b.report.Total += amount
+
+	series, ok := b.byService[service]
+	if !ok {
+		series = &ServiceForecast{ServiceName: service, Amounts: make([]int, b.months)}
+		b.byService[service] = series
+		b.report.Services = append(b.report.Services, series)
+	}
+	series.Amounts[i] += amount
+	series.Total += amount
+}
+
+func (b *forecastBuilder) build() *ForecastReport {
+	sort.Slice(b.report.Services, func(i, j int) bool {
+		return b.report.Services[i].ServiceName < b.report.Services[j].ServiceName
+	})
+	return b.report
+}
+
+// monthsBetween возвращает число месяцев от from до month.
+func monthsBetween(from, month time.Time) int {
+	return (month.Year()-from.Year())*12 + int(month.Month()-from.Month())
+}
@@ -0,0 +1,128 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// rollupRepository отвечает на MonthlySpend так, как ответил бы готовый агрегат
+// monthly_spend, и запрещает расчёт по подпискам.
+type rollupRepository struct {
+	*MemoryRepository
+}
+
+func (r rollupRepository) MonthlySpend(ctx context.Context, filters map[string]interface{}) ([]*MonthlySpend, bool, error) {
+	subs, err := r.MemoryRepository.ListForPeriod(ctx, filters)
+	if err != nil {
+		return nil, false, err
+	}
+	from, to := filters["start_date_from"].(time.Time), filters["start_date_to"].(time.Time)
+	userID, _ := filters["user_id"].(string)
+
+	type key struct {
+		service string
+		month   time.Time
+	}
+	sums := make(map[key]*MonthlySpend)
+	var spend []*MonthlySpend
+	for _, sub := range subs {
+		for _, row := range sub.SpendRows(to) {
+			if row.Month.Before(from) || (userID != "" && row.UserID != userID) {
+				continue
+			}
+			k := key{row.ServiceName, row.Month}
+			if _, ok := sums[k]; !ok {
+				sums[k] = &MonthlySpend{ServiceName: row.ServiceName, Month: row.Month}
+				spend = append(spend, sums[k])
+			}
+			sums[k].Amount += row.Amount
+		}
+	}
+	return spend, true, nil
+}
+
+func (r rollupRepository) ListForPeriod(context.Context, map[string]interface{}) ([]*Subscription, error) {
+	return nil, errors.New("forecast must be built from the rollup")
+}
+
+func TestForecast(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, time.March, 17, 9, 30, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	month := func(m time.Month) time.Time { return time.Date(2025, m, 1, 0, 0, 0, 0, time.UTC) }
+
+	repo := NewMemoryRepository()
+	// Заканчивается внутри горизонта прогноза
+	netflixEnd := month(time.June)
+	create(t, repo, "Netflix", 500, testUsers[0], month(time.January), &netflixEnd)
+	// Цена меняется внутри горизонта прогноза
+	spotify := create(t, repo, "Spotify", 300, testUsers[0], month(time.January).AddDate(-1, 0, 0), nil)
+	if err := repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: spotify.ID, Price: 400, EffectiveFrom: month(time.May)}); err != nil {
+		t.Fatalf("add price change: %v", err)
+	}
+	// Пробный период февраль–апрель, с мая подписка платная
+	kinopoisk := create(t, repo, "Kinopoisk", 200, testUsers[0], month(time.February), nil)
+	if err := repo.AddDiscount(ctx, kinopoisk.ID, &Discount{Kind: DiscountTrial, StartDate: month(time.February), DurationPeriods: 3}); err != nil {
+		t.Fatalf("add trial: %v", err)
+	}
+	wantErr(t, repo.AddDiscount(ctx, spotify.ID, &Discount{Kind: DiscountTrial, StartDate: month(time.March), DurationPeriods: 1}), ErrTrialNotAtStart)
+	wantErr(t, repo.AddDiscount(ctx, kinopoisk.ID, &Discount{Kind: DiscountTrial, Value: 10, StartDate: month(time.February), DurationPeriods: 1}), ErrInvalidDiscount)
+	// Начинается внутри горизонта и принадлежит другому пользователю
+	create(t, repo, "Netflix", 700, testUsers[1], month(time.April), nil)
+	// Закончилась до начала прогноза
+	oldEnd := month(time.February)
+	create(t, repo, "Yandex Plus", 250, testUsers[0], month(time.January), &oldEnd)
+
+	wantMonths := []int{800, 800, 1100, 1100, 600, 600}
+	wantServices := []*ServiceForecast{
+		{ServiceName: "Kinopoisk", Amounts: []int{0, 0, 200, 200, 200, 200}, Total: 800},
+		{ServiceName: "Netflix", Amounts: []int{500, 500, 500, 500, 0, 0}, Total: 2000},
+		{ServiceName: "Spotify", Amounts: []int{300, 300, 400, 400, 400, 400}, Total: 2200},
+	}
+
+	for _, tc := range []struct {
+		name string
+		repo ISubscriptionRepository
+	}{
+		{"subscriptions", repo},
+		{"monthly spend rollup", rollupRepository{repo}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			forecaster := NewForecaster(tc.repo, clock)
+
+			report, err := forecaster.Forecast(ctx, map[string]interface{}{"user_id": testUsers[0]}, 6)
+			if err != nil {
+				t.Fatalf("forecast: %v", err)
+			}
+			if !report.From.Equal(month(time.March)) || len(report.Months) != len(wantMonths) || report.Total != 5000 {
+				t.Fatalf("from %v, %d months, total %d", report.From, len(report.Months), report.Total)
+			}
+			for i, fm := range report.Months {
+				if !fm.Month.Equal(month(time.March).AddDate(0, i, 0)) || fm.Total != wantMonths[i] {
+					t.Errorf("month %d: %v total %d, want %d", i, fm.Month, fm.Total, wantMonths[i])
+				}
+			}
+			if !reflect.DeepEqual(report.Services, wantServices) {
+				t.Errorf("services %+v %+v %+v, want %+v %+v %+v", report.Services[0], report.Services[1], report.Services[2],
+					wantServices[0], wantServices[1], wantServices[2])
+			}
+
+			all, err := forecaster.Forecast(ctx, nil, 6)
+			if err != nil {
+				t.Fatalf("forecast: %v", err)
+			}
+			if all.Total != 5000+5*700 || all.Months[0].Services["Netflix"] != 500 || all.Months[1].Services["Netflix"] != 1200 {
+				t.Errorf("total %d, Netflix %v", all.Total, all.Months[1].Services)
+			}
+		})
+	}
+
+	for _, months := range []int{0, MaxForecastMonths + 1} {
+		if _, err := NewForecaster(repo, clock).Forecast(ctx, nil, months); !errors.Is(err, ErrInvalidForecastMonths) {
+			t.Errorf("months %d: got %v, want %v", months, err, ErrInvalidForecastMonths)
+		}
+	}
+}
@@ -1,22 +1,38 @@
 package subscriptions
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
+	"SubscriptionService/internal/config"
+
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// MIMENDJSON is the format of the streamed subscription list: one JSON object per line.
+const MIMENDJSON = "application/x-ndjson"
+
 type SubscriptionHandler struct {
 	logger *zap.Logger
 	repo   ISubscriptionRepository
+	events *EventHub
+	// streamWriteTimeout ограничивает запись каждой порции потокового ответа
+	streamWriteTimeout time.Duration
+	heartbeat          time.Duration
 }
 
-func NewSubscriptionHandler(logger *zap.Logger, repo ISubscriptionRepository) *SubscriptionHandler {
+func NewSubscriptionHandler(logger *zap.Logger, repo ISubscriptionRepository, events *EventHub, cfg config.HTTPConfig) *SubscriptionHandler {
 	return &SubscriptionHandler{
-		logger: logger,
-		repo:   repo,
+		logger:             logger,
+		repo:               repo,
+		events:             events,
+		streamWriteTimeout: cfg.StreamWriteTimeout,
+		heartbeat:          cfg.SSEHeartbeat,
 	}
 }
 
@@ -31,6 +47,23 @@ func (h *SubscriptionHandler) RegisterRoutes(router *gin.Engine) {
 			subs.PUT("/:id", h.Update)
 			subs.DELETE("/:id", h.Delete)
 			subs.GET("/cost", h.CalculateCost)
+			subs.GET("/events", h.Events)
+			subs.GET("/:id/prices", h.ListPrices)
+			subs.POST("/:id/prices", h.SchedulePriceChange)
+			subs.POST("/:id/pause", h.Pause)
+			subs.POST("/:id/resume", h.Resume)
+			subs.GET("/:id/discounts", h.ListDiscounts)
+			subs.POST("/:id/discounts", h.AddDiscount)
+			subs.DELETE("/:id/discounts/:discount_id", h.RemoveDiscount)
+			subs.GET("/:id/members", h.ListMembers)
+			subs.POST("/:id/members", h.AddMember)
+			subs.DELETE("/:id/members/:user_id", h.RemoveMember)
+			subs.POST("/:id/cancel", h.Cancel)
+		}
+
+		users := api.Group("/users/:user_id")
+		{
+			users.GET("/duplicates", h.Duplicates)
 		}
 	}
 }
@@ -41,8 +74,9 @@ func (h *SubscriptionHandler) RegisterRoutes(router *gin.Engine) {
 // @Accept json
 // @Produce json
 // @Param subscription body CreateSubscriptionRequest true "Subscription"
+// @Param allow_overlap query bool false "Allow overlapping subscription to the same service"
 // @Success 201 {object} Subscription
-// @Failure 400,500 {object} gin.H
+// @Failure 400,409,500 {object} gin.H
 // @Router /subscriptions [post]
 func (h *SubscriptionHandler) Create(c *gin.Context) {
 	var req CreateSubscriptionRequest
@@ -82,9 +116,23 @@ func (h *SubscriptionHandler) Create(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.Create(c.Request.Context(), sub); err != nil {
+	// Пересекающаяся подписка на тот же сервис обычно ошибка, поэтому создаём её только по явному запросу
+	var conflicts []*Subscription
+	if c.Query("allow_overlap") == "true" {
+		err = h.repo.Create(c.Request.Context(), sub)
+	} else {
+		conflicts, err = h.repo.CreateNonOverlapping(c.Request.Context(), sub)
+	}
+	switch {
+	case errors.Is(err, ErrSubscriptionOverlaps):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "subscription overlaps an existing subscription to the same service, pass allow_overlap=true to create it anyway",
+			"conflicts": conflicts,
+		})
+		return
+	case err != nil:
 		h.logger.Error("failed to create subscription", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create subscription"})
+		serverError(c, err, "failed to create subscription")
 		return
 	}
 
@@ -93,13 +141,17 @@ func (h *SubscriptionHandler) Create(c *gin.Context) {
 
 // Update godoc
 // @Summary Update subscription
+// @Description The price must equal the current base price: it applies from the first month,
+// @Description so a new price is scheduled with POST /subscriptions/{id}/prices instead.
+// @Description The new dates must still cover the scheduled price changes, pauses and discounts
+// @Description (a trial must start in the new first month), and the new owner must not be a member.
 // @Tags Subscriptions
 // @Accept json
 // @Produce json
 // @Param id path string true "Subscription ID"
 // @Param subscription body UpdateSubscriptionRequest true "Subscription"
 // @Success 200 {object} Subscription
-// @Failure 400,500 {object} gin.H
+// @Failure 400,404,409,500 {object} gin.H
 // @Router /subscriptions/{id} [put]
 func (h *SubscriptionHandler) Update(c *gin.Context) {
 	id := c.Param("id")
@@ -136,14 +188,37 @@ func (h *SubscriptionHandler) Update(c *gin.Context) {
 		StartDate:   startDate,
 		EndDate:     endDatePtr,
 	}
+	if err := sub.Validate(); err != nil {
+		h.logger.Error("invalid subscription data", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err := h.repo.Update(c.Request.Context(), sub); err != nil {
+	// Новые даты и владелец сверяются с сохранёнными ценами, паузами, скидками и участниками
+	err = h.repo.Update(c.Request.Context(), sub)
+	switch {
+	case errors.Is(err, ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	case errors.Is(err, ErrPriceChangeRequired), errors.Is(err, ErrDetailsOutOfRange),
+		errors.Is(err, ErrTrialNotAtStart), errors.Is(err, ErrMemberIsOwner), errors.Is(err, ErrSharesExceedTotal):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		h.logger.Error("failed to update subscription", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update subscription"})
+		serverError(c, err, "failed to update subscription")
 		return
 	}
 
-	c.JSON(http.StatusOK, sub)
+	// Перечитываем подписку, чтобы вернуть цены с учётом истории и скидок
+	updated, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to get updated subscription", zap.Error(err))
+		serverError(c, err, "failed to get subscription")
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
 }
 
 // Get godoc
@@ -157,13 +232,13 @@ func (h *SubscriptionHandler) Update(c *gin.Context) {
 func (h *SubscriptionHandler) Get(c *gin.Context) {
 	id := c.Param("id")
 	sub, err := h.repo.GetByID(c.Request.Context(), id)
-	if err != nil {
-		h.logger.Error("failed to get subscription", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get subscription"})
+	if errors.Is(err, ErrSubscriptionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
 		return
 	}
-	if sub == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	if err != nil {
+		h.logger.Error("failed to get subscription", zap.Error(err))
+		serverError(c, err, "failed to get subscription")
 		return
 	}
 	c.JSON(http.StatusOK, sub)
@@ -175,13 +250,17 @@ func (h *SubscriptionHandler) Get(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Subscription ID"
 // @Success 204
-// @Failure 500 {object} gin.H
+// @Failure 404,500 {object} gin.H
 // @Router /subscriptions/{id} [delete]
 func (h *SubscriptionHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.repo.Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, ErrSubscriptionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+			return
+		}
 		h.logger.Error("failed to delete subscription", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete subscription"})
+		serverError(c, err, "failed to delete subscription")
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -189,8 +268,9 @@ func (h *SubscriptionHandler) Delete(c *gin.Context) {
 
 // List godoc
 // @Summary List subscriptions
+// @Description With Accept: application/x-ndjson the subscriptions are streamed one per line as they are read.
 // @Tags Subscriptions
-// @Produce json
+// @Produce json,application/x-ndjson
 // @Param user_id query string false "User ID"
 // @Param service_name query string false "Service Name"
 // @Param start_date_from query string false "Start Date From MM-YYYY"
@@ -224,28 +304,142 @@ func (h *SubscriptionHandler) List(c *gin.Context) {
 		filters["start_date_to"] = date
 	}
 
+	if c.NegotiateFormat(gin.MIMEJSON, MIMENDJSON) == MIMENDJSON {
+		h.streamList(c, filters)
+		return
+	}
+
 	subs, err := h.repo.List(c.Request.Context(), filters)
 	if err != nil {
 		h.logger.Error("failed to list subscriptions", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list subscriptions"})
+		serverError(c, err, "failed to list subscriptions")
 		return
 	}
 	c.JSON(http.StatusOK, subs)
 }
 
+// streamList пишет подписки в ответ по мере чтения из БД. Статус отправляется
+// с первой строкой, поэтому ошибку до неё клиент получает обычным ответом,
+// а после неё поток завершается строкой {"error": ...}.
+func (h *SubscriptionHandler) streamList(c *gin.Context, filters map[string]interface{}) {
+	rc := http.NewResponseController(c.Writer)
+	enc := json.NewEncoder(c.Writer)
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			c.Header("Content-Type", MIMENDJSON)
+			c.Status(http.StatusOK)
+		}
+	}
+
+	err := h.repo.StreamList(c.Request.Context(), filters, func(sub *Subscription) error {
+		start()
+		if err := extendWriteDeadline(rc, h.streamWriteTimeout); err != nil {
+			return err
+		}
+		if err := enc.Encode(sub); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	switch {
+	case err == nil:
+		start()
+		c.Writer.WriteHeaderNow()
+	case !started:
+		h.logger.Error("failed to list subscriptions", zap.Error(err))
+		serverError(c, err, "failed to list subscriptions")
+	default:
+		h.logger.Error("failed to stream subscriptions", zap.Error(err))
+		enc.Encode(gin.H{"error": "failed to list subscriptions"})
+	}
+}
+
+// Events godoc
+// @Summary Stream subscription changes
+// @Description Server-sent events subscription.created, subscription.updated and subscription.deleted
+// @Description for subscriptions owned by the user, from all instances of the service.
+// @Description Missed events are not replayed: after reconnecting, reload the subscriptions.
+// @Tags Subscriptions
+// @Produce text/event-stream
+// @Param user_id query string true "User ID"
+// @Success 200 {object} Event
+// @Failure 400 {object} gin.H
+// @Router /subscriptions/events [get]
+func (h *SubscriptionHandler) Events(c *gin.Context) {
+	userID := strings.TrimSpace(c.Query("user_id"))
+	if len(userID) != 36 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidUserID.Error()})
+		return
+	}
+
+	events, cancel := h.events.Subscribe(userID)
+	defer cancel()
+
+	rc := http.NewResponseController(c.Writer)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// nginx иначе буферизует ответ и события приходят с задержкой
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-events:
+			// Канал закрыт при отставании клиента или завершении сервера: клиент переподключится
+			if !ok {
+				return
+			}
+			if err := extendWriteDeadline(rc, h.streamWriteTimeout); err != nil {
+				return
+			}
+			c.SSEvent(event.Type, event)
+		case <-heartbeat.C:
+			if err := extendWriteDeadline(rc, h.streamWriteTimeout); err != nil {
+				return
+			}
+			if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// extendWriteDeadline заменяет общий WriteTimeout сервера дедлайном очередной записи
+// потокового ответа. Writer без поддержки дедлайнов (httptest) не считается ошибкой.
+func extendWriteDeadline(rc *http.ResponseController, timeout time.Duration) error {
+	err := rc.SetWriteDeadline(time.Now().Add(timeout))
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
 // CalculateCostResponse is the response for cost calculation
 type CalculateCostResponse struct {
 	TotalCost int `json:"total_cost"`
 }
 
 // CalculateCost godoc
-// @Summary Calculate total cost of subscriptions
+// @Summary Calculate total cost of subscriptions for a period
+// @Description Sums every month of the period at the price in effect that month.
+// @Description With user_id only the user's share of shared subscriptions is counted.
 // @Tags Subscriptions
 // @Produce json
 // @Param user_id query string false "User ID"
 // @Param service_name query string false "Service Name"
-// @Param start_date_from query string false "Start Date From MM-YYYY"
-// @Param start_date_to query string false "Start Date To MM-YYYY"
+// @Param start_date_from query string false "Period start MM-YYYY"
+// @Param start_date_to query string false "Period end MM-YYYY (defaults to current month)"
 // @Success 200 {object} CalculateCostResponse
 // @Failure 400,500 {object} gin.H
 // @Router /subscriptions/cost [get]
@@ -278,9 +472,439 @@ func (h *SubscriptionHandler) CalculateCost(c *gin.Context) {
 	total, err := h.repo.CalculateMonthlyCost(c.Request.Context(), filters)
 	if err != nil {
 		h.logger.Error("failed to calculate monthly cost", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to calculate monthly cost"})
+		serverError(c, err, "failed to calculate monthly cost")
 		return
 	}
 
 	c.JSON(http.StatusOK, CalculateCostResponse{TotalCost: total})
 }
+
+// ListPrices godoc
+// @Summary Get subscription price timeline
+// @Tags Subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {array} PriceSegment
+// @Failure 404,500 {object} gin.H
+// @Router /subscriptions/{id}/prices [get]
+func (h *SubscriptionHandler) ListPrices(c *gin.Context) {
+	sub, err := h.repo.GetByID(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrSubscriptionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get subscription", zap.Error(err))
+		serverError(c, err, "failed to get subscription")
+		return
+	}
+
+	c.JSON(http.StatusOK, sub.PriceTimeline())
+}
+
+// SchedulePriceChange godoc
+// @Summary Schedule a price change from a given month
+// @Tags Subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Description The new price may not leave fixed member amounts above the price (409)
+// @Param change body SchedulePriceChangeRequest true "Price change"
+// @Success 201 {object} PriceChange
+// @Failure 400,404,409,500 {object} gin.H
+// @Router /subscriptions/{id}/prices [post]
+func (h *SubscriptionHandler) SchedulePriceChange(c *gin.Context) {
+	var req SchedulePriceChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	effectiveFrom, err := time.Parse("01-2006", req.EffectiveFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid effective_from format, use MM-YYYY"})
+		return
+	}
+
+	change := &PriceChange{
+		SubscriptionID: c.Param("id"),
+		Price:          req.Price,
+		EffectiveFrom:  effectiveFrom,
+	}
+
+	// Изменение проверяется репозиторием в транзакции записи, а не по чтению из реплики
+	err = h.repo.AddPriceChange(c.Request.Context(), change)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	case errors.Is(err, ErrInvalidPrice), errors.Is(err, ErrInvalidEffectiveDate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrSharesExceedTotal):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	default:
+		h.logger.Error("failed to add price change", zap.Error(err))
+		serverError(c, err, "failed to schedule price change")
+		return
+	}
+
+	c.JSON(http.StatusCreated, change)
+}
+
+// Pause godoc
+// @Summary Pause subscription billing
+// @Tags Subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param pause body PauseSubscriptionRequest true "Pause interval"
+// @Success 201 {object} Pause
+// @Failure 400,404,409,500 {object} gin.H
+// @Router /subscriptions/{id}/pause [post]
+func (h *SubscriptionHandler) Pause(c *gin.Context) {
+	var req PauseSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startDate, err := time.Parse("01-2006", req.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date format, use MM-YYYY"})
+		return
+	}
+
+	var endDatePtr *time.Time
+	if req.EndDate != "" {
+		endDate, err := time.Parse("01-2006", req.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_date format, use MM-YYYY"})
+			return
+		}
+		endDatePtr = &endDate
+	}
+
+	pause, err := h.repo.Pause(c.Request.Context(), c.Param("id"), startDate, endDatePtr)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, pause)
+	case errors.Is(err, ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	case errors.Is(err, ErrPauseOverlap):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrPauseOutOfRange), errors.Is(err, ErrInvalidDateRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("failed to pause subscription", zap.Error(err))
+		serverError(c, err, "failed to pause subscription")
+	}
+}
+
+// Resume godoc
+// @Summary Resume a paused subscription
+// @Description Ends the pause covering resume_date (defaults to current month) with the previous month
+// @Tags Subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param resume body ResumeSubscriptionRequest false "Resume month"
+// @Success 200 {object} Pause
+// @Failure 400,404,409,500 {object} gin.H
+// @Router /subscriptions/{id}/resume [post]
+func (h *SubscriptionHandler) Resume(c *gin.Context) {
+	var req ResumeSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.logger.Error("invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resumeDate := time.Now().UTC()
+	if req.ResumeDate != "" {
+		date, err := time.Parse("01-2006", req.ResumeDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resume_date format, use MM-YYYY"})
+			return
+		}
+		resumeDate = date
+	}
+
+	pause, err := h.repo.Resume(c.Request.Context(), c.Param("id"), resumeDate)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, pause)
+	case errors.Is(err, ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	case errors.Is(err, ErrNotPaused):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidResumeDate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("failed to resume subscription", zap.Error(err))
+		serverError(c, err, "failed to resume subscription")
+	}
+}
+
+// ListDiscounts godoc
+// @Summary List subscription discounts
+// @Tags Subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {array} Discount
+// @Failure 404,500 {object} gin.H
+// @Router /subscriptions/{id}/discounts [get]
+func (h *SubscriptionHandler) ListDiscounts(c *gin.Context) {
+	sub, err := h.repo.GetByID(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrSubscriptionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get subscription", zap.Error(err))
+		serverError(c, err, "failed to get subscription")
+		return
+	}
+
+	discounts := sub.Discounts
+	if discounts == nil {
+		discounts = []*Discount{}
+	}
+	c.JSON(http.StatusOK, discounts)
+}
+
+// AddDiscount godoc
+// @Summary Add a discount to subscription
+// @Description Percentage or fixed discount applied for duration_periods months from start_date. A trial has no value, must start with the first month of the subscription and makes its months free; the subscription converts to the regular price afterwards
+// @Tags Subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param discount body AddDiscountRequest true "Discount"
+// @Success 201 {object} Discount
+// @Failure 400,404,409,500 {object} gin.H
+// @Router /subscriptions/{id}/discounts [post]
+func (h *SubscriptionHandler) AddDiscount(c *gin.Context) {
+	var req AddDiscountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startDate, err := time.Parse("01-2006", req.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date format, use MM-YYYY"})
+		return
+	}
+
+	discount := &Discount{
+		Kind:            DiscountKind(req.Kind),
+		Value:           req.Value,
+		StartDate:       startDate,
+		DurationPeriods: req.DurationPeriods,
+		Description:     req.Description,
+	}
+
+	err = h.repo.AddDiscount(c.Request.Context(), c.Param("id"), discount)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, discount)
+	case errors.Is(err, ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	case errors.Is(err, ErrDiscountOverlap):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidDiscount), errors.Is(err, ErrDiscountOutOfRange), errors.Is(err, ErrTrialNotAtStart):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("failed to add discount", zap.Error(err))
+		serverError(c, err, "failed to add discount")
+	}
+}
+
+// RemoveDiscount godoc
+// @Summary Remove a discount from subscription
+// @Tags Subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param discount_id path string true "Discount ID"
+// @Success 204
+// @Failure 404,500 {object} gin.H
+// @Router /subscriptions/{id}/discounts/{discount_id} [delete]
+func (h *SubscriptionHandler) RemoveDiscount(c *gin.Context) {
+	err := h.repo.RemoveDiscount(c.Request.Context(), c.Param("id"), c.Param("discount_id"))
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, ErrDiscountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "discount not found"})
+	default:
+		h.logger.Error("failed to remove discount", zap.Error(err))
+		serverError(c, err, "failed to remove discount")
+	}
+}
+
+// ListMembers godoc
+// @Summary List subscription members
+// @Tags Subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {array} Member
+// @Failure 404,500 {object} gin.H
+// @Router /subscriptions/{id}/members [get]
+func (h *SubscriptionHandler) ListMembers(c *gin.Context) {
+	sub, err := h.repo.GetByID(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrSubscriptionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get subscription", zap.Error(err))
+		serverError(c, err, "failed to get subscription")
+		return
+	}
+
+	members := sub.Members
+	if members == nil {
+		members = []*Member{}
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// AddMember godoc
+// @Summary Add a member sharing the subscription cost
+// @Description Exactly one of share_percent or fixed_amount must be set. Fixed amounts are charged first, percents split the rest, and the owner pays whatever remains. The percents of all members plus their fixed amounts as a percentage of the price may not exceed 100% for any price in the subscription timeline (409)
+// @Tags Subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param member body AddMemberRequest true "Member"
+// @Success 201 {object} Member
+// @Failure 400,404,409,500 {object} gin.H
+// @Router /subscriptions/{id}/members [post]
+func (h *SubscriptionHandler) AddMember(c *gin.Context) {
+	var req AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member := &Member{
+		UserID:       req.UserID,
+		SharePercent: req.SharePercent,
+		FixedAmount:  req.FixedAmount,
+	}
+
+	err := h.repo.AddMember(c.Request.Context(), c.Param("id"), member)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, member)
+	case errors.Is(err, ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	case errors.Is(err, ErrMemberExists), errors.Is(err, ErrSharesExceedTotal):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidMemberShare), errors.Is(err, ErrMemberIsOwner), errors.Is(err, ErrInvalidUserID):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("failed to add member", zap.Error(err))
+		serverError(c, err, "failed to add member")
+	}
+}
+
+// RemoveMember godoc
+// @Summary Remove a member from subscription
+// @Tags Subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param user_id path string true "Member User ID"
+// @Success 204
+// @Failure 404,500 {object} gin.H
+// @Router /subscriptions/{id}/members/{user_id} [delete]
+func (h *SubscriptionHandler) RemoveMember(c *gin.Context) {
+	err := h.repo.RemoveMember(c.Request.Context(), c.Param("id"), c.Param("user_id"))
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, ErrMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+	default:
+		h.logger.Error("failed to remove member", zap.Error(err))
+		serverError(c, err, "failed to remove member")
+	}
+}
+
+// Duplicates godoc
+// @Summary Find overlapping subscriptions to the same service
+// @Tags Subscriptions
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {array} Overlap
+// @Failure 500 {object} gin.H
+// @Router /users/{user_id}/duplicates [get]
+func (h *SubscriptionHandler) Duplicates(c *gin.Context) {
+	overlaps, err := h.repo.FindDuplicates(c.Request.Context(), c.Param("user_id"))
+	if err != nil {
+		h.logger.Error("failed to find duplicate subscriptions", zap.Error(err))
+		serverError(c, err, "failed to find duplicate subscriptions")
+		return
+	}
+
+	if overlaps == nil {
+		overlaps = []*Overlap{}
+	}
+	c.JSON(http.StatusOK, overlaps)
+}
+
+// Cancel godoc
+// @Summary Cancel subscription
+// @Description effective: immediately (previous month is the last billed), end_of_period (current month is the last billed) or month (the given month is the last billed). A subscription that would end before its start month is rejected with 409 and should be deleted instead
+// @Tags Subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param cancellation body CancelSubscriptionRequest true "Cancellation"
+// @Success 200 {object} Subscription
+// @Failure 400,404,409,500 {object} gin.H
+// @Router /subscriptions/{id}/cancel [post]
+func (h *SubscriptionHandler) Cancel(c *gin.Context) {
+	var req CancelSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cancellation := Cancellation{
+		Effective: CancelEffective(req.Effective),
+		Reason:    CancellationReason(req.Reason),
+		Comment:   req.Comment,
+	}
+	if req.Month != "" {
+		month, err := time.Parse("01-2006", req.Month)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid month format, use MM-YYYY"})
+			return
+		}
+		cancellation.Month = &month
+	}
+
+	sub, err := h.repo.Cancel(c.Request.Context(), c.Param("id"), cancellation)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, sub)
+	case errors.Is(err, ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+	case errors.Is(err, ErrSubscriptionEnded), errors.Is(err, ErrAlreadyCancelled), errors.Is(err, ErrCancelBeforeStart):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidCancellation), errors.Is(err, ErrInvalidCancelDate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("failed to cancel subscription", zap.Error(err))
+		serverError(c, err, "failed to cancel subscription")
+	}
+}
@@ -0,0 +1,503 @@
+package subscriptions
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"SubscriptionService/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// routeEnv — роутер с SubscriptionHandler и BudgetHandler поверх хранилищ в памяти. Каждый
+// обработанный маршрут отмечается в covered.
+type routeEnv struct {
+	t      *testing.T
+	router *gin.Engine
+	events *EventHub
+}
+
+func newRouteEnv(t *testing.T, covered map[string]bool) *routeEnv {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		covered[c.Request.Method+" "+c.FullPath()] = true
+	})
+	cfg := config.Default().HTTP
+	cfg.SSEHeartbeat = 10 * time.Millisecond
+	events := NewEventHub(cfg.SSEBuffer, zap.NewNop())
+	repo := NewMemoryRepository()
+	NewSubscriptionHandler(zap.NewNop(), repo, events, cfg).RegisterRoutes(router)
+	budgets := newMemoryBudgetRepository()
+	evaluator := NewBudgetEvaluator(budgets, repo, NewLogNotifier(zap.NewNop()), zap.NewNop())
+	NewBudgetHandler(zap.NewNop(), budgets, evaluator).RegisterRoutes(router)
+	return &routeEnv{t: t, router: router, events: events}
+}
+
+// do выполняет запрос и проверяет код ответа; body, если задан, кодируется в JSON.
+func (e *routeEnv) do(method, path string, body any, status int) *httptest.ResponseRecorder {
+	e.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatalf("encode body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	if w.Code != status {
+		e.t.Fatalf("%s %s: status %d, want %d, body %s", method, path, w.Code, status, w.Body)
+	}
+	return w
+}
+
+// create создаёт подписку через API с 01-2024 без даты окончания.
+func (e *routeEnv) create(service, userID string) *Subscription {
+	e.t.Helper()
+	w := e.do(http.MethodPost, "/api/v1/subscriptions?allow_overlap=true", gin.H{
+		"service_name": service, "price": 500, "user_id": userID, "start_date": "01-2024",
+	}, http.StatusCreated)
+	return decode[*Subscription](e.t, w)
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	return v
+}
+
+func TestSubscriptionRoutes(t *testing.T) {
+	covered := make(map[string]bool)
+	subsPath := "/api/v1/subscriptions"
+
+	t.Run("create", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		body := gin.H{"service_name": "Netflix", "price": 500, "user_id": testUsers[0], "start_date": "01-2024", "end_date": "12-2024"}
+
+		created := decode[*Subscription](t, e.do(http.MethodPost, subsPath, body, http.StatusCreated))
+		if len(created.ID) != 36 || created.ServiceName != "Netflix" || created.EffectivePrice != 500 {
+			t.Fatalf("created %+v", created)
+		}
+
+		conflict := decode[struct {
+			Conflicts []*Subscription `json:"conflicts"`
+		}](t, e.do(http.MethodPost, subsPath, body, http.StatusConflict))
+		if len(conflict.Conflicts) != 1 || conflict.Conflicts[0].ID != created.ID {
+			t.Fatalf("conflicts %+v", conflict.Conflicts)
+		}
+		e.do(http.MethodPost, subsPath+"?allow_overlap=true", body, http.StatusCreated)
+
+		e.do(http.MethodPost, subsPath, gin.H{"service_name": "Netflix", "price": 500, "user_id": "not-a-uuid", "start_date": "01-2024"}, http.StatusBadRequest)
+		e.do(http.MethodPost, subsPath, gin.H{"service_name": "Netflix", "price": 500, "user_id": testUsers[0], "start_date": "2024-01"}, http.StatusBadRequest)
+		e.do(http.MethodPost, subsPath, gin.H{"service_name": "Okko", "price": 500, "user_id": testUsers[0], "start_date": "05-2024", "end_date": "01-2024"}, http.StatusBadRequest)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		sub := e.create("Netflix", testUsers[0])
+
+		got := decode[*Subscription](t, e.do(http.MethodGet, subsPath+"/"+sub.ID, nil, http.StatusOK))
+		if got.ID != sub.ID || got.UserID != testUsers[0] {
+			t.Fatalf("got %+v", got)
+		}
+		e.do(http.MethodGet, subsPath+"/"+missingID, nil, http.StatusNotFound)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		sub := e.create("Netflix", testUsers[0])
+		body := gin.H{"service_name": "Spotify", "price": 500, "user_id": testUsers[0], "start_date": "02-2024", "end_date": "06-2024"}
+
+		got := decode[*Subscription](t, e.do(http.MethodPut, subsPath+"/"+sub.ID, body, http.StatusOK))
+		if got.ServiceName != "Spotify" || got.Price != 500 || got.EndDate == nil || !got.EndDate.Equal(month(2024, 6)) {
+			t.Fatalf("updated %+v", got)
+		}
+		e.do(http.MethodPut, subsPath+"/"+missingID, body, http.StatusNotFound)
+
+		// Новая цена не переписывает прошлые месяцы: PUT её отклоняет, а изменение
+		// с заданного месяца действует только начиная с него
+		body["price"] = 300
+		e.do(http.MethodPut, subsPath+"/"+sub.ID, body, http.StatusConflict)
+		e.do(http.MethodPost, subsPath+"/"+sub.ID+"/prices", gin.H{"price": 300, "effective_from": "04-2024"}, http.StatusCreated)
+		costPath := subsPath + "/cost?user_id=" + testUsers[0]
+		if cost := decode[CalculateCostResponse](t, e.do(http.MethodGet, costPath+"&start_date_from=01-2024&start_date_to=03-2024", nil, http.StatusOK)); cost.TotalCost != 1000 {
+			t.Fatalf("past months cost %d, want 1000 at the old price", cost.TotalCost)
+		}
+		if cost := decode[CalculateCostResponse](t, e.do(http.MethodGet, costPath+"&start_date_from=04-2024&start_date_to=06-2024", nil, http.StatusOK)); cost.TotalCost != 900 {
+			t.Fatalf("later months cost %d, want 900 at the new price", cost.TotalCost)
+		}
+
+		// Даты должны покрывать изменение цены, а владелец — не быть участником
+		body["price"] = 500
+		body["start_date"] = "05-2024"
+		e.do(http.MethodPut, subsPath+"/"+sub.ID, body, http.StatusConflict)
+		body["start_date"], body["end_date"] = "02-2024", "03-2024"
+		e.do(http.MethodPut, subsPath+"/"+sub.ID, body, http.StatusConflict)
+		body["end_date"] = "06-2024"
+		e.do(http.MethodPost, subsPath+"/"+sub.ID+"/members", gin.H{"user_id": testUsers[1], "share_percent": 50}, http.StatusCreated)
+		body["user_id"] = testUsers[1]
+		e.do(http.MethodPut, subsPath+"/"+sub.ID, body, http.StatusConflict)
+
+		body["user_id"] = testUsers[0]
+		body["service_name"] = "X"
+		e.do(http.MethodPut, subsPath+"/"+sub.ID, body, http.StatusBadRequest)
+		body["service_name"] = "Spotify"
+		body["end_date"] = "June"
+		e.do(http.MethodPut, subsPath+"/"+sub.ID, body, http.StatusBadRequest)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		sub := e.create("Netflix", testUsers[0])
+
+		e.do(http.MethodDelete, subsPath+"/"+sub.ID, nil, http.StatusNoContent)
+		e.do(http.MethodDelete, subsPath+"/"+sub.ID, nil, http.StatusNotFound)
+		e.do(http.MethodGet, subsPath+"/"+sub.ID, nil, http.StatusNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		sub := e.create("Netflix", testUsers[0])
+		e.create("Spotify", testUsers[0])
+		e.create("Netflix", testUsers[1])
+
+		subs := decode[[]*Subscription](t, e.do(http.MethodGet, subsPath+"?user_id="+testUsers[0]+"&service_name=Netflix&start_date_from=01-2024", nil, http.StatusOK))
+		if len(subs) != 1 || subs[0].ID != sub.ID {
+			t.Fatalf("listed %+v", subs)
+		}
+		if subs := decode[[]*Subscription](t, e.do(http.MethodGet, subsPath, nil, http.StatusOK)); len(subs) != 3 {
+			t.Fatalf("listed %d subscriptions, want 3", len(subs))
+		}
+		e.do(http.MethodGet, subsPath+"?start_date_to=2024", nil, http.StatusBadRequest)
+
+		req := httptest.NewRequest(http.MethodGet, subsPath+"?user_id="+testUsers[0], nil)
+		req.Header.Set("Accept", MIMENDJSON)
+		w := httptest.NewRecorder()
+		e.router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != MIMENDJSON {
+			t.Fatalf("ndjson: status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+		}
+		var streamed []string
+		for _, line := range strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n") {
+			var sub Subscription
+			if err := json.Unmarshal([]byte(line), &sub); err != nil {
+				t.Fatalf("ndjson line %q: %v", line, err)
+			}
+			streamed = append(streamed, sub.ID)
+		}
+		listed := decode[[]*Subscription](t, e.do(http.MethodGet, subsPath+"?user_id="+testUsers[0], nil, http.StatusOK))
+		if want := subscriptionIDs(listed); !slices.Equal(streamed, want) {
+			t.Fatalf("streamed %v, want %v", streamed, want)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		e.do(http.MethodGet, subsPath+"/events", nil, http.StatusBadRequest)
+
+		srv := httptest.NewServer(e.router)
+		defer srv.Close()
+		resp, err := http.Get(srv.URL + subsPath + "/events?user_id=" + testUsers[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+
+		lines := bufio.NewScanner(resp.Body)
+		next := func() string {
+			if !lines.Scan() {
+				t.Fatalf("stream ended: %v", lines.Err())
+			}
+			return lines.Text()
+		}
+		if line := next(); line != ": heartbeat" {
+			t.Fatalf("first line %q, want heartbeat", line)
+		}
+
+		// Полезная нагрузка в том виде, в каком её отправляет триггер; событие чужого пользователя не приходит
+		e.events.HandleNotification(`{"type":"subscription.created","subscription_id":"` + missingID + `","user_id":"` + testUsers[1] + `","occurred_at":"2024-03-01T12:00:00.5+03:00"}`)
+		e.events.HandleNotification(`{"type":"subscription.updated","subscription_id":"` + missingID + `","user_id":"` + strings.ToUpper(testUsers[0]) + `","occurred_at":"2024-03-01T12:00:00.5+03:00"}`)
+		line := next()
+		for line == "" || line == ": heartbeat" {
+			line = next()
+		}
+		if line != "event:"+EventSubscriptionUpdated {
+			t.Fatalf("event line %q", line)
+		}
+		var event struct {
+			Type       string             `json:"type"`
+			UserID     string             `json:"user_id"`
+			Payload    SubscriptionChange `json:"payload"`
+			OccurredAt time.Time          `json:"occurred_at"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(next(), "data:")), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != EventSubscriptionUpdated || event.Payload.SubscriptionID != missingID ||
+			!event.OccurredAt.Equal(time.Date(2024, 3, 1, 9, 0, 0, 5e8, time.UTC)) {
+			t.Fatalf("event %+v", event)
+		}
+
+		// После Close поток завершается, а новые подписчики сразу получают закрытый канал
+		e.events.Close()
+		for lines.Scan() {
+		}
+		closed, cancel := e.events.Subscribe(testUsers[0])
+		defer cancel()
+		if _, ok := <-closed; ok {
+			t.Fatal("subscribed to a closed hub")
+		}
+	})
+
+	t.Run("cost", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		e.create("Netflix", testUsers[0])
+		e.create("Spotify", testUsers[1])
+
+		cost := decode[CalculateCostResponse](t, e.do(http.MethodGet, subsPath+"/cost?start_date_from=01-2024&start_date_to=03-2024", nil, http.StatusOK))
+		if cost.TotalCost != 3000 {
+			t.Fatalf("total %d, want 3000", cost.TotalCost)
+		}
+		cost = decode[CalculateCostResponse](t, e.do(http.MethodGet, subsPath+"/cost?user_id="+testUsers[1]+"&start_date_from=01-2024&start_date_to=03-2024", nil, http.StatusOK))
+		if cost.TotalCost != 1500 {
+			t.Fatalf("user total %d, want 1500", cost.TotalCost)
+		}
+		e.do(http.MethodGet, subsPath+"/cost?start_date_from=1-1-2024", nil, http.StatusBadRequest)
+	})
+
+	t.Run("prices", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		sub := e.create("Netflix", testUsers[0])
+		path := subsPath + "/" + sub.ID + "/prices"
+
+		change := decode[*PriceChange](t, e.do(http.MethodPost, path, gin.H{"price": 700, "effective_from": "03-2024"}, http.StatusCreated))
+		if change.ID == "" || change.Price != 700 {
+			t.Fatalf("price change %+v", change)
+		}
+		e.do(http.MethodPost, path, gin.H{"price": 700, "effective_from": "01-2024"}, http.StatusBadRequest)
+		e.do(http.MethodPost, path, gin.H{"price": 700, "effective_from": "March"}, http.StatusBadRequest)
+		e.do(http.MethodPost, subsPath+"/"+missingID+"/prices", gin.H{"price": 700, "effective_from": "03-2024"}, http.StatusNotFound)
+
+		timeline := decode[[]PriceSegment](t, e.do(http.MethodGet, path, nil, http.StatusOK))
+		if len(timeline) != 2 || timeline[0].Price != 500 || timeline[1].Price != 700 || !timeline[1].EffectiveFrom.Equal(month(2024, 3)) {
+			t.Fatalf("timeline %+v", timeline)
+		}
+		e.do(http.MethodGet, subsPath+"/"+missingID+"/prices", nil, http.StatusNotFound)
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		sub := e.create("Netflix", testUsers[0])
+		pausePath, resumePath := subsPath+"/"+sub.ID+"/pause", subsPath+"/"+sub.ID+"/resume"
+
+		pause := decode[*Pause](t, e.do(http.MethodPost, pausePath, gin.H{"start_date": "03-2024"}, http.StatusCreated))
+		if pause.ID == "" || pause.EndDate != nil {
+			t.Fatalf("pause %+v", pause)
+		}
+		e.do(http.MethodPost, pausePath, gin.H{"start_date": "04-2024", "end_date": "05-2024"}, http.StatusConflict)
+		e.do(http.MethodPost, pausePath, gin.H{"start_date": "12-2023"}, http.StatusBadRequest)
+		e.do(http.MethodPost, pausePath, gin.H{"start_date": "2024"}, http.StatusBadRequest)
+		e.do(http.MethodPost, subsPath+"/"+missingID+"/pause", gin.H{"start_date": "03-2024"}, http.StatusNotFound)
+
+		e.do(http.MethodPost, resumePath, gin.H{"resume_date": "03-2024"}, http.StatusBadRequest)
+		resumed := decode[*Pause](t, e.do(http.MethodPost, resumePath, gin.H{"resume_date": "05-2024"}, http.StatusOK))
+		if resumed.ID != pause.ID || resumed.EndDate == nil || !resumed.EndDate.Equal(month(2024, 4)) {
+			t.Fatalf("resumed %+v", resumed)
+		}
+		// Без тела возобновление с текущего месяца, а пауза уже закончилась
+		e.do(http.MethodPost, resumePath, nil, http.StatusConflict)
+		e.do(http.MethodPost, subsPath+"/"+missingID+"/resume", nil, http.StatusNotFound)
+	})
+
+	t.Run("discounts", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		sub := e.create("Netflix", testUsers[0])
+		path := subsPath + "/" + sub.ID + "/discounts"
+		body := gin.H{"kind": "percentage", "value": 10, "start_date": "02-2024", "duration_periods": 3}
+
+		discount := decode[*Discount](t, e.do(http.MethodPost, path, body, http.StatusCreated))
+		if discount.ID == "" || discount.SubscriptionID != sub.ID {
+			t.Fatalf("discount %+v", discount)
+		}
+		e.do(http.MethodPost, path, body, http.StatusConflict)
+		e.do(http.MethodPost, path, gin.H{"kind": "percentage", "value": 150, "start_date": "06-2024", "duration_periods": 1}, http.StatusBadRequest)
+		e.do(http.MethodPost, path, gin.H{"kind": "bogus", "value": 10, "start_date": "06-2024", "duration_periods": 1}, http.StatusBadRequest)
+		e.do(http.MethodPost, path, gin.H{"kind": "fixed", "start_date": "06-2024", "duration_periods": 1}, http.StatusBadRequest)
+		e.do(http.MethodPost, subsPath+"/"+missingID+"/discounts", body, http.StatusNotFound)
+
+		// Пробный период без значения и только с первого месяца подписки
+		trialPath := subsPath + "/" + e.create("Spotify", testUsers[0]).ID + "/discounts"
+		e.do(http.MethodPost, trialPath, gin.H{"kind": "trial", "start_date": "03-2024", "duration_periods": 2}, http.StatusBadRequest)
+		e.do(http.MethodPost, trialPath, gin.H{"kind": "trial", "value": 10, "start_date": "01-2024", "duration_periods": 2}, http.StatusBadRequest)
+		if trial := decode[*Discount](t, e.do(http.MethodPost, trialPath, gin.H{"kind": "trial", "start_date": "01-2024", "duration_periods": 2}, http.StatusCreated)); trial.Kind != DiscountTrial {
+			t.Fatalf("trial %+v", trial)
+		}
+
+		discounts := decode[[]*Discount](t, e.do(http.MethodGet, path, nil, http.StatusOK))
+		if len(discounts) != 1 || discounts[0].ID != discount.ID {
+			t.Fatalf("discounts %+v", discounts)
+		}
+		e.do(http.MethodGet, subsPath+"/"+missingID+"/discounts", nil, http.StatusNotFound)
+
+		e.do(http.MethodDelete, path+"/"+discount.ID, nil, http.StatusNoContent)
+		e.do(http.MethodDelete, path+"/"+discount.ID, nil, http.StatusNotFound)
+		if w := e.do(http.MethodGet, path, nil, http.StatusOK); w.Body.String() != "[]" {
+			t.Fatalf("discounts after removal %s, want []", w.Body)
+		}
+	})
+
+	t.Run("members", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		sub := e.create("Netflix", testUsers[0])
+		path := subsPath + "/" + sub.ID + "/members"
+
+		member := decode[*Member](t, e.do(http.MethodPost, path, gin.H{"user_id": testUsers[1], "share_percent": 50}, http.StatusCreated))
+		if member.SubscriptionID != sub.ID || member.UserID != testUsers[1] {
+			t.Fatalf("member %+v", member)
+		}
+		e.do(http.MethodPost, path, gin.H{"user_id": testUsers[1], "share_percent": 10}, http.StatusConflict)
+		e.do(http.MethodPost, path, gin.H{"user_id": testUsers[2], "share_percent": 60}, http.StatusConflict)
+		e.do(http.MethodPost, path, gin.H{"user_id": testUsers[0], "share_percent": 10}, http.StatusBadRequest)
+		e.do(http.MethodPost, path, gin.H{"user_id": testUsers[2]}, http.StatusBadRequest)
+		e.do(http.MethodPost, subsPath+"/"+missingID+"/members", gin.H{"user_id": testUsers[1], "share_percent": 50}, http.StatusNotFound)
+
+		members := decode[[]*Member](t, e.do(http.MethodGet, path, nil, http.StatusOK))
+		if len(members) != 1 || members[0].UserID != testUsers[1] {
+			t.Fatalf("members %+v", members)
+		}
+		e.do(http.MethodGet, subsPath+"/"+missingID+"/members", nil, http.StatusNotFound)
+
+		e.do(http.MethodDelete, path+"/"+testUsers[1], nil, http.StatusNoContent)
+		e.do(http.MethodDelete, path+"/"+testUsers[1], nil, http.StatusNotFound)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		sub := e.create("Netflix", testUsers[0])
+		other := e.create("Spotify", testUsers[0])
+		path := subsPath + "/" + sub.ID + "/cancel"
+
+		cancelled := decode[*Subscription](t, e.do(http.MethodPost, path, gin.H{"effective": "end_of_period", "reason": "too_expensive"}, http.StatusOK))
+		if cancelled.CancelledAt == nil || cancelled.EndDate == nil || cancelled.CancellationReason != ReasonTooExpensive {
+			t.Fatalf("cancelled %+v", cancelled)
+		}
+		e.do(http.MethodPost, path, gin.H{"effective": "end_of_period", "reason": "too_expensive"}, http.StatusConflict)
+
+		otherPath := subsPath + "/" + other.ID + "/cancel"
+		e.do(http.MethodPost, otherPath, gin.H{"effective": "end_of_period", "reason": "bored"}, http.StatusBadRequest)
+		e.do(http.MethodPost, otherPath, gin.H{"effective": "month", "month": "01-2020", "reason": "other"}, http.StatusBadRequest)
+		e.do(http.MethodPost, otherPath, gin.H{"effective": "month", "month": "2020", "reason": "other"}, http.StatusBadRequest)
+		e.do(http.MethodPost, otherPath, gin.H{"effective": "later", "reason": "other"}, http.StatusBadRequest)
+		e.do(http.MethodPost, subsPath+"/"+missingID+"/cancel", gin.H{"effective": "end_of_period", "reason": "other"}, http.StatusNotFound)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		a := e.create("Netflix", testUsers[0])
+		b := e.create("netflix", testUsers[0])
+		e.create("Spotify", testUsers[0])
+
+		overlaps := decode[[]*Overlap](t, e.do(http.MethodGet, "/api/v1/users/"+testUsers[0]+"/duplicates", nil, http.StatusOK))
+		if len(overlaps) != 1 {
+			t.Fatalf("overlaps %+v", overlaps)
+		}
+		ids := []string{overlaps[0].First.ID, overlaps[0].Second.ID}
+		want := []string{a.ID, b.ID}
+		sort.Strings(ids)
+		sort.Strings(want)
+		if ids[0] != want[0] || ids[1] != want[1] || !overlaps[0].From.Equal(month(2024, 1)) || overlaps[0].To != nil {
+			t.Fatalf("overlap %+v", overlaps[0])
+		}
+
+		if w := e.do(http.MethodGet, "/api/v1/users/"+testUsers[1]+"/duplicates", nil, http.StatusOK); w.Body.String() != "[]" {
+			t.Fatalf("duplicates of a user without subscriptions %s, want []", w.Body)
+		}
+	})
+
+	t.Run("budgets", func(t *testing.T) {
+		e := newRouteEnv(t, covered)
+		e.create("Netflix", testUsers[0])
+		e.create("Spotify", testUsers[0])
+		path := "/api/v1/users/" + testUsers[0] + "/budgets"
+
+		overall := decode[*Budget](t, e.do(http.MethodPost, path, gin.H{"amount": 800}, http.StatusCreated))
+		netflix := decode[*Budget](t, e.do(http.MethodPost, path, gin.H{"service_name": " Netflix ", "amount": 500}, http.StatusCreated))
+		if overall.ID == "" || overall.UserID != testUsers[0] || netflix.ServiceName == nil || *netflix.ServiceName != "Netflix" {
+			t.Fatalf("budgets %+v %+v", overall, netflix)
+		}
+		e.do(http.MethodPost, path, gin.H{"amount": 100}, http.StatusConflict)
+		e.do(http.MethodPost, path, gin.H{"amount": 0}, http.StatusBadRequest)
+		e.do(http.MethodPost, "/api/v1/users/not-a-uuid/budgets", gin.H{"amount": 100}, http.StatusBadRequest)
+
+		budgets := decode[[]*Budget](t, e.do(http.MethodGet, path, nil, http.StatusOK))
+		if len(budgets) != 2 || budgets[0].ID != overall.ID || budgets[1].ID != netflix.ID {
+			t.Fatalf("budgets %+v", budgets)
+		}
+		if got := decode[*Budget](t, e.do(http.MethodGet, path+"/"+netflix.ID, nil, http.StatusOK)); got.Amount != 500 {
+			t.Fatalf("budget %+v", got)
+		}
+		e.do(http.MethodGet, "/api/v1/users/"+testUsers[1]+"/budgets/"+netflix.ID, nil, http.StatusNotFound)
+
+		// Обе подписки по 500: общий бюджет превышен, бюджет на Netflix исчерпан ровно,
+		// а после уменьшения тоже превышен
+		statuses := decode[[]*BudgetStatus](t, e.do(http.MethodGet, path+"/status?month=03-2024", nil, http.StatusOK))
+		if len(statuses) != 2 || !statuses[0].Exceeded || statuses[0].Spent != 1000 || statuses[1].Exceeded || statuses[1].Remaining != 0 {
+			t.Fatalf("statuses %+v %+v", statuses[0], statuses[1])
+		}
+		e.do(http.MethodPut, path+"/"+netflix.ID, gin.H{"service_name": "Netflix", "amount": 300}, http.StatusOK)
+		e.do(http.MethodPut, path+"/"+netflix.ID, gin.H{"amount": 300}, http.StatusConflict)
+		e.do(http.MethodPut, path+"/"+missingID, gin.H{"amount": 300}, http.StatusNotFound)
+		e.do(http.MethodPut, path+"/"+netflix.ID, gin.H{}, http.StatusBadRequest)
+		statuses = decode[[]*BudgetStatus](t, e.do(http.MethodGet, path+"/status?month=03-2024", nil, http.StatusOK))
+		if !statuses[1].Exceeded || statuses[1].Spent != 500 || statuses[1].Remaining != -200 {
+			t.Fatalf("status after update %+v", statuses[1])
+		}
+		// До начала подписок расходов нет
+		statuses = decode[[]*BudgetStatus](t, e.do(http.MethodGet, path+"/status?month=12-2023", nil, http.StatusOK))
+		if statuses[0].Spent != 0 || statuses[0].Exceeded {
+			t.Fatalf("status before the subscriptions %+v", statuses[0])
+		}
+		e.do(http.MethodGet, path+"/status?month=2024-03", nil, http.StatusBadRequest)
+
+		e.do(http.MethodDelete, path+"/"+netflix.ID, nil, http.StatusNoContent)
+		e.do(http.MethodDelete, path+"/"+netflix.ID, nil, http.StatusNotFound)
+		if budgets := decode[[]*Budget](t, e.do(http.MethodGet, path, nil, http.StatusOK)); len(budgets) != 1 {
+			t.Fatalf("budgets after delete %+v", budgets)
+		}
+	})
+
+	// Новый маршрут без проверки здесь должен уронить тест
+	t.Run("every route is covered", func(t *testing.T) {
+		e := newRouteEnv(t, make(map[string]bool))
+		for _, route := range e.router.Routes() {
+			if key := route.Method + " " + route.Path; !covered[key] {
+				t.Errorf("route %s is not covered", key)
+			}
+		}
+	})
+}
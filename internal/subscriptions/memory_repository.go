@@ -0,0 +1,672 @@
+package subscriptions
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory ISubscriptionRepository for tests and for
+// running the service without PostgreSQL. Filters, ordering, validation and
+// cost calculation follow SubscriptionRepository, and every call returns
+// copies, so callers cannot change stored data. The monthly_spend rollup is
+// not kept: MonthlySpend reports ok=false and costs are calculated on the fly.
+type MemoryRepository struct {
+	mu   sync.RWMutex
+	subs map[string]*Subscription
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{subs: make(map[string]*Subscription)}
+}
+
+func (r *MemoryRepository) Create(_ context.Context, sub *Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.insert(sub)
+	return nil
+}
+
+func (r *MemoryRepository) CreateNonOverlapping(_ context.Context, sub *Subscription) ([]*Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if conflicts := r.overlapping(sub); len(conflicts) > 0 {
+		return conflicts, ErrSubscriptionOverlaps
+	}
+	r.insert(sub)
+	return nil, nil
+}
+
+func (r *MemoryRepository) GetByID(_ context.Context, id string) (*Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.subs[strings.ToLower(id)]
+	if !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+	return load(stored), nil
+}
+
+func (r *MemoryRepository) Update(_ context.Context, sub *Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.subs[strings.ToLower(sub.ID)]
+	if !ok {
+		return ErrSubscriptionNotFound
+	}
+	if err := load(stored).ApplyUpdate(sub); err != nil {
+		return err
+	}
+	stored.ServiceName = sub.ServiceName
+	stored.UserID = strings.ToLower(sub.UserID)
+	stored.StartDate = dateOf(sub.StartDate)
+	stored.EndDate = datePtr(sub.EndDate)
+	return nil
+}
+
+func (r *MemoryRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id = strings.ToLower(id)
+	if _, ok := r.subs[id]; !ok {
+		return ErrSubscriptionNotFound
+	}
+	delete(r.subs, id)
+	return nil
+}
+
+func (r *MemoryRepository) PurgeEnded(_ context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before = dateOf(before)
+	var purged int64
+	for id, sub := range r.subs {
+		if sub.EndDate != nil && sub.EndDate.Before(before) {
+			delete(r.subs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *MemoryRepository) List(_ context.Context, filters map[string]interface{}) ([]*Subscription, error) {
+	f, err := parseMemoryFilters(filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matched := r.selectSorted(f.matchList)
+	if f.offset != nil {
+		matched = matched[min(max(*f.offset, 0), len(matched)):]
+	}
+	if f.limit != nil {
+		matched = matched[:min(max(*f.limit, 0), len(matched))]
+	}
+
+	var subs []*Subscription
+	for _, sub := range matched {
+		subs = append(subs, load(sub))
+	}
+	return subs, nil
+}
+
+// StreamList передаёт fn снимок List: fn не вызывается под блокировкой, чтобы
+// медленный получатель не задерживал запись.
+func (r *MemoryRepository) StreamList(ctx context.Context, filters map[string]interface{}, fn func(*Subscription) error) error {
+	subs, err := r.List(ctx, filters)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CalculateMonthlyCost суммирует стоимость подписок помесячно, см. PeriodCost.
+func (r *MemoryRepository) CalculateMonthlyCost(ctx context.Context, filters map[string]interface{}) (int, error) {
+	subs, err := r.ListForPeriod(ctx, filters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate monthly cost: %w", err)
+	}
+	return PeriodCost(subs, filters, time.Now().UTC()), nil
+}
+
+func (r *MemoryRepository) ListForPeriod(_ context.Context, filters map[string]interface{}) ([]*Subscription, error) {
+	f, err := parseMemoryFilters(filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions for period: %w", err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subs := []*Subscription{}
+	for _, sub := range r.selectSorted(f.matchCost) {
+		subs = append(subs, load(sub))
+	}
+	return subs, nil
+}
+
+func (r *MemoryRepository) FindDuplicates(_ context.Context, userID string) ([]*Overlap, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userID = strings.ToLower(userID)
+	overlaps := []*Overlap{}
+	for _, a := range r.subs {
+		if a.UserID != userID {
+			continue
+		}
+		for _, b := range r.subs {
+			if b.UserID != a.UserID || b.ID <= a.ID || !strings.EqualFold(a.ServiceName, b.ServiceName) ||
+				!datesOverlap(a.StartDate, a.EndDate, b.StartDate, b.EndDate) {
+				continue
+			}
+
+			o := &Overlap{
+				ServiceName: a.ServiceName,
+				From:        a.StartDate,
+				To:          datePtr(a.EndDate),
+				First:       overlapSide(a),
+				Second:      overlapSide(b),
+			}
+			if b.StartDate.After(o.From) {
+				o.From = b.StartDate
+			}
+			if b.EndDate != nil && (o.To == nil || b.EndDate.Before(*o.To)) {
+				o.To = datePtr(b.EndDate)
+			}
+			overlaps = append(overlaps, o)
+		}
+	}
+
+	// Порядок пар с одинаковым началом PostgreSQL не задаёт, здесь он фиксирован по id
+	sort.Slice(overlaps, func(i, j int) bool {
+		a, b := overlaps[i], overlaps[j]
+		if sa, sb := strings.ToLower(a.ServiceName), strings.ToLower(b.ServiceName); sa != sb {
+			return sa < sb
+		}
+		if !a.From.Equal(b.From) {
+			return a.From.Before(b.From)
+		}
+		if a.First.ID != b.First.ID {
+			return a.First.ID < b.First.ID
+		}
+		return a.Second.ID < b.Second.ID
+	})
+	return overlaps, nil
+}
+
+func (r *MemoryRepository) FindOverlapping(_ context.Context, sub *Subscription) ([]*Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.overlapping(sub), nil
+}
+
+// overlapping вызывается под блокировкой
+func (r *MemoryRepository) overlapping(sub *Subscription) []*Subscription {
+	userID, start, end := strings.ToLower(sub.UserID), dateOf(sub.StartDate), datePtr(sub.EndDate)
+	matched := r.selectSorted(func(s *Subscription) bool {
+		return s.UserID == userID && strings.EqualFold(s.ServiceName, sub.ServiceName) && s.ID != sub.ID &&
+			datesOverlap(s.StartDate, s.EndDate, start, end)
+	})
+
+	// Как и SubscriptionRepository, возвращает только строки подписок без связанных данных
+	subs := []*Subscription{}
+	for _, s := range matched {
+		row := *s
+		row.EndDate = clonePtr(s.EndDate)
+		row.CancelledAt = clonePtr(s.CancelledAt)
+		row.PriceChanges, row.Pauses, row.Discounts, row.Members = nil, nil, nil, nil
+		subs = append(subs, &row)
+	}
+	return subs
+}
+
+func (r *MemoryRepository) Cancel(_ context.Context, subscriptionID string, cancellation Cancellation) (*Subscription, error) {
+	sub, err := r.modify(subscriptionID, func(sub *Subscription) error {
+		return sub.Cancel(cancellation, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	sub.RefreshPricing(time.Now().UTC())
+	return sub, nil
+}
+
+func (r *MemoryRepository) ChurnStats(_ context.Context, filters map[string]interface{}) ([]*ChurnStat, error) {
+	var errs []error
+	service := filterValue[string](filters, "service_name", &errs)
+	from := filterValue[time.Time](filters, "cancelled_from", &errs)
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("failed to collect churn statistics: %w", err)
+	}
+	// cancelled_to другого типа SubscriptionRepository тоже пропускает
+	to, hasTo := filters["cancelled_to"].(time.Time)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[ChurnStat]int)
+	for _, sub := range r.subs {
+		if sub.CancelledAt == nil ||
+			(service != nil && sub.ServiceName != *service) ||
+			(from != nil && sub.CancelledAt.Before(*from)) ||
+			(hasTo && !sub.CancelledAt.Before(monthStart(to).AddDate(0, 1, 0))) {
+			continue
+		}
+		counts[ChurnStat{ServiceName: sub.ServiceName, Reason: sub.CancellationReason}]++
+	}
+
+	stats := []*ChurnStat{}
+	for stat, count := range counts {
+		stat.Count = count
+		stats = append(stats, &stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].ServiceName != stats[j].ServiceName {
+			return stats[i].ServiceName < stats[j].ServiceName
+		}
+		return stats[i].Reason < stats[j].Reason
+	})
+	return stats, nil
+}
+
+// AddPriceChange добавляет изменение цены или заменяет цену изменения с тем же месяцем,
+// сохраняя его id, как ON CONFLICT в SubscriptionRepository.
+func (r *MemoryRepository) AddPriceChange(_ context.Context, change *PriceChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sub, ok := r.subs[strings.ToLower(change.SubscriptionID)]
+	if !ok {
+		return fmt.Errorf("failed to add price change: %w", ErrSubscriptionNotFound)
+	}
+	validated, err := load(sub).SchedulePriceChange(change.Price, change.EffectiveFrom)
+	if err != nil {
+		return err
+	}
+
+	effectiveFrom := dateOf(validated.EffectiveFrom)
+	change.EffectiveFrom = effectiveFrom
+	for _, pc := range sub.PriceChanges {
+		if pc.EffectiveFrom.Equal(effectiveFrom) {
+			pc.Price = change.Price
+			change.ID, change.CreatedAt = pc.ID, pc.CreatedAt
+			return nil
+		}
+	}
+
+	stored := &PriceChange{
+		ID:             newUUID(),
+		SubscriptionID: sub.ID,
+		Price:          change.Price,
+		EffectiveFrom:  effectiveFrom,
+		CreatedAt:      time.Now().UTC(),
+	}
+	sub.PriceChanges = append(sub.PriceChanges, stored)
+	sort.SliceStable(sub.PriceChanges, func(i, j int) bool {
+		return sub.PriceChanges[i].EffectiveFrom.Before(sub.PriceChanges[j].EffectiveFrom)
+	})
+	change.ID, change.CreatedAt = stored.ID, stored.CreatedAt
+	return nil
+}
+
+func (r *MemoryRepository) ListPriceChanges(_ context.Context, subscriptionID string) ([]*PriceChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	changes := []*PriceChange{}
+	if sub, ok := r.subs[strings.ToLower(subscriptionID)]; ok {
+		changes = append(changes, cloneAll(sub.PriceChanges, clonePtr[PriceChange])...)
+	}
+	return changes, nil
+}
+
+func (r *MemoryRepository) Pause(_ context.Context, subscriptionID string, from time.Time, to *time.Time) (*Pause, error) {
+	var pause *Pause
+	_, err := r.modify(subscriptionID, func(sub *Subscription) error {
+		p, err := sub.Pause(from, to)
+		if err != nil {
+			return err
+		}
+		p.ID = newUUID()
+		pause = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pause, nil
+}
+
+func (r *MemoryRepository) Resume(_ context.Context, subscriptionID string, month time.Time) (*Pause, error) {
+	var pause *Pause
+	_, err := r.modify(subscriptionID, func(sub *Subscription) error {
+		p, err := sub.Resume(month)
+		pause = p
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pause, nil
+}
+
+func (r *MemoryRepository) AddDiscount(_ context.Context, subscriptionID string, discount *Discount) error {
+	_, err := r.modify(subscriptionID, func(sub *Subscription) error {
+		if err := sub.AddDiscount(discount); err != nil {
+			return err
+		}
+		discount.ID = newUUID()
+		discount.CreatedAt = time.Now().UTC()
+		return nil
+	})
+	return err
+}
+
+func (r *MemoryRepository) RemoveDiscount(_ context.Context, subscriptionID, discountID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sub, ok := r.subs[strings.ToLower(subscriptionID)]
+	if !ok {
+		return ErrDiscountNotFound
+	}
+	for i, d := range sub.Discounts {
+		if d.ID == strings.ToLower(discountID) {
+			sub.Discounts = append(sub.Discounts[:i:i], sub.Discounts[i+1:]...)
+			return nil
+		}
+	}
+	return ErrDiscountNotFound
+}
+
+func (r *MemoryRepository) AddMember(_ context.Context, subscriptionID string, member *Member) error {
+	_, err := r.modify(subscriptionID, func(sub *Subscription) error {
+		if err := sub.AddMember(member); err != nil {
+			return err
+		}
+		member.CreatedAt = time.Now().UTC()
+		return nil
+	})
+	return err
+}
+
+func (r *MemoryRepository) RemoveMember(_ context.Context, subscriptionID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sub, ok := r.subs[strings.ToLower(subscriptionID)]
+	if !ok {
+		return ErrMemberNotFound
+	}
+	for i, m := range sub.Members {
+		if m.UserID == strings.ToLower(userID) {
+			sub.Members = append(sub.Members[:i:i], sub.Members[i+1:]...)
+			return nil
+		}
+	}
+	return ErrMemberNotFound
+}
+
+// MonthlySpend всегда возвращает ok=false: агрегата в памяти нет, и вызывающий
+// считает суммы по подпискам.
+func (r *MemoryRepository) MonthlySpend(_ context.Context, _ map[string]interface{}) ([]*MonthlySpend, bool, error) {
+	return nil, false, nil
+}
+
+// modify применяет изменение fn к копии подписки со связанными данными и
+// сохраняет её, только если fn не вернул ошибку, как транзакция в
+// SubscriptionRepository. Возвращает изменённую копию.
+func (r *MemoryRepository) modify(id string, fn func(sub *Subscription) error) (*Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.subs[strings.ToLower(id)]
+	if !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+	sub := load(stored)
+	if err := fn(sub); err != nil {
+		return nil, err
+	}
+	r.store(sub)
+	return sub, nil
+}
+
+// insert сохраняет новую подписку под блокировкой и присваивает sub её ID
+func (r *MemoryRepository) insert(sub *Subscription) {
+	stored := &Subscription{
+		ID:          newUUID(),
+		ServiceName: sub.ServiceName,
+		Price:       sub.Price,
+		UserID:      sub.UserID,
+		StartDate:   dateOf(sub.StartDate),
+		EndDate:     datePtr(sub.EndDate),
+	}
+	r.store(stored)
+	sub.ID = stored.ID
+}
+
+// store сохраняет копию подписки в том виде, в каком её вернул бы PostgreSQL:
+// uuid в нижнем регистре, даты без времени, без created_at и updated_at,
+// которые SubscriptionRepository не читает.
+func (r *MemoryRepository) store(sub *Subscription) {
+	stored := cloneSubscription(sub)
+	stored.UserID = strings.ToLower(stored.UserID)
+	stored.CreatedAt, stored.UpdatedAt = time.Time{}, time.Time{}
+	stored.ListPrice, stored.EffectivePrice = 0, 0
+	for _, m := range stored.Members {
+		m.UserID = strings.ToLower(m.UserID)
+	}
+	// Цены, паузы и скидки упорядочивают методы модели, участников — attachDetails
+	sort.Slice(stored.Members, func(i, j int) bool {
+		return stored.Members[i].UserID < stored.Members[j].UserID
+	})
+	r.subs[stored.ID] = stored
+}
+
+// selectSorted возвращает подписки, для которых match истинно, в порядке start_date, id.
+func (r *MemoryRepository) selectSorted(match func(sub *Subscription) bool) []*Subscription {
+	var selected []*Subscription
+	for _, sub := range r.subs {
+		if match(sub) {
+			selected = append(selected, sub)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if !selected[i].StartDate.Equal(selected[j].StartDate) {
+			return selected[i].StartDate.Before(selected[j].StartDate)
+		}
+		return selected[i].ID < selected[j].ID
+	})
+	return selected
+}
+
+// load возвращает копию подписки со связанными данными и текущей ценой, как attachDetails.
+func load(stored *Subscription) *Subscription {
+	sub := cloneSubscription(stored)
+	sub.RefreshPricing(time.Now().UTC())
+	return sub
+}
+
+// overlapSide возвращает поля подписки, которые FindDuplicates читает из БД.
+func overlapSide(sub *Subscription) *Subscription {
+	return &Subscription{
+		ID:          sub.ID,
+		ServiceName: sub.ServiceName,
+		Price:       sub.Price,
+		UserID:      sub.UserID,
+		StartDate:   sub.StartDate,
+		EndDate:     clonePtr(sub.EndDate),
+	}
+}
+
+// memoryFilters — фильтры List и ListForPeriod; nil означает, что фильтра нет.
+type memoryFilters struct {
+	userID      *string
+	userIDs     *[]string
+	serviceName *string
+	from        *time.Time
+	to          *time.Time
+	limit       *int
+	offset      *int
+}
+
+func parseMemoryFilters(filters map[string]interface{}) (memoryFilters, error) {
+	var errs []error
+	f := memoryFilters{
+		userID:      filterValue[string](filters, "user_id", &errs),
+		userIDs:     filterValue[[]string](filters, "user_ids", &errs),
+		serviceName: filterValue[string](filters, "service_name", &errs),
+		from:        filterValue[time.Time](filters, "start_date_from", &errs),
+		to:          filterValue[time.Time](filters, "start_date_to", &errs),
+		limit:       filterValue[int](filters, "limit", &errs),
+		offset:      filterValue[int](filters, "offset", &errs),
+	}
+	// Даты сравниваются с колонками типа DATE, поэтому время суток отбрасывается
+	if f.from != nil {
+		*f.from = dateOf(*f.from)
+	}
+	if f.to != nil {
+		*f.to = dateOf(*f.to)
+	}
+	return f, errors.Join(errs...)
+}
+
+// filterValue возвращает значение фильтра key или nil, если его нет. Значение
+// другого типа добавляет ошибку в errs, как ошибка кодирования параметра в pgx.
+func filterValue[T any](filters map[string]interface{}, key string, errs *[]error) *T {
+	raw, ok := filters[key]
+	if !ok {
+		return nil
+	}
+	v, ok := raw.(T)
+	if !ok {
+		*errs = append(*errs, fmt.Errorf("unsupported value %v for filter %s", raw, key))
+		return nil
+	}
+	return &v
+}
+
+// matchList повторяет listConditions.
+func (f memoryFilters) matchList(sub *Subscription) bool {
+	return f.matchUsers(sub, false) &&
+		(f.serviceName == nil || sub.ServiceName == *f.serviceName) &&
+		(f.from == nil || !sub.StartDate.Before(*f.from)) &&
+		(f.to == nil || !sub.StartDate.After(*f.to))
+}
+
+// matchCost повторяет costConditions: пользователь может быть владельцем или участником.
+func (f memoryFilters) matchCost(sub *Subscription) bool {
+	return f.matchUsers(sub, true) &&
+		(f.serviceName == nil || sub.ServiceName == *f.serviceName) &&
+		(f.from == nil || sub.EndDate == nil || !sub.EndDate.Before(*f.from)) &&
+		(f.to == nil || !sub.StartDate.After(*f.to))
+}
+
+func (f memoryFilters) matchUsers(sub *Subscription, members bool) bool {
+	if f.userID != nil && !hasUser(sub, []string{*f.userID}, members) {
+		return false
+	}
+	return f.userIDs == nil || hasUser(sub, *f.userIDs, members)
+}
+
+func hasUser(sub *Subscription, userIDs []string, members bool) bool {
+	for _, userID := range userIDs {
+		userID = strings.ToLower(userID)
+		if sub.UserID == userID {
+			return true
+		}
+		for _, m := range sub.Members {
+			if members && m.UserID == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// datesOverlap сообщает, пересекаются ли периоды включительно; nil — бессрочно,
+// как daterange(start, end, '[]').
+func datesOverlap(aStart time.Time, aEnd *time.Time, bStart time.Time, bEnd *time.Time) bool {
+	return (bEnd == nil || !aStart.After(*bEnd)) && (aEnd == nil || !bStart.After(*aEnd))
+}
+
+// dateOf отбрасывает время, как при записи в колонку типа DATE.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func datePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	d := dateOf(*t)
+	return &d
+}
+
+func cloneSubscription(sub *Subscription) *Subscription {
+	c := *sub
+	c.EndDate = clonePtr(sub.EndDate)
+	c.CancelledAt = clonePtr(sub.CancelledAt)
+	c.PriceChanges = cloneAll(sub.PriceChanges, clonePtr[PriceChange])
+	c.Discounts = cloneAll(sub.Discounts, clonePtr[Discount])
+	c.Pauses = cloneAll(sub.Pauses, func(p *Pause) *Pause {
+		pc := *p
+		pc.EndDate = clonePtr(p.EndDate)
+		return &pc
+	})
+	c.Members = cloneAll(sub.Members, func(m *Member) *Member {
+		mc := *m
+		mc.SharePercent = clonePtr(m.SharePercent)
+		mc.FixedAmount = clonePtr(m.FixedAmount)
+		return &mc
+	})
+	return &c
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+func cloneAll[T any](items []*T, clone func(*T) *T) []*T {
+	if items == nil {
+		return nil
+	}
+	cloned := make([]*T, len(items))
+	for i, item := range items {
+		cloned[i] = clone(item)
+	}
+	return cloned
+}
+
+// newUUID возвращает случайный UUID версии 4, как gen_random_uuid().
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -2,6 +2,7 @@ package subscriptions
 
 import (
 	"errors"
+	"sort"
 	"strings"
 	"time"
 )
@@ -11,8 +12,76 @@ var (
 	ErrInvalidPrice       = errors.New("price must be positive")
 	ErrInvalidUserID      = errors.New("invalid user ID format")
 	ErrInvalidDateRange   = errors.New("end date must be after start date")
+
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrSubscriptionOverlaps = errors.New("subscription overlaps an existing subscription to the same service")
+	ErrInvalidEffectiveDate = errors.New("effective date must be after start date and within subscription lifetime")
+	ErrPriceChangeRequired  = errors.New("price cannot be changed in place because it applies to past months; schedule a price change from a given month instead")
+	ErrPauseOutOfRange      = errors.New("pause must lie within subscription lifetime")
+	ErrPauseOverlap         = errors.New("pause overlaps an existing pause")
+	ErrNotPaused            = errors.New("subscription is not paused in the given month")
+	ErrInvalidResumeDate    = errors.New("resume date must be after pause start")
+	ErrInvalidDiscount      = errors.New("discount must be a percentage between 1 and 100, a positive fixed amount or a trial without a value, lasting at least one period")
+	ErrDiscountOutOfRange   = errors.New("discount must start within subscription lifetime")
+	ErrTrialNotAtStart      = errors.New("trial must start with the first month of the subscription")
+	ErrDiscountOverlap      = errors.New("discount overlaps an existing discount")
+	ErrDiscountNotFound     = errors.New("discount not found")
+	ErrInvalidMemberShare   = errors.New("member must have either a share percent between 1 and 100 or a positive fixed amount")
+	ErrMemberIsOwner        = errors.New("subscription owner cannot be added as a member")
+	ErrMemberExists         = errors.New("user is already a member of the subscription")
+	ErrSharesExceedTotal    = errors.New("member shares exceed 100% of the subscription price")
+	ErrMemberNotFound       = errors.New("member not found")
+	ErrSubscriptionEnded    = errors.New("subscription has already ended")
+	ErrAlreadyCancelled     = errors.New("subscription is already cancelled")
+	ErrInvalidCancellation  = errors.New("invalid cancellation: unknown effective mode or reason")
+	ErrInvalidCancelDate    = errors.New("cancellation month must be between the current month and the subscription end")
+	ErrCancelBeforeStart    = errors.New("subscription would end before its first month; delete it instead of cancelling")
+	ErrDetailsOutOfRange    = errors.New("subscription dates must cover its scheduled price changes, pauses and discounts")
+)
+
+type DiscountKind string
+
+const (
+	DiscountPercentage DiscountKind = "percentage"
+	DiscountFixed      DiscountKind = "fixed"
+	// DiscountTrial makes the first months free; the subscription converts
+	// to its regular price when the trial ends.
+	DiscountTrial DiscountKind = "trial"
+)
+
+// CancelEffective defines when a cancellation takes effect.
+type CancelEffective string
+
+const (
+	// CancelImmediately stops billing before the current month.
+	CancelImmediately CancelEffective = "immediately"
+	// CancelEndOfPeriod keeps the current (already paid) month as the last one.
+	CancelEndOfPeriod CancelEffective = "end_of_period"
+	// CancelAtMonth ends the subscription with the given month.
+	CancelAtMonth CancelEffective = "month"
+)
+
+// CancellationReason is a structured reason for cancelling a subscription.
+type CancellationReason string
+
+const (
+	ReasonTooExpensive    CancellationReason = "too_expensive"
+	ReasonNotUsing        CancellationReason = "not_using"
+	ReasonSwitchedService CancellationReason = "switched_service"
+	ReasonMissingFeatures CancellationReason = "missing_features"
+	ReasonTechnicalIssues CancellationReason = "technical_issues"
+	ReasonTemporary       CancellationReason = "temporary"
+	ReasonOther           CancellationReason = "other"
 )
 
+// Cancellation is a request to cancel a subscription.
+type Cancellation struct {
+	Effective CancelEffective
+	Month     *time.Time
+	Reason    CancellationReason
+	Comment   string
+}
+
 type Subscription struct {
 	ID          string     `json:"id"`
 	ServiceName string     `json:"service_name"`
@@ -22,6 +91,82 @@ type Subscription struct {
 	EndDate     *time.Time `json:"end_date,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// ListPrice and EffectivePrice are the current month's price before and
+	// after discounts.
+	ListPrice      int `json:"list_price"`
+	EffectivePrice int `json:"effective_price"`
+
+	CancelledAt         *time.Time         `json:"cancelled_at,omitempty"`
+	CancellationReason  CancellationReason `json:"cancellation_reason,omitempty"`
+	CancellationComment string             `json:"cancellation_comment,omitempty"`
+
+	// PriceChanges is the schedule of price changes sorted by EffectiveFrom.
+	PriceChanges []*PriceChange `json:"-"`
+	// Pauses are the months when billing is suspended, sorted by StartDate.
+	Pauses []*Pause `json:"pauses,omitempty"`
+	// Discounts are promotional price reductions, sorted by StartDate.
+	Discounts []*Discount `json:"discounts,omitempty"`
+	// Members share the cost with the owner (UserID), who pays the remainder.
+	Members []*Member `json:"members,omitempty"`
+}
+
+// Member is a user sharing the subscription cost either by a percentage of
+// the monthly price or by a fixed monthly amount.
+type Member struct {
+	SubscriptionID string    `json:"subscription_id"`
+	UserID         string    `json:"user_id"`
+	SharePercent   *int      `json:"share_percent,omitempty"`
+	FixedAmount    *int      `json:"fixed_amount,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Overlap is a pair of one user's subscriptions to the same service that are
+// active in the same months, From to To inclusive (nil To means open-ended).
+type Overlap struct {
+	ServiceName string        `json:"service_name"`
+	From        time.Time     `json:"from"`
+	To          *time.Time    `json:"to,omitempty"`
+	First       *Subscription `json:"first"`
+	Second      *Subscription `json:"second"`
+}
+
+// Discount reduces the price for DurationPeriods months starting with StartDate.
+type Discount struct {
+	ID              string       `json:"id"`
+	SubscriptionID  string       `json:"subscription_id"`
+	Kind            DiscountKind `json:"kind"`
+	Value           int          `json:"value"`
+	StartDate       time.Time    `json:"start_date"`
+	DurationPeriods int          `json:"duration_periods"`
+	Description     string       `json:"description,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+// Pause suspends billing from StartDate to EndDate inclusive; a nil EndDate
+// means the subscription stays paused until resumed.
+type Pause struct {
+	ID             string     `json:"id"`
+	SubscriptionID string     `json:"subscription_id"`
+	StartDate      time.Time  `json:"start_date"`
+	EndDate        *time.Time `json:"end_date,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// PriceChange is a price in effect from the given month until the next change.
+type PriceChange struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscription_id"`
+	Price          int       `json:"price"`
+	EffectiveFrom  time.Time `json:"effective_from"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PriceSegment is one interval of the subscription price timeline.
+type PriceSegment struct {
+	Price         int        `json:"price"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to,omitempty"`
 }
 
 func NewSubscription(
@@ -32,13 +177,15 @@ func NewSubscription(
 	endDate *time.Time,
 ) (*Subscription, error) {
 	sub := &Subscription{
-		ServiceName: strings.TrimSpace(serviceName),
-		Price:       price,
-		UserID:      strings.TrimSpace(userID),
-		StartDate:   startDate,
-		EndDate:     endDate,
-		CreatedAt:   time.Now().UTC(),
-		UpdatedAt:   time.Now().UTC(),
+		ServiceName:    strings.TrimSpace(serviceName),
+		Price:          price,
+		UserID:         strings.TrimSpace(userID),
+		StartDate:      startDate,
+		EndDate:        endDate,
+		ListPrice:      price,
+		EffectivePrice: price,
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
 	}
 
 	if err := sub.Validate(); err != nil {
@@ -70,3 +217,443 @@ func (s *Subscription) Validate() error {
 
 	return nil
 }
+
+// ApplyUpdate переносит в подписку поля, изменяемые через PUT, после тех же
+// проверок, что и при создании. Цены, паузы, скидки и участники остаются
+// прежними, поэтому новые даты должны их покрывать (ErrDetailsOutOfRange,
+// ErrTrialNotAtStart), а новый владелец не может быть участником.
+func (s *Subscription) ApplyUpdate(u *Subscription) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	if u.Price != s.Price {
+		return ErrPriceChangeRequired
+	}
+
+	updated := *s
+	updated.ServiceName = u.ServiceName
+	updated.UserID = u.UserID
+	updated.StartDate = u.StartDate
+	updated.EndDate = u.EndDate
+
+	start := monthStart(updated.StartDate)
+	for _, pc := range s.PriceChanges {
+		if !pc.EffectiveFrom.After(start) || !updated.ActiveIn(pc.EffectiveFrom) {
+			return ErrDetailsOutOfRange
+		}
+	}
+	for _, p := range s.Pauses {
+		if !updated.ActiveIn(p.StartDate) || (p.EndDate != nil && !updated.ActiveIn(*p.EndDate)) {
+			return ErrDetailsOutOfRange
+		}
+	}
+	for _, d := range s.Discounts {
+		if !updated.ActiveIn(d.StartDate) {
+			return ErrDetailsOutOfRange
+		}
+		if d.Kind == DiscountTrial && !d.StartDate.Equal(start) {
+			return ErrTrialNotAtStart
+		}
+	}
+	for _, m := range s.Members {
+		if strings.EqualFold(m.UserID, updated.UserID) {
+			return ErrMemberIsOwner
+		}
+	}
+	if err := updated.checkShares(s.Members); err != nil {
+		return err
+	}
+
+	*s = updated
+	return nil
+}
+
+// SchedulePriceChange добавляет (или заменяет) изменение цены с указанного месяца.
+func (s *Subscription) SchedulePriceChange(price int, effectiveFrom time.Time) (*PriceChange, error) {
+	if price <= 0 {
+		return nil, ErrInvalidPrice
+	}
+
+	effectiveFrom = monthStart(effectiveFrom)
+	if !effectiveFrom.After(monthStart(s.StartDate)) {
+		return nil, ErrInvalidEffectiveDate
+	}
+	if s.EndDate != nil && effectiveFrom.After(monthStart(*s.EndDate)) {
+		return nil, ErrInvalidEffectiveDate
+	}
+
+	change := &PriceChange{
+		SubscriptionID: s.ID,
+		Price:          price,
+		EffectiveFrom:  effectiveFrom,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	changes := make([]*PriceChange, 0, len(s.PriceChanges)+1)
+	for _, pc := range s.PriceChanges {
+		if !pc.EffectiveFrom.Equal(effectiveFrom) {
+			changes = append(changes, pc)
+		}
+	}
+	changes = append(changes, change)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].EffectiveFrom.Before(changes[j].EffectiveFrom)
+	})
+
+	// Новая цена не должна оказаться меньше фиксированных долей участников
+	previous := s.PriceChanges
+	s.PriceChanges = changes
+	if err := s.checkShares(s.Members); err != nil {
+		s.PriceChanges = previous
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// PriceTimeline возвращает интервалы цен, начиная с базовой цены подписки.
+func (s *Subscription) PriceTimeline() []PriceSegment {
+	segments := []PriceSegment{{
+		Price:         s.Price,
+		EffectiveFrom: monthStart(s.StartDate),
+	}}
+
+	for _, pc := range s.PriceChanges {
+		if s.EndDate != nil && pc.EffectiveFrom.After(monthStart(*s.EndDate)) {
+			break
+		}
+		prevEnd := pc.EffectiveFrom.AddDate(0, -1, 0)
+		segments[len(segments)-1].EffectiveTo = &prevEnd
+		segments = append(segments, PriceSegment{
+			Price:         pc.Price,
+			EffectiveFrom: pc.EffectiveFrom,
+		})
+	}
+
+	if s.EndDate != nil {
+		end := monthStart(*s.EndDate)
+		segments[len(segments)-1].EffectiveTo = &end
+	}
+
+	return segments
+}
+
+// Covers сообщает, приостановлена ли подписка в указанном месяце.
+func (p *Pause) Covers(month time.Time) bool {
+	month = monthStart(month)
+	if month.Before(p.StartDate) {
+		return false
+	}
+	return p.EndDate == nil || !month.After(*p.EndDate)
+}
+
+// overlaps сообщает, пересекается ли пауза с интервалом [from, to]; nil to означает бессрочно.
+func (p *Pause) overlaps(from time.Time, to *time.Time) bool {
+	if to != nil && to.Before(p.StartDate) {
+		return false
+	}
+	return p.EndDate == nil || !from.After(*p.EndDate)
+}
+
+// Pause приостанавливает подписку с месяца from по месяц to включительно (nil — до возобновления).
+func (s *Subscription) Pause(from time.Time, to *time.Time) (*Pause, error) {
+	from = monthStart(from)
+	if to != nil {
+		end := monthStart(*to)
+		if end.Before(from) {
+			return nil, ErrInvalidDateRange
+		}
+		to = &end
+	}
+
+	if !s.ActiveIn(from) || (to != nil && !s.ActiveIn(*to)) {
+		return nil, ErrPauseOutOfRange
+	}
+
+	for _, p := range s.Pauses {
+		if p.overlaps(from, to) {
+			return nil, ErrPauseOverlap
+		}
+	}
+
+	pause := &Pause{
+		SubscriptionID: s.ID,
+		StartDate:      from,
+		EndDate:        to,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	s.Pauses = append(s.Pauses, pause)
+	sort.Slice(s.Pauses, func(i, j int) bool {
+		return s.Pauses[i].StartDate.Before(s.Pauses[j].StartDate)
+	})
+
+	return pause, nil
+}
+
+// Resume возобновляет подписку с указанного месяца, завершая паузу предыдущим месяцем.
+func (s *Subscription) Resume(month time.Time) (*Pause, error) {
+	month = monthStart(month)
+
+	for _, p := range s.Pauses {
+		if !p.Covers(month) {
+			continue
+		}
+		if !month.After(p.StartDate) {
+			return nil, ErrInvalidResumeDate
+		}
+		end := month.AddDate(0, -1, 0)
+		p.EndDate = &end
+		return p, nil
+	}
+
+	return nil, ErrNotPaused
+}
+
+// PausedIn сообщает, приостановлена ли подписка в указанном месяце.
+func (s *Subscription) PausedIn(month time.Time) bool {
+	for _, p := range s.Pauses {
+		if p.Covers(month) {
+			return true
+		}
+	}
+	return false
+}
+
+// EndDate возвращает последний месяц действия скидки.
+func (d *Discount) EndDate() time.Time {
+	return d.StartDate.AddDate(0, d.DurationPeriods-1, 0)
+}
+
+// Covers сообщает, действует ли скидка в указанном месяце.
+func (d *Discount) Covers(month time.Time) bool {
+	month = monthStart(month)
+	return !month.Before(d.StartDate) && !month.After(d.EndDate())
+}
+
+// Apply возвращает цену после скидки; цена не может стать отрицательной.
+func (d *Discount) Apply(price int) int {
+	switch d.Kind {
+	case DiscountPercentage:
+		return price - price*d.Value/100
+	case DiscountFixed:
+		return max(price-d.Value, 0)
+	case DiscountTrial:
+		return 0
+	}
+	return price
+}
+
+func (d *Discount) Validate() error {
+	switch d.Kind {
+	case DiscountPercentage:
+		if d.Value < 1 || d.Value > 100 {
+			return ErrInvalidDiscount
+		}
+	case DiscountFixed:
+		if d.Value <= 0 {
+			return ErrInvalidDiscount
+		}
+	case DiscountTrial:
+		if d.Value != 0 {
+			return ErrInvalidDiscount
+		}
+	default:
+		return ErrInvalidDiscount
+	}
+
+	if d.DurationPeriods < 1 {
+		return ErrInvalidDiscount
+	}
+
+	return nil
+}
+
+// AddDiscount проверяет скидку и добавляет её к подписке. Скидки не суммируются,
+// поэтому их интервалы не должны пересекаться.
+func (s *Subscription) AddDiscount(d *Discount) error {
+	d.SubscriptionID = s.ID
+	d.StartDate = monthStart(d.StartDate)
+	d.Description = strings.TrimSpace(d.Description)
+
+	if err := d.Validate(); err != nil {
+		return err
+	}
+
+	if !s.ActiveIn(d.StartDate) {
+		return ErrDiscountOutOfRange
+	}
+	// Пробный период бывает только в начале подписки, после него она становится платной
+	if d.Kind == DiscountTrial && !d.StartDate.Equal(monthStart(s.StartDate)) {
+		return ErrTrialNotAtStart
+	}
+
+	for _, existing := range s.Discounts {
+		if !d.StartDate.After(existing.EndDate()) && !existing.StartDate.After(d.EndDate()) {
+			return ErrDiscountOverlap
+		}
+	}
+
+	s.Discounts = append(s.Discounts, d)
+	sort.Slice(s.Discounts, func(i, j int) bool {
+		return s.Discounts[i].StartDate.Before(s.Discounts[j].StartDate)
+	})
+
+	return nil
+}
+
+// RefreshPricing пересчитывает ListPrice и EffectivePrice на месяц now,
+// ограниченный сроком действия подписки.
+func (s *Subscription) RefreshPricing(now time.Time) {
+	month := monthStart(now)
+	if start := monthStart(s.StartDate); month.Before(start) {
+		month = start
+	}
+	if s.EndDate != nil {
+		if end := monthStart(*s.EndDate); month.After(end) {
+			month = end
+		}
+	}
+
+	s.ListPrice = s.PriceAt(month)
+	s.EffectivePrice = s.DiscountedPriceAt(month)
+}
+
+func (m *Member) Validate() error {
+	m.UserID = strings.TrimSpace(m.UserID)
+	if len(m.UserID) != 36 {
+		return ErrInvalidUserID
+	}
+
+	switch {
+	case m.SharePercent != nil && m.FixedAmount == nil:
+		if *m.SharePercent < 1 || *m.SharePercent > 100 {
+			return ErrInvalidMemberShare
+		}
+	case m.FixedAmount != nil && m.SharePercent == nil:
+		if *m.FixedAmount <= 0 {
+			return ErrInvalidMemberShare
+		}
+	default:
+		return ErrInvalidMemberShare
+	}
+
+	return nil
+}
+
+// AddMember добавляет участника. Участники платят свои доли, а владелец —
+// остаток цены, поэтому доли всех, включая владельца, в сумме всегда дают
+// 100%. Проверяется, что остаток не отрицателен: проценты участников вместе
+// с фиксированными суммами, пересчитанными в проценты от цены, не превышают
+// 100% ни в одном интервале цен подписки.
+func (s *Subscription) AddMember(m *Member) error {
+	m.SubscriptionID = s.ID
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	if m.UserID == s.UserID {
+		return ErrMemberIsOwner
+	}
+	for _, existing := range s.Members {
+		if existing.UserID == m.UserID {
+			return ErrMemberExists
+		}
+	}
+
+	members := append(s.Members[:len(s.Members):len(s.Members)], m)
+	if err := s.checkShares(members); err != nil {
+		return err
+	}
+
+	s.Members = members
+	return nil
+}
+
+// checkShares проверяет, что доли участников помещаются в каждую цену из
+// истории подписки: участники действуют на весь её срок, и запланированное
+// снижение цены не должно оставить фиксированные суммы больше цены.
+func (s *Subscription) checkShares(members []*Member) error {
+	percent, fixed := 0, 0
+	for _, m := range members {
+		if m.SharePercent != nil {
+			percent += *m.SharePercent
+		}
+		if m.FixedAmount != nil {
+			fixed += *m.FixedAmount
+		}
+	}
+	if percent == 0 && fixed == 0 {
+		return nil
+	}
+
+	for _, segment := range s.PriceTimeline() {
+		// percent + fixed/price·100 > 100 без деления и округления
+		if percent*segment.Price+fixed*100 > 100*segment.Price {
+			return ErrSharesExceedTotal
+		}
+	}
+	return nil
+}
+
+func (r CancellationReason) Valid() bool {
+	switch r {
+	case ReasonTooExpensive, ReasonNotUsing, ReasonSwitchedService, ReasonMissingFeatures,
+		ReasonTechnicalIssues, ReasonTemporary, ReasonOther:
+		return true
+	}
+	return false
+}
+
+// Cancel отменяет подписку, устанавливая последний оплачиваемый месяц:
+// immediately — предыдущий месяц, end_of_period — текущий, month — указанный.
+// Дата окончания не может стать раньше начала подписки (ErrCancelBeforeStart)
+// или позже уже заданной.
+func (s *Subscription) Cancel(c Cancellation, now time.Time) error {
+	if !c.Reason.Valid() {
+		return ErrInvalidCancellation
+	}
+
+	current := monthStart(now)
+	if s.CancelledAt != nil {
+		return ErrAlreadyCancelled
+	}
+	if s.EndDate != nil && monthStart(*s.EndDate).Before(current) {
+		return ErrSubscriptionEnded
+	}
+
+	var end time.Time
+	switch c.Effective {
+	case CancelImmediately:
+		end = current.AddDate(0, -1, 0)
+	case CancelEndOfPeriod:
+		end = current
+	case CancelAtMonth:
+		if c.Month == nil {
+			return ErrInvalidCancelDate
+		}
+		end = monthStart(*c.Month)
+		if end.Before(current) || (s.EndDate != nil && end.After(monthStart(*s.EndDate))) {
+			return ErrInvalidCancelDate
+		}
+	default:
+		return ErrInvalidCancellation
+	}
+
+	// Подписка, ещё не начавшаяся к последнему оплачиваемому месяцу, не должна
+	// тарифицироваться вовсе; сдвиг окончания на месяц начала выставил бы его к оплате
+	if end.Before(monthStart(s.StartDate)) {
+		return ErrCancelBeforeStart
+	}
+	if s.EndDate != nil && end.After(monthStart(*s.EndDate)) {
+		end = monthStart(*s.EndDate)
+	}
+
+	cancelledAt := now.UTC()
+	s.EndDate = &end
+	s.CancelledAt = &cancelledAt
+	s.CancellationReason = c.Reason
+	s.CancellationComment = strings.TrimSpace(c.Comment)
+
+	return nil
+}
@@ -0,0 +1,282 @@
+package subscriptions
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionCancel(t *testing.T) {
+	now := time.Date(2025, time.June, 17, 12, 0, 0, 0, time.UTC)
+	month := func(m time.Month, year int) *time.Time {
+		d := time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+
+	tests := []struct {
+		name    string
+		start   time.Time
+		end     *time.Time
+		cancel  Cancellation
+		wantEnd *time.Time
+		wantErr error
+	}{
+		{
+			name:    "immediately ends with the previous month",
+			start:   *month(time.January, 2025),
+			cancel:  Cancellation{Effective: CancelImmediately, Reason: ReasonNotUsing},
+			wantEnd: month(time.May, 2025),
+		},
+		{
+			name:    "immediately in the first month",
+			start:   *month(time.June, 2025),
+			cancel:  Cancellation{Effective: CancelImmediately, Reason: ReasonNotUsing},
+			wantErr: ErrCancelBeforeStart,
+		},
+		{
+			name:    "immediately before the start",
+			start:   *month(time.September, 2025),
+			cancel:  Cancellation{Effective: CancelImmediately, Reason: ReasonNotUsing},
+			wantErr: ErrCancelBeforeStart,
+		},
+		{
+			name:    "end of period in the first month",
+			start:   *month(time.June, 2025),
+			cancel:  Cancellation{Effective: CancelEndOfPeriod, Reason: ReasonTemporary},
+			wantEnd: month(time.June, 2025),
+		},
+		{
+			name:    "end of period before the start",
+			start:   *month(time.September, 2025),
+			cancel:  Cancellation{Effective: CancelEndOfPeriod, Reason: ReasonTemporary},
+			wantErr: ErrCancelBeforeStart,
+		},
+		{
+			name:    "month before the start",
+			start:   *month(time.September, 2025),
+			cancel:  Cancellation{Effective: CancelAtMonth, Reason: ReasonOther, Month: month(time.July, 2025)},
+			wantErr: ErrCancelBeforeStart,
+		},
+		{
+			name:    "month after the end",
+			start:   *month(time.January, 2025),
+			end:     month(time.August, 2025),
+			cancel:  Cancellation{Effective: CancelAtMonth, Reason: ReasonOther, Month: month(time.October, 2025)},
+			wantErr: ErrInvalidCancelDate,
+		},
+		{
+			name:    "end of period keeps an earlier end",
+			start:   *month(time.January, 2025),
+			end:     month(time.June, 2025),
+			cancel:  Cancellation{Effective: CancelEndOfPeriod, Reason: ReasonOther},
+			wantEnd: month(time.June, 2025),
+		},
+		{
+			name:    "already ended",
+			start:   *month(time.January, 2025),
+			end:     month(time.March, 2025),
+			cancel:  Cancellation{Effective: CancelEndOfPeriod, Reason: ReasonOther},
+			wantErr: ErrSubscriptionEnded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &Subscription{Price: 500, StartDate: tt.start, EndDate: tt.end}
+			err := sub.Cancel(tt.cancel, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if sub.CancelledAt != nil || sub.EndDate != tt.end {
+					t.Fatalf("rejected cancellation changed the subscription: end %v, cancelled at %v", sub.EndDate, sub.CancelledAt)
+				}
+				return
+			}
+			if sub.EndDate == nil || !sub.EndDate.Equal(*tt.wantEnd) || sub.CancelledAt == nil {
+				t.Fatalf("end %v, cancelled at %v, want end %v", sub.EndDate, sub.CancelledAt, *tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestSubscriptionAddMember(t *testing.T) {
+	owner, first, second := testUsers[0], testUsers[1], testUsers[2]
+
+	tests := []struct {
+		name     string
+		changes  []*PriceChange
+		existing []*Member
+		member   *Member
+		wantErr  error
+	}{
+		{name: "percent up to 100%", existing: []*Member{{UserID: first, SharePercent: intPtr(40)}}, member: &Member{UserID: second, SharePercent: intPtr(60)}},
+		{name: "percent over 100%", existing: []*Member{{UserID: first, SharePercent: intPtr(40)}}, member: &Member{UserID: second, SharePercent: intPtr(61)}, wantErr: ErrSharesExceedTotal},
+		{name: "fixed up to the price", existing: []*Member{{UserID: first, FixedAmount: intPtr(300)}}, member: &Member{UserID: second, FixedAmount: intPtr(200)}},
+		{name: "fixed over the price", existing: []*Member{{UserID: first, FixedAmount: intPtr(300)}}, member: &Member{UserID: second, FixedAmount: intPtr(201)}, wantErr: ErrSharesExceedTotal},
+		{name: "fixed and percent up to 100%", existing: []*Member{{UserID: first, FixedAmount: intPtr(250)}}, member: &Member{UserID: second, SharePercent: intPtr(50)}},
+		{name: "fixed and percent over 100%", existing: []*Member{{UserID: first, FixedAmount: intPtr(250)}}, member: &Member{UserID: second, SharePercent: intPtr(51)}, wantErr: ErrSharesExceedTotal},
+		{name: "percent and fixed over 100%", existing: []*Member{{UserID: first, SharePercent: intPtr(90)}}, member: &Member{UserID: second, FixedAmount: intPtr(51)}, wantErr: ErrSharesExceedTotal},
+		{name: "owner", member: &Member{UserID: owner, SharePercent: intPtr(10)}, wantErr: ErrMemberIsOwner},
+		{name: "existing member", existing: []*Member{{UserID: first, SharePercent: intPtr(10)}}, member: &Member{UserID: first, SharePercent: intPtr(10)}, wantErr: ErrMemberExists},
+		{name: "fixed over a later price", changes: []*PriceChange{{Price: 300, EffectiveFrom: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)}}, existing: []*Member{{UserID: first, FixedAmount: intPtr(200)}}, member: &Member{UserID: second, FixedAmount: intPtr(101)}, wantErr: ErrSharesExceedTotal},
+		{name: "both shares", member: &Member{UserID: first, SharePercent: intPtr(10), FixedAmount: intPtr(10)}, wantErr: ErrInvalidMemberShare},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &Subscription{ID: "sub", UserID: owner, Price: 500, ListPrice: 500, StartDate: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+				PriceChanges: tt.changes, Members: tt.existing}
+			err := sub.AddMember(tt.member)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			want := len(tt.existing)
+			if tt.wantErr == nil {
+				want++
+			}
+			if len(sub.Members) != want {
+				t.Fatalf("%d members, want %d", len(sub.Members), want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionSchedulePriceChange(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)
+	members := []*Member{
+		{UserID: testUsers[1], FixedAmount: intPtr(200)},
+		{UserID: testUsers[2], SharePercent: intPtr(50)},
+	}
+
+	tests := []struct {
+		name    string
+		price   int
+		from    time.Time
+		wantErr error
+	}{
+		{name: "higher price", price: 800, from: start.AddDate(0, 3, 0)},
+		// 200 из 400 — 50%, вместе с 50% ровно 100%
+		{name: "lower price fitting the shares", price: 400, from: start.AddDate(0, 3, 0)},
+		{name: "lower price below the shares", price: 399, from: start.AddDate(0, 3, 0), wantErr: ErrSharesExceedTotal},
+		{name: "first month", price: 600, from: start, wantErr: ErrInvalidEffectiveDate},
+		{name: "after the end", price: 600, from: end.AddDate(0, 1, 0), wantErr: ErrInvalidEffectiveDate},
+		{name: "non-positive price", price: 0, from: start.AddDate(0, 3, 0), wantErr: ErrInvalidPrice},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &Subscription{ID: "sub", UserID: testUsers[0], Price: 500, StartDate: start, EndDate: &end, Members: members}
+			_, err := sub.SchedulePriceChange(tt.price, tt.from)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			want := 1
+			if tt.wantErr != nil {
+				want = 0
+			}
+			if len(sub.PriceChanges) != want {
+				t.Fatalf("%d price changes, want %d", len(sub.PriceChanges), want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionApplyUpdate(t *testing.T) {
+	at := func(m time.Month) time.Time { return time.Date(2025, m, 1, 0, 0, 0, 0, time.UTC) }
+	atPtr := func(m time.Month) *time.Time { month := at(m); return &month }
+	start := at(time.January)
+
+	tests := []struct {
+		name      string
+		changes   []*PriceChange
+		pauses    []*Pause
+		discounts []*Discount
+		update    func(u *Subscription)
+		wantErr   error
+	}{
+		{name: "new owner and service", update: func(u *Subscription) { u.UserID = testUsers[3]; u.ServiceName = "Okko" }},
+		{name: "invalid service name", update: func(u *Subscription) { u.ServiceName = "X" }, wantErr: ErrInvalidServiceName},
+		{name: "changed price", update: func(u *Subscription) { u.Price = 600 }, wantErr: ErrPriceChangeRequired},
+		{name: "member becomes the owner", update: func(u *Subscription) { u.UserID = testUsers[1] }, wantErr: ErrMemberIsOwner},
+		{
+			name:    "dates covering a price change",
+			changes: []*PriceChange{{Price: 600, EffectiveFrom: at(time.March)}},
+			update:  func(u *Subscription) { u.StartDate = at(time.February); u.EndDate = atPtr(time.March) },
+		},
+		{
+			name:    "start at a price change",
+			changes: []*PriceChange{{Price: 600, EffectiveFrom: at(time.March)}},
+			update:  func(u *Subscription) { u.StartDate = at(time.March) },
+			wantErr: ErrDetailsOutOfRange,
+		},
+		{
+			name:    "end before a price change",
+			changes: []*PriceChange{{Price: 600, EffectiveFrom: at(time.March)}},
+			update:  func(u *Subscription) { u.EndDate = atPtr(time.February) },
+			wantErr: ErrDetailsOutOfRange,
+		},
+		{
+			name:    "start after a pause start",
+			pauses:  []*Pause{{StartDate: at(time.March), EndDate: atPtr(time.April)}},
+			update:  func(u *Subscription) { u.StartDate = at(time.April) },
+			wantErr: ErrDetailsOutOfRange,
+		},
+		{
+			name:    "end inside a pause",
+			pauses:  []*Pause{{StartDate: at(time.May), EndDate: atPtr(time.July)}},
+			update:  func(u *Subscription) { u.EndDate = atPtr(time.June) },
+			wantErr: ErrDetailsOutOfRange,
+		},
+		{
+			name:   "end during an open pause",
+			pauses: []*Pause{{StartDate: at(time.May)}},
+			update: func(u *Subscription) { u.EndDate = atPtr(time.June) },
+		},
+		{
+			name:      "start after a discount start",
+			discounts: []*Discount{{Kind: DiscountPercentage, Value: 10, StartDate: at(time.February), DurationPeriods: 2}},
+			update:    func(u *Subscription) { u.StartDate = at(time.March) },
+			wantErr:   ErrDetailsOutOfRange,
+		},
+		{
+			name:      "trial no longer at the start",
+			discounts: []*Discount{{Kind: DiscountTrial, StartDate: start, DurationPeriods: 1}},
+			update:    func(u *Subscription) { u.StartDate = start.AddDate(0, -1, 0) },
+			wantErr:   ErrTrialNotAtStart,
+		},
+		{
+			name:      "trial start within the first month",
+			discounts: []*Discount{{Kind: DiscountTrial, StartDate: start, DurationPeriods: 1}},
+			update:    func(u *Subscription) { u.StartDate = start.AddDate(0, 0, 14) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &Subscription{
+				ID: "sub", ServiceName: "Netflix", UserID: testUsers[0], Price: 500, StartDate: start,
+				PriceChanges: tt.changes,
+				Pauses:       tt.pauses,
+				Discounts:    tt.discounts,
+				Members:      []*Member{{UserID: testUsers[1], SharePercent: intPtr(50)}},
+			}
+			u := &Subscription{ID: sub.ID, ServiceName: sub.ServiceName, UserID: sub.UserID, Price: sub.Price, StartDate: sub.StartDate}
+			tt.update(u)
+
+			err := sub.ApplyUpdate(u)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			want := u
+			if tt.wantErr != nil {
+				want = &Subscription{ServiceName: "Netflix", UserID: testUsers[0], StartDate: start}
+			}
+			if sub.ServiceName != want.ServiceName || sub.UserID != want.UserID ||
+				!sub.StartDate.Equal(want.StartDate) || (sub.EndDate == nil) != (want.EndDate == nil) {
+				t.Fatalf("got %+v, want %+v", sub, want)
+			}
+		})
+	}
+}
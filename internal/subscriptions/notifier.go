@@ -0,0 +1,44 @@
+package subscriptions
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	EventBudgetExceeded = "budget.exceeded"
+)
+
+// Event is a notification about something the user should know about.
+type Event struct {
+	Type       string    `json:"type"`
+	UserID     string    `json:"user_id"`
+	Payload    any       `json:"payload"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Notifier delivers events to users (email, push, webhooks).
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// LogNotifier пишет события в лог; используется, пока не подключён реальный канал доставки.
+type LogNotifier struct {
+	logger *zap.Logger
+}
+
+func NewLogNotifier(logger *zap.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(_ context.Context, event Event) error {
+	n.logger.Info("notification",
+		zap.String("type", event.Type),
+		zap.String("user_id", event.UserID),
+		zap.Any("payload", event.Payload),
+		zap.Time("occurred_at", event.OccurredAt),
+	)
+	return nil
+}
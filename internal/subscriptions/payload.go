@@ -15,3 +15,43 @@ type UpdateSubscriptionRequest struct {
 	StartDate   string `json:"start_date" binding:"required"`
 	EndDate     string `json:"end_date,omitempty"`
 }
+
+type SchedulePriceChangeRequest struct {
+	Price         int    `json:"price" binding:"required,min=1"`
+	EffectiveFrom string `json:"effective_from" binding:"required"`
+}
+
+type PauseSubscriptionRequest struct {
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date,omitempty"`
+}
+
+type ResumeSubscriptionRequest struct {
+	ResumeDate string `json:"resume_date,omitempty"`
+}
+
+type AddDiscountRequest struct {
+	Kind            string `json:"kind" binding:"required,oneof=percentage fixed trial"`
+	Value           int    `json:"value" binding:"min=0"`
+	StartDate       string `json:"start_date" binding:"required"`
+	DurationPeriods int    `json:"duration_periods" binding:"required,min=1"`
+	Description     string `json:"description,omitempty" binding:"max=255"`
+}
+
+type AddMemberRequest struct {
+	UserID       string `json:"user_id" binding:"required,uuid"`
+	SharePercent *int   `json:"share_percent,omitempty" binding:"omitempty,min=1,max=100"`
+	FixedAmount  *int   `json:"fixed_amount,omitempty" binding:"omitempty,min=1"`
+}
+
+type BudgetRequest struct {
+	ServiceName *string `json:"service_name,omitempty" binding:"omitempty,min=2,max=100"`
+	Amount      int     `json:"amount" binding:"required,min=1"`
+}
+
+type CancelSubscriptionRequest struct {
+	Effective string `json:"effective" binding:"required,oneof=immediately end_of_period month"`
+	Month     string `json:"month,omitempty"`
+	Reason    string `json:"reason" binding:"required"`
+	Comment   string `json:"comment,omitempty" binding:"max=1000"`
+}
@@ -0,0 +1,121 @@
+package subscriptions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type ReportHandler struct {
+	logger     *zap.Logger
+	repo       ISubscriptionRepository
+	forecaster *Forecaster
+}
+
+func NewReportHandler(logger *zap.Logger, repo ISubscriptionRepository, forecaster *Forecaster) *ReportHandler {
+	return &ReportHandler{
+		logger:     logger,
+		repo:       repo,
+		forecaster: forecaster,
+	}
+}
+
+func (h *ReportHandler) RegisterRoutes(router *gin.Engine) {
+	api := router.Group("/api/v1")
+	{
+		reports := api.Group("/reports")
+		{
+			reports.GET("/forecast", h.Forecast)
+			reports.GET("/churn", h.Churn)
+		}
+	}
+}
+
+// Forecast godoc
+// @Summary Forecast spend for the next months
+// @Description Projects monthly spend of active subscriptions starting with the current month
+// @Tags Reports
+// @Produce json
+// @Param months query int false "Number of months (1-60, default 12)"
+// @Param user_id query string false "User ID"
+// @Param service_name query string false "Service Name"
+// @Success 200 {object} ForecastReport
+// @Failure 400,500 {object} gin.H
+// @Router /reports/forecast [get]
+func (h *ReportHandler) Forecast(c *gin.Context) {
+	months := 12
+	if m := c.Query("months"); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidForecastMonths.Error()})
+			return
+		}
+		months = n
+	}
+
+	filters := make(map[string]interface{})
+	if serviceName := c.Query("service_name"); serviceName != "" {
+		filters["service_name"] = serviceName
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		filters["user_id"] = userID
+	}
+
+	report, err := h.forecaster.Forecast(c.Request.Context(), filters, months)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, report)
+	case errors.Is(err, ErrInvalidForecastMonths):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("failed to build forecast", zap.Error(err))
+		serverError(c, err, "failed to build forecast")
+	}
+}
+
+// Churn godoc
+// @Summary Cancellation statistics by service and reason
+// @Tags Reports
+// @Produce json
+// @Param service_name query string false "Service Name"
+// @Param from query string false "First cancellation month MM-YYYY"
+// @Param to query string false "Last cancellation month MM-YYYY"
+// @Success 200 {object} ChurnReport
+// @Failure 400,500 {object} gin.H
+// @Router /reports/churn [get]
+func (h *ReportHandler) Churn(c *gin.Context) {
+	filters := make(map[string]interface{})
+
+	if serviceName := c.Query("service_name"); serviceName != "" {
+		filters["service_name"] = serviceName
+	}
+	if from := c.Query("from"); from != "" {
+		date, err := time.Parse("01-2006", from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from format, use MM-YYYY"})
+			return
+		}
+		filters["cancelled_from"] = date
+	}
+	if to := c.Query("to"); to != "" {
+		date, err := time.Parse("01-2006", to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to format, use MM-YYYY"})
+			return
+		}
+		filters["cancelled_to"] = date
+	}
+
+	stats, err := h.repo.ChurnStats(c.Request.Context(), filters)
+	if err != nil {
+		h.logger.Error("failed to collect churn statistics", zap.Error(err))
+		serverError(c, err, "failed to collect churn statistics")
+		return
+	}
+
+	c.JSON(http.StatusOK, BuildChurnReport(stats))
+}
@@ -2,11 +2,15 @@ package subscriptions
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"SubscriptionService/internal/logging"
+	"SubscriptionService/pkg/db"
+
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
@@ -18,19 +22,91 @@ type ISubscriptionRepository interface {
 	Update(ctx context.Context, sub *Subscription) error
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, filters map[string]interface{}) ([]*Subscription, error)
+	// StreamList выбирает подписки как List, но передаёт их fn по одной по мере чтения,
+	// не накапливая результат; ошибка fn прекращает выборку и возвращается как есть.
+	StreamList(ctx context.Context, filters map[string]interface{}, fn func(*Subscription) error) error
 	CalculateMonthlyCost(ctx context.Context, filters map[string]interface{}) (int, error)
+	ListForPeriod(ctx context.Context, filters map[string]interface{}) ([]*Subscription, error)
+	FindDuplicates(ctx context.Context, userID string) ([]*Overlap, error)
+	FindOverlapping(ctx context.Context, sub *Subscription) ([]*Subscription, error)
+	// CreateNonOverlapping создаёт подписку, только если у пользователя нет
+	// пересекающейся с ней по датам подписки на тот же сервис; иначе возвращает
+	// пересечения и ErrSubscriptionOverlaps. Проверка и вставка атомарны.
+	CreateNonOverlapping(ctx context.Context, sub *Subscription) ([]*Subscription, error)
+	Cancel(ctx context.Context, subscriptionID string, cancellation Cancellation) (*Subscription, error)
+	ChurnStats(ctx context.Context, filters map[string]interface{}) ([]*ChurnStat, error)
+	// AddPriceChange проверяет изменение по текущему состоянию подписки (см.
+	// Subscription.SchedulePriceChange) и сохраняет его в той же транзакции.
+	AddPriceChange(ctx context.Context, change *PriceChange) error
+	ListPriceChanges(ctx context.Context, subscriptionID string) ([]*PriceChange, error)
+	Pause(ctx context.Context, subscriptionID string, from time.Time, to *time.Time) (*Pause, error)
+	Resume(ctx context.Context, subscriptionID string, month time.Time) (*Pause, error)
+	AddDiscount(ctx context.Context, subscriptionID string, discount *Discount) error
+	RemoveDiscount(ctx context.Context, subscriptionID, discountID string) error
+	AddMember(ctx context.Context, subscriptionID string, member *Member) error
+	RemoveMember(ctx context.Context, subscriptionID, userID string) error
+	// PurgeEnded удаляет подписки, закончившиеся раньше месяца before, и возвращает их количество.
+	PurgeEnded(ctx context.Context, before time.Time) (int64, error)
+	// MonthlySpend возвращает суммы по сервисам и месяцам из агрегата monthly_spend
+	// или ok=false, если фильтры или период агрегат не поддерживает.
+	MonthlySpend(ctx context.Context, filters map[string]interface{}) ([]*MonthlySpend, bool, error)
 }
 
+// SubscriptionRepository пишет в primary, а запросы только на чтение отправляет
+// через router в реплики; после записи в том же запросе чтение идёт в primary.
 type SubscriptionRepository struct {
 	db     *pgxpool.Pool
+	router *db.Router
 	logger *zap.Logger
 }
 
-func NewSubscriptionRepository(db *pgxpool.Pool, logger *zap.Logger) *SubscriptionRepository {
-	return &SubscriptionRepository{db: db, logger: logger}
+func NewSubscriptionRepository(router *db.Router, logger *zap.Logger) *SubscriptionRepository {
+	return &SubscriptionRepository{db: router.Primary(), router: router, logger: logger}
+}
+
+// log возвращает логгер запроса из ctx, чтобы ошибки БД содержали request_id
+func (s *SubscriptionRepository) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, s.logger)
 }
 
 func (s *SubscriptionRepository) Create(ctx context.Context, sub *Subscription) error {
+	return s.withSpend(ctx, func() string { return sub.ID }, func(tx pgx.Tx) error {
+		return s.insert(ctx, tx, sub)
+	})
+}
+
+// CreateNonOverlapping выполняет проверку пересечений и вставку в одной
+// транзакции под advisory lock пользователя и сервиса: параллельное создание
+// той же подписки ждёт фиксации первой и видит её при своей проверке.
+func (s *SubscriptionRepository) CreateNonOverlapping(ctx context.Context, sub *Subscription) ([]*Subscription, error) {
+	var conflicts []*Subscription
+	err := s.withSpend(ctx, func() string { return sub.ID }, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended(lower($1::text) || '/' || lower($2), 0))`,
+			sub.UserID, sub.ServiceName); err != nil {
+			s.log(ctx).Error("failed to lock subscriptions of user and service",
+				zap.Error(err),
+				zap.String("service", sub.ServiceName),
+				zap.String("user", sub.UserID))
+			return fmt.Errorf("failed to create subscription: %w", err)
+		}
+
+		var err error
+		conflicts, err = s.findOverlapping(ctx, tx, sub)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return ErrSubscriptionOverlaps
+		}
+		return s.insert(ctx, tx, sub)
+	})
+	if errors.Is(err, ErrSubscriptionOverlaps) {
+		return conflicts, err
+	}
+	return nil, err
+}
+
+func (s *SubscriptionRepository) insert(ctx context.Context, tx pgx.Tx, sub *Subscription) error {
 	query := `
 		INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date)
 		VALUES ($1, $2, $3, $4, $5)
@@ -41,7 +117,7 @@ func (s *SubscriptionRepository) Create(ctx context.Context, sub *Subscription)
 		endDate = *sub.EndDate
 	}
 
-	err := s.db.QueryRow(ctx, query,
+	err := tx.QueryRow(ctx, query,
 		sub.ServiceName,
 		sub.Price,
 		sub.UserID,
@@ -50,7 +126,7 @@ func (s *SubscriptionRepository) Create(ctx context.Context, sub *Subscription)
 	).Scan(&sub.ID)
 
 	if err != nil {
-		s.logger.Error("failed to create subscription",
+		s.log(ctx).Error("failed to create subscription",
 			zap.Error(err),
 			zap.String("service", sub.ServiceName),
 			zap.String("user", sub.UserID))
@@ -61,111 +137,139 @@ func (s *SubscriptionRepository) Create(ctx context.Context, sub *Subscription)
 }
 
 func (s *SubscriptionRepository) GetByID(ctx context.Context, id string) (*Subscription, error) {
+	pool := s.router.Read(ctx)
+
 	query := `
-		SELECT id, service_name, price, user_id, start_date, end_date
+		SELECT id, service_name, price, user_id, start_date, end_date,
+		       cancelled_at, cancellation_reason, cancellation_comment
 		FROM subscriptions 
 		WHERE id = $1`
 
 	sub := &Subscription{}
 	var endDate *time.Time
 
-	err := s.db.QueryRow(ctx, query, id).Scan(
+	err := pool.QueryRow(ctx, query, id).Scan(
 		&sub.ID,
 		&sub.ServiceName,
 		&sub.Price,
 		&sub.UserID,
 		&sub.StartDate,
 		&endDate,
+		&sub.CancelledAt,
+		&sub.CancellationReason,
+		&sub.CancellationComment,
 	)
 
 	sub.EndDate = endDate
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("subscription not found")
+			return nil, ErrSubscriptionNotFound
 		}
-		s.logger.Error("failed to get subscription",
+		s.log(ctx).Error("failed to get subscription",
 			zap.Error(err),
 			zap.String("id", id))
 		return nil, fmt.Errorf("failed to get subscription: %w", err)
 	}
 
+	if err := s.attachDetails(ctx, pool, []*Subscription{sub}); err != nil {
+		return nil, err
+	}
+
 	return sub, nil
 }
 
+// Update меняет поля подписки, кроме цены: базовая цена действует с первого месяца,
+// поэтому её изменение пересчитало бы прошлые месяцы. Новая цена задаётся через
+// AddPriceChange, а отличающаяся цена в sub отклоняется с ErrPriceChangeRequired.
+// Новые даты и владелец проверяются по заблокированной строке вместе с ценами,
+// паузами, скидками и участниками (Subscription.ApplyUpdate).
 func (s *SubscriptionRepository) Update(ctx context.Context, sub *Subscription) error {
 	query := `
 		UPDATE subscriptions 
-		SET service_name = $1, price = $2, user_id = $3, 
-			start_date = $4, end_date = $5
-		WHERE id = $6`
-
-	var endDate interface{} = nil
-	if sub.EndDate != nil {
-		endDate = *sub.EndDate
-	}
-
-	result, err := s.db.Exec(ctx, query,
-		sub.ServiceName,
-		sub.Price,
-		sub.UserID,
-		sub.StartDate,
-		endDate,
-		sub.ID,
-	)
+		SET service_name = $1, user_id = $2, 
+			start_date = $3, end_date = $4
+		WHERE id = $5`
+
+	return s.withSpend(ctx, func() string { return sub.ID }, func(tx pgx.Tx) error {
+		stored, err := s.getForUpdate(ctx, tx, sub.ID)
+		if err != nil {
+			return err
+		}
+		if err := stored.ApplyUpdate(sub); err != nil {
+			return err
+		}
 
-	if err != nil {
-		s.logger.Error("failed to update subscription",
-			zap.Error(err),
-			zap.String("id", sub.ID))
-		return fmt.Errorf("failed to update subscription: %w", err)
-	}
+		var endDate interface{} = nil
+		if stored.EndDate != nil {
+			endDate = *stored.EndDate
+		}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("subscription not found")
-	}
+		if _, err := tx.Exec(ctx, query,
+			stored.ServiceName,
+			stored.UserID,
+			stored.StartDate,
+			endDate,
+			stored.ID,
+		); err != nil {
+			s.log(ctx).Error("failed to update subscription",
+				zap.Error(err),
+				zap.String("id", sub.ID))
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 func (s *SubscriptionRepository) Delete(ctx context.Context, id string) error {
+	db.MarkWrite(ctx)
+
 	query := `DELETE FROM subscriptions WHERE id = $1`
 
 	result, err := s.db.Exec(ctx, query, id)
 	if err != nil {
-		s.logger.Error("failed to delete subscription",
+		s.log(ctx).Error("failed to delete subscription",
 			zap.Error(err),
 			zap.String("id", id))
 		return fmt.Errorf("failed to delete subscription: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("subscription not found")
+		return ErrSubscriptionNotFound
 	}
 
 	return nil
 }
 
-func (s *SubscriptionRepository) List(ctx context.Context, filters map[string]interface{}) ([]*Subscription, error) {
-	baseQuery := `
-		SELECT id, service_name, price, user_id, start_date, end_date
-		FROM subscriptions`
+func (s *SubscriptionRepository) PurgeEnded(ctx context.Context, before time.Time) (int64, error) {
+	db.MarkWrite(ctx)
 
-	var conditions []string
-	var args []interface{}
+	// Цены, паузы, скидки и участники удаляются каскадно
+	query := `DELETE FROM subscriptions WHERE end_date IS NOT NULL AND end_date < $1`
 
-	for field, value := range filters {
-		conditions = append(conditions, fmt.Sprintf("%s = $%d", field, len(args)+1))
-		args = append(args, value)
+	result, err := s.db.Exec(ctx, query, before)
+	if err != nil {
+		s.log(ctx).Error("failed to purge ended subscriptions",
+			zap.Error(err),
+			zap.Time("before", before))
+		return 0, fmt.Errorf("failed to purge ended subscriptions: %w", err)
 	}
 
-	if len(conditions) > 0 {
-		baseQuery += " WHERE " + strings.Join(conditions, " AND ")
-	}
+	return result.RowsAffected(), nil
+}
 
-	rows, err := s.db.Query(ctx, baseQuery, args...)
+func (s *SubscriptionRepository) List(ctx context.Context, filters map[string]interface{}) ([]*Subscription, error) {
+	pool := s.router.Read(ctx)
+
+	query, args := listQuery(`
+		SELECT id, service_name, price, user_id, start_date, end_date,
+		       cancelled_at, cancellation_reason, cancellation_comment
+		FROM subscriptions`, filters)
+
+	rows, err := pool.Query(ctx, query, args...)
 	if err != nil {
-		s.logger.Error("failed to list subscriptions",
+		s.log(ctx).Error("failed to list subscriptions",
 			zap.Error(err))
 		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
 	}
@@ -183,8 +287,11 @@ func (s *SubscriptionRepository) List(ctx context.Context, filters map[string]in
 			&sub.UserID,
 			&sub.StartDate,
 			&endDate,
+			&sub.CancelledAt,
+			&sub.CancellationReason,
+			&sub.CancellationComment,
 		); err != nil {
-			s.logger.Error("failed to scan subscription",
+			s.log(ctx).Error("failed to scan subscription",
 				zap.Error(err))
 			continue
 		}
@@ -192,32 +299,423 @@ func (s *SubscriptionRepository) List(ctx context.Context, filters map[string]in
 		sub.EndDate = endDate
 		subs = append(subs, &sub)
 	}
+	rows.Close()
+
+	if err := s.attachDetails(ctx, pool, subs); err != nil {
+		return nil, err
+	}
 
 	return subs, nil
 }
 
+// StreamList передаёт подписки fn прямо из rows.Next(). Пока курсор открыт,
+// соединение занято, поэтому дочерние записи загружаются не отдельными
+// запросами, как в attachDetails, а в той же строке как JSON-массивы.
+func (s *SubscriptionRepository) StreamList(ctx context.Context, filters map[string]interface{}, fn func(*Subscription) error) error {
+	query, args := listQuery(`
+		SELECT id, service_name, price, user_id, start_date, end_date,
+		       cancelled_at, cancellation_reason, cancellation_comment,
+		       (SELECT json_agg(json_build_object(
+		                   'id', p.id, 'subscription_id', p.subscription_id, 'price', p.price,
+		                   'effective_from', `+jsonDate("p.effective_from")+`, 'created_at', p.created_at)
+		               ORDER BY p.effective_from)
+		        FROM subscription_prices p WHERE p.subscription_id = subscriptions.id),
+		       (SELECT json_agg(json_build_object(
+		                   'id', p.id, 'subscription_id', p.subscription_id,
+		                   'start_date', `+jsonDate("p.start_date")+`, 'end_date', `+jsonDate("p.end_date")+`,
+		                   'created_at', p.created_at)
+		               ORDER BY p.start_date)
+		        FROM subscription_pauses p WHERE p.subscription_id = subscriptions.id),
+		       (SELECT json_agg(json_build_object(
+		                   'id', d.id, 'subscription_id', d.subscription_id, 'kind', d.kind, 'value', d.value,
+		                   'start_date', `+jsonDate("d.start_date")+`, 'duration_periods', d.duration_periods,
+		                   'description', d.description, 'created_at', d.created_at)
+		               ORDER BY d.start_date)
+		        FROM subscription_discounts d WHERE d.subscription_id = subscriptions.id),
+		       (SELECT json_agg(json_build_object(
+		                   'subscription_id', m.subscription_id, 'user_id', m.user_id,
+		                   'share_percent', m.share_percent, 'fixed_amount', m.fixed_amount,
+		                   'created_at', m.created_at)
+		               ORDER BY m.user_id)
+		        FROM subscription_members m WHERE m.subscription_id = subscriptions.id)
+		FROM subscriptions`, filters)
+
+	rows, err := s.router.Read(ctx).Query(ctx, query, args...)
+	if err != nil {
+		s.log(ctx).Error("failed to stream subscriptions", zap.Error(err))
+		return fmt.Errorf("failed to stream subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	for rows.Next() {
+		var sub Subscription
+		var prices, pauses, discounts, members []byte
+		err := rows.Scan(
+			&sub.ID,
+			&sub.ServiceName,
+			&sub.Price,
+			&sub.UserID,
+			&sub.StartDate,
+			&sub.EndDate,
+			&sub.CancelledAt,
+			&sub.CancellationReason,
+			&sub.CancellationComment,
+			&prices,
+			&pauses,
+			&discounts,
+			&members,
+		)
+		if err == nil {
+			err = decodeDetails(
+				detail{prices, &sub.PriceChanges},
+				detail{pauses, &sub.Pauses},
+				detail{discounts, &sub.Discounts},
+				detail{members, &sub.Members},
+			)
+		}
+		if err != nil {
+			s.log(ctx).Error("failed to scan subscription", zap.Error(err))
+			return fmt.Errorf("failed to scan subscription: %w", err)
+		}
+
+		sub.RefreshPricing(now)
+		if err := fn(&sub); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		s.log(ctx).Error("failed to stream subscriptions", zap.Error(err))
+		return fmt.Errorf("failed to stream subscriptions: %w", err)
+	}
+
+	return nil
+}
+
+// CalculateMonthlyCost суммирует стоимость подписок помесячно за период
+// start_date_from..start_date_to, см. PeriodCost.
 func (s *SubscriptionRepository) CalculateMonthlyCost(ctx context.Context, filters map[string]interface{}) (int, error) {
-	baseQuery := `SELECT COALESCE(SUM(price), 0) FROM subscriptions`
+	now := time.Now().UTC()
 
-	var conditions []string
-	var args []any
+	// Совместимые фильтры считаются по агрегату monthly_spend, остальные — по подпискам
+	if q, ok := NewSpendQuery(filters, now); ok {
+		total, ok, err := s.spendTotal(ctx, q)
+		if err != nil {
+			return 0, fmt.Errorf("failed to calculate monthly cost: %w", err)
+		}
+		if ok {
+			return total, nil
+		}
+	}
 
-	for field, value := range filters {
-		conditions = append(conditions, fmt.Sprintf("%s = $%d", field, len(args)+1))
-		args = append(args, value)
+	subs, err := s.ListForPeriod(ctx, filters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate monthly cost: %w", err)
 	}
 
+	return PeriodCost(subs, filters, now), nil
+}
+
+// ListForPeriod возвращает подписки, действующие в периоде start_date_from..start_date_to,
+// вместе с ценами, паузами, скидками и участниками. Фильтр user_id включает совместные
+// подписки, где пользователь участник.
+func (s *SubscriptionRepository) ListForPeriod(ctx context.Context, filters map[string]interface{}) ([]*Subscription, error) {
+	pool := s.router.Read(ctx)
+
+	baseQuery := `
+		SELECT id, service_name, price, user_id, start_date, end_date,
+		       cancelled_at, cancellation_reason, cancellation_comment
+		FROM subscriptions`
+
+	conditions, args := costConditions(filters)
 	if len(conditions) > 0 {
 		baseQuery += " WHERE " + strings.Join(conditions, " AND ")
 	}
+	baseQuery += " ORDER BY start_date, id"
 
-	var total int
-	err := s.db.QueryRow(ctx, baseQuery, args...).Scan(&total)
+	rows, err := pool.Query(ctx, baseQuery, args...)
 	if err != nil {
-		s.logger.Error("failed to calculate monthly cost",
+		s.log(ctx).Error("failed to list subscriptions for period",
 			zap.Error(err))
-		return 0, fmt.Errorf("failed to calculate monthly cost: %w", err)
+		return nil, fmt.Errorf("failed to list subscriptions for period: %w", err)
+	}
+
+	subs, err := pgx.CollectRows(rows, scanSubscription)
+	if err != nil {
+		s.log(ctx).Error("failed to scan subscriptions for period",
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to list subscriptions for period: %w", err)
+	}
+
+	if err := s.attachDetails(ctx, pool, subs); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// FindDuplicates находит пары подписок пользователя на один сервис (без учёта
+// регистра названия) с пересекающимися периодами действия.
+func (s *SubscriptionRepository) FindDuplicates(ctx context.Context, userID string) ([]*Overlap, error) {
+	pool := s.router.Read(ctx)
+
+	query := `
+		SELECT a.id, a.service_name, a.price, a.user_id, a.start_date, a.end_date,
+		       b.id, b.service_name, b.price, b.user_id, b.start_date, b.end_date,
+		       lower(r.overlap), upper(r.overlap) - 1
+		FROM subscriptions a
+		JOIN subscriptions b
+		  ON b.user_id = a.user_id
+		 AND lower(b.service_name) = lower(a.service_name)
+		 AND b.id > a.id
+		CROSS JOIN LATERAL (
+			SELECT daterange(a.start_date, a.end_date, '[]') * daterange(b.start_date, b.end_date, '[]') AS overlap
+		) r
+		WHERE a.user_id = $1
+		  AND daterange(a.start_date, a.end_date, '[]') && daterange(b.start_date, b.end_date, '[]')
+		ORDER BY lower(a.service_name), lower(r.overlap)`
+
+	rows, err := pool.Query(ctx, query, userID)
+	if err != nil {
+		s.log(ctx).Error("failed to find duplicate subscriptions",
+			zap.Error(err),
+			zap.String("user_id", userID))
+		return nil, fmt.Errorf("failed to find duplicate subscriptions: %w", err)
+	}
+
+	overlaps, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Overlap, error) {
+		o := &Overlap{First: &Subscription{}, Second: &Subscription{}}
+		err := row.Scan(
+			&o.First.ID, &o.First.ServiceName, &o.First.Price, &o.First.UserID, &o.First.StartDate, &o.First.EndDate,
+			&o.Second.ID, &o.Second.ServiceName, &o.Second.Price, &o.Second.UserID, &o.Second.StartDate, &o.Second.EndDate,
+			&o.From, &o.To,
+		)
+		o.ServiceName = o.First.ServiceName
+		return o, err
+	})
+	if err != nil {
+		s.log(ctx).Error("failed to scan duplicate subscriptions",
+			zap.Error(err),
+			zap.String("user_id", userID))
+		return nil, fmt.Errorf("failed to find duplicate subscriptions: %w", err)
+	}
+
+	return overlaps, nil
+}
+
+// FindOverlapping возвращает подписки того же пользователя на тот же сервис,
+// период действия которых пересекается с периодом sub.
+func (s *SubscriptionRepository) FindOverlapping(ctx context.Context, sub *Subscription) ([]*Subscription, error) {
+	return s.findOverlapping(ctx, s.db, sub)
+}
+
+func (s *SubscriptionRepository) findOverlapping(ctx context.Context, q querier, sub *Subscription) ([]*Subscription, error) {
+	query := `
+		SELECT id, service_name, price, user_id, start_date, end_date,
+		       cancelled_at, cancellation_reason, cancellation_comment
+		FROM subscriptions
+		WHERE user_id = $1
+		  AND lower(service_name) = lower($2)
+		  AND id::text <> $3
+		  AND daterange(start_date, end_date, '[]') && daterange($4::date, $5::date, '[]')
+		ORDER BY start_date, id`
+
+	rows, err := q.Query(ctx, query,
+		sub.UserID,
+		sub.ServiceName,
+		sub.ID,
+		sub.StartDate,
+		sub.EndDate,
+	)
+	if err != nil {
+		s.log(ctx).Error("failed to find overlapping subscriptions",
+			zap.Error(err),
+			zap.String("user_id", sub.UserID))
+		return nil, fmt.Errorf("failed to find overlapping subscriptions: %w", err)
+	}
+
+	subs, err := pgx.CollectRows(rows, scanSubscription)
+	if err != nil {
+		s.log(ctx).Error("failed to scan overlapping subscriptions",
+			zap.Error(err),
+			zap.String("user_id", sub.UserID))
+		return nil, fmt.Errorf("failed to find overlapping subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
+
+// ChurnStats считает отмены по сервисам и причинам. Фильтры cancelled_from и
+// cancelled_to ограничивают месяц отмены, service_name — сервис.
+func (s *SubscriptionRepository) ChurnStats(ctx context.Context, filters map[string]interface{}) ([]*ChurnStat, error) {
+	pool := s.router.Read(ctx)
+
+	baseQuery := `
+		SELECT service_name, cancellation_reason, COUNT(*)
+		FROM subscriptions`
+
+	conditions := []string{"cancelled_at IS NOT NULL"}
+	var args []any
+
+	if v, ok := filters["service_name"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("service_name = $%d", len(args)))
+	}
+	if v, ok := filters["cancelled_from"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("cancelled_at >= $%d", len(args)))
+	}
+	if v, ok := filters["cancelled_to"].(time.Time); ok {
+		args = append(args, monthStart(v).AddDate(0, 1, 0))
+		conditions = append(conditions, fmt.Sprintf("cancelled_at < $%d", len(args)))
+	}
+
+	baseQuery += " WHERE " + strings.Join(conditions, " AND ") +
+		" GROUP BY service_name, cancellation_reason ORDER BY service_name, cancellation_reason"
+
+	rows, err := pool.Query(ctx, baseQuery, args...)
+	if err != nil {
+		s.log(ctx).Error("failed to collect churn statistics", zap.Error(err))
+		return nil, fmt.Errorf("failed to collect churn statistics: %w", err)
+	}
+
+	stats, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*ChurnStat, error) {
+		var stat ChurnStat
+		err := row.Scan(&stat.ServiceName, &stat.Reason, &stat.Count)
+		return &stat, err
+	})
+	if err != nil {
+		s.log(ctx).Error("failed to scan churn statistics", zap.Error(err))
+		return nil, fmt.Errorf("failed to collect churn statistics: %w", err)
+	}
+
+	return stats, nil
+}
+
+func scanSubscription(row pgx.CollectableRow) (*Subscription, error) {
+	var sub Subscription
+	err := row.Scan(
+		&sub.ID,
+		&sub.ServiceName,
+		&sub.Price,
+		&sub.UserID,
+		&sub.StartDate,
+		&sub.EndDate,
+		&sub.CancelledAt,
+		&sub.CancellationReason,
+		&sub.CancellationComment,
+	)
+	return &sub, err
+}
+
+// listQuery дополняет selectFrom условиями listConditions, порядком выборки
+// списка и постраничными limit и offset.
+func listQuery(selectFrom string, filters map[string]interface{}) (string, []any) {
+	query := selectFrom
+	conditions, args := listConditions(filters)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY start_date, id"
+
+	// Постраничная выборка: limit и offset не являются условиями фильтрации
+	if v, ok := filters["limit"]; ok {
+		args = append(args, v)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if v, ok := filters["offset"]; ok {
+		args = append(args, v)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	return query, args
+}
+
+// jsonDate форматирует DATE так, чтобы json.Unmarshal прочитал его в time.Time
+// полночью UTC, как при сканировании столбца pgx.
+func jsonDate(column string) string {
+	return "to_char(" + column + `, 'YYYY-MM-DD"T00:00:00Z"')`
+}
+
+// detail — JSON-массив дочерних записей и срез, в который он декодируется
+type detail struct {
+	data []byte
+	dst  any
+}
+
+// decodeDetails декодирует JSON-массивы дочерних записей; NULL (нет записей)
+// оставляет срез пустым.
+func decodeDetails(details ...detail) error {
+	for _, d := range details {
+		if d.data == nil {
+			continue
+		}
+		if err := json.Unmarshal(d.data, d.dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listConditions строит условия WHERE для выборки списка подписок.
+func listConditions(filters map[string]interface{}) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	if v, ok := filters["user_id"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	// user_ids позволяет загрузить подписки нескольких пользователей одним запросом
+	if v, ok := filters["user_ids"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("user_id = ANY($%d::uuid[])", len(args)))
+	}
+	if v, ok := filters["service_name"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("service_name = $%d", len(args)))
+	}
+	if v, ok := filters["start_date_from"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("start_date >= $%d", len(args)))
+	}
+	if v, ok := filters["start_date_to"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("start_date <= $%d", len(args)))
+	}
+
+	return conditions, args
+}
+
+// costConditions строит условия WHERE для подписок, пересекающихся с периодом расчёта.
+func costConditions(filters map[string]interface{}) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	if v, ok := filters["user_id"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf(
+			"(user_id = $%[1]d OR id IN (SELECT subscription_id FROM subscription_members WHERE user_id = $%[1]d))",
+			len(args)))
+	}
+	if v, ok := filters["user_ids"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf(
+			"(user_id = ANY($%[1]d::uuid[]) OR id IN (SELECT subscription_id FROM subscription_members WHERE user_id = ANY($%[1]d::uuid[])))",
+			len(args)))
+	}
+	if v, ok := filters["service_name"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("service_name = $%d", len(args)))
+	}
+	if v, ok := filters["start_date_from"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("(end_date IS NULL OR end_date >= $%d)", len(args)))
+	}
+	if v, ok := filters["start_date_to"]; ok {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf("start_date <= $%d", len(args)))
 	}
 
-	return total, nil
+	return conditions, args
 }
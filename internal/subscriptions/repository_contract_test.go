@@ -0,0 +1,941 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"SubscriptionService/migrations"
+	"SubscriptionService/pkg/db"
+
+	"go.uber.org/zap"
+)
+
+// missingID — корректный uuid, которого нет ни в одном репозитории.
+const missingID = "00000000-0000-4000-8000-000000000000"
+
+func TestMemoryRepositoryContract(t *testing.T) {
+	testRepositoryContract(t, func(t *testing.T) ISubscriptionRepository {
+		return NewMemoryRepository()
+	})
+}
+
+// TestPostgresRepositoryContract запускает тот же набор на PostgreSQL из
+// TEST_DB_URL. Таблицы очищаются перед каждой проверкой, поэтому база должна
+// быть отдельной, например созданной только для тестов.
+func TestPostgresRepositoryContract(t *testing.T) {
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	ctx := context.Background()
+	logger := zap.NewNop()
+	pool, err := db.NewPGXPool(ctx, db.PoolConfig{URL: url, MaxConns: 4}, logger)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err := db.NewMigrator(pool, migrations.FS, logger).Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := NewSubscriptionRepository(db.NewRouter(pool, nil, 0, logger), logger)
+
+	reset := func(t *testing.T) {
+		t.Helper()
+		if _, err := pool.Exec(ctx, `TRUNCATE subscriptions CASCADE`); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		if _, err := pool.Exec(ctx, `
+			UPDATE monthly_spend_state
+			SET ready = FALSE, materialized_through = date_trunc('month', NOW())::date, pending_through = NULL`,
+		); err != nil {
+			t.Fatalf("reset monthly spend state: %v", err)
+		}
+	}
+
+	t.Run("on the fly", func(t *testing.T) {
+		testRepositoryContract(t, func(t *testing.T) ISubscriptionRepository {
+			reset(t)
+			return repo
+		})
+	})
+
+	// Агрегат готов с пустых таблиц, дальше его обновляет каждая запись
+	t.Run("monthly spend rollup", func(t *testing.T) {
+		testRepositoryContract(t, func(t *testing.T) ISubscriptionRepository {
+			reset(t)
+			through := monthStart(time.Now().UTC()).AddDate(0, 24, 0)
+			if _, err := repo.RebuildSpend(ctx, through, true, nil); err != nil {
+				t.Fatalf("rebuild monthly spend: %v", err)
+			}
+			return repo
+		})
+	})
+}
+
+// testRepositoryContract проверяет поведение, общее для всех реализаций
+// ISubscriptionRepository. newRepo возвращает пустой репозиторий.
+func testRepositoryContract(t *testing.T, newRepo func(t *testing.T) ISubscriptionRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo ISubscriptionRepository)
+	}{
+		{"create and get", testCreateAndGet},
+		{"update", testUpdate},
+		{"delete and purge", testDeleteAndPurge},
+		{"list", testList},
+		{"stream list", testStreamList},
+		{"list for period", testListForPeriod},
+		{"monthly cost", testMonthlyCost},
+		{"duplicates", testDuplicates},
+		{"overlapping", testOverlapping},
+		{"create non-overlapping", testCreateNonOverlapping},
+		{"price changes", testPriceChanges},
+		{"pauses", testPauses},
+		{"discounts", testDiscounts},
+		{"members", testMembers},
+		{"cancel and churn", testCancelAndChurn},
+		{"returns copies", testReturnsCopies},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+func month(year int, m time.Month) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func monthPtr(year int, m time.Month) *time.Time {
+	t := month(year, m)
+	return &t
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func create(t *testing.T, repo ISubscriptionRepository, service string, price int, userID string, start time.Time, end *time.Time) *Subscription {
+	t.Helper()
+	sub, err := NewSubscription(service, price, userID, start, end)
+	if err != nil {
+		t.Fatalf("new subscription: %v", err)
+	}
+	if err := repo.Create(context.Background(), sub); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	return sub
+}
+
+func get(t *testing.T, repo ISubscriptionRepository, id string) *Subscription {
+	t.Helper()
+	sub, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get %s: %v", id, err)
+	}
+	return sub
+}
+
+func subscriptionIDs(subs []*Subscription) []string {
+	ids := []string{}
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+	return ids
+}
+
+// sortedIDs возвращает id в порядке start_date, id, как их отдаёт репозиторий.
+func sortedIDs(subs ...*Subscription) []string {
+	sorted := append([]*Subscription(nil), subs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].StartDate.Equal(sorted[j].StartDate) {
+			return sorted[i].StartDate.Before(sorted[j].StartDate)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return subscriptionIDs(sorted)
+}
+
+func wantErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("error = %v, want %v", err, want)
+	}
+}
+
+func testCreateAndGet(t *testing.T, repo ISubscriptionRepository) {
+	sub := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), monthPtr(2024, time.June))
+	if len(sub.ID) != 36 {
+		t.Fatalf("created id = %q, want a uuid", sub.ID)
+	}
+
+	got := get(t, repo, sub.ID)
+	if got.ServiceName != "Netflix" || got.Price != 500 || got.UserID != testUsers[0] ||
+		!got.StartDate.Equal(month(2024, time.January)) || got.EndDate == nil || !got.EndDate.Equal(month(2024, time.June)) {
+		t.Fatalf("got %+v", got)
+	}
+	if got.ListPrice != 500 || got.EffectivePrice != 500 || got.CancelledAt != nil {
+		t.Fatalf("list price %d, effective price %d, cancelled at %v", got.ListPrice, got.EffectivePrice, got.CancelledAt)
+	}
+
+	_, err := repo.GetByID(context.Background(), missingID)
+	wantErr(t, err, ErrSubscriptionNotFound)
+}
+
+func testUpdate(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), nil)
+
+	updated := &Subscription{
+		ID:          sub.ID,
+		ServiceName: "Spotify",
+		Price:       500,
+		UserID:      testUsers[1],
+		StartDate:   month(2024, time.March),
+		EndDate:     monthPtr(2025, time.March),
+	}
+	if err := repo.Update(ctx, updated); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	got := get(t, repo, sub.ID)
+	if got.ServiceName != "Spotify" || got.Price != 500 || got.UserID != testUsers[1] ||
+		!got.StartDate.Equal(month(2024, time.March)) || !got.EndDate.Equal(month(2025, time.March)) {
+		t.Fatalf("got %+v", got)
+	}
+
+	// Базовая цена действует с первого месяца и через Update не меняется
+	changed := *updated
+	changed.Price = 300
+	changed.ServiceName = "Okko"
+	wantErr(t, repo.Update(ctx, &changed), ErrPriceChangeRequired)
+	if got := get(t, repo, sub.ID); got.Price != 500 || got.ServiceName != "Spotify" {
+		t.Fatalf("rejected update was applied: %+v", got)
+	}
+
+	// Новые даты и владелец проверяются по сохранённым ценам, паузам, скидкам и участникам
+	if err := repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: sub.ID, Price: 600, EffectiveFrom: month(2024, time.June)}); err != nil {
+		t.Fatalf("add price change: %v", err)
+	}
+	if _, err := repo.Pause(ctx, sub.ID, month(2024, time.August), monthPtr(2024, time.September)); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if err := repo.AddMember(ctx, sub.ID, &Member{UserID: testUsers[2], FixedAmount: intPtr(400)}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	moved := *updated
+	moved.StartDate = month(2024, time.July)
+	wantErr(t, repo.Update(ctx, &moved), ErrDetailsOutOfRange)
+	moved = *updated
+	moved.EndDate = monthPtr(2024, time.August)
+	wantErr(t, repo.Update(ctx, &moved), ErrDetailsOutOfRange)
+	moved = *updated
+	moved.UserID = testUsers[2]
+	wantErr(t, repo.Update(ctx, &moved), ErrMemberIsOwner)
+	if got := get(t, repo, sub.ID); !got.StartDate.Equal(month(2024, time.March)) || !got.EndDate.Equal(month(2025, time.March)) || got.UserID != testUsers[1] {
+		t.Fatalf("rejected update was applied: %+v", got)
+	}
+	moved = *updated
+	moved.StartDate = month(2024, time.May)
+	moved.EndDate = monthPtr(2024, time.September)
+	if err := repo.Update(ctx, &moved); err != nil {
+		t.Fatalf("update within details: %v", err)
+	}
+
+	updated.ID = missingID
+	wantErr(t, repo.Update(ctx, updated), ErrSubscriptionNotFound)
+}
+
+func testDeleteAndPurge(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	deleted := create(t, repo, "Netflix", 500, testUsers[0], month(2023, time.January), nil)
+	ended := create(t, repo, "Spotify", 300, testUsers[0], month(2023, time.January), monthPtr(2023, time.June))
+	endsLater := create(t, repo, "Okko", 400, testUsers[0], month(2023, time.January), monthPtr(2024, time.June))
+	open := create(t, repo, "Yandex Plus", 200, testUsers[0], month(2023, time.January), nil)
+
+	if err := repo.Delete(ctx, deleted.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	_, err := repo.GetByID(ctx, deleted.ID)
+	wantErr(t, err, ErrSubscriptionNotFound)
+	wantErr(t, repo.Delete(ctx, deleted.ID), ErrSubscriptionNotFound)
+
+	purged, err := repo.PurgeEnded(ctx, month(2024, time.January))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d subscriptions, want 1", purged)
+	}
+
+	subs, err := repo.List(ctx, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if got, want := subscriptionIDs(subs), sortedIDs(endsLater, open); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after purge of %s: ids %v, want %v", ended.ID, got, want)
+	}
+}
+
+func testList(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	a := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), nil)
+	b := create(t, repo, "Spotify", 300, testUsers[0], month(2024, time.March), monthPtr(2024, time.May))
+	c := create(t, repo, "Netflix", 700, testUsers[1], month(2024, time.March), nil)
+	d := create(t, repo, "Netflix", 600, testUsers[2], month(2024, time.July), nil)
+
+	// Участник не попадает в список по user_id: фильтр списка — только владелец
+	if err := repo.AddMember(ctx, d.ID, &Member{UserID: testUsers[0], SharePercent: intPtr(50)}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		want    []string
+	}{
+		{"all", map[string]interface{}{}, sortedIDs(a, b, c, d)},
+		{"user", map[string]interface{}{"user_id": testUsers[0]}, sortedIDs(a, b)},
+		{"users", map[string]interface{}{"user_ids": []string{testUsers[1], testUsers[2]}}, sortedIDs(c, d)},
+		{"service", map[string]interface{}{"service_name": "Netflix"}, sortedIDs(a, c, d)},
+		{"service is case sensitive", map[string]interface{}{"service_name": "netflix"}, []string{}},
+		{"start from", map[string]interface{}{"start_date_from": month(2024, time.March)}, sortedIDs(b, c, d)},
+		{"start to", map[string]interface{}{"start_date_to": month(2024, time.March)}, sortedIDs(a, b, c)},
+		{"combined", map[string]interface{}{
+			"service_name":    "Netflix",
+			"start_date_from": month(2024, time.February),
+			"start_date_to":   month(2024, time.June),
+		}, sortedIDs(c)},
+		{"page", map[string]interface{}{"limit": 2, "offset": 1}, sortedIDs(a, b, c, d)[1:3]},
+		{"offset past the end", map[string]interface{}{"offset": 10}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs, err := repo.List(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if got := subscriptionIDs(subs); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ids %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	subs, err := repo.List(ctx, map[string]interface{}{"user_id": testUsers[2]})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(subs) != 1 || len(subs[0].Members) != 1 || subs[0].Members[0].UserID != testUsers[0] {
+		t.Fatalf("list should load members, got %+v", subs)
+	}
+}
+
+// describe перечисляет поля подписки с датами в UTC: PostgreSQL возвращает
+// одни и те же моменты в разных часовых поясах при сканировании и в JSON.
+func describe(sub *Subscription) string {
+	out := fmt.Sprintf("%s %s %d/%d/%d %s %s %s %s %q", sub.ID, sub.ServiceName, sub.Price, sub.ListPrice, sub.EffectivePrice,
+		sub.StartDate.UTC(), optional(sub.EndDate), optional(sub.CancelledAt), sub.CancellationReason, sub.CancellationComment)
+	for _, pc := range sub.PriceChanges {
+		out += fmt.Sprintf(" price(%s %d %s %s)", pc.ID, pc.Price, pc.EffectiveFrom.UTC(), pc.CreatedAt.UTC())
+	}
+	for _, p := range sub.Pauses {
+		out += fmt.Sprintf(" pause(%s %s %s %s)", p.ID, p.StartDate.UTC(), optional(p.EndDate), p.CreatedAt.UTC())
+	}
+	for _, d := range sub.Discounts {
+		out += fmt.Sprintf(" discount(%s %s %d %s %d %q %s)", d.ID, d.Kind, d.Value, d.StartDate.UTC(), d.DurationPeriods, d.Description, d.CreatedAt.UTC())
+	}
+	for _, m := range sub.Members {
+		out += fmt.Sprintf(" member(%s %s %s %s)", m.UserID, optional(m.SharePercent), optional(m.FixedAmount), m.CreatedAt.UTC())
+	}
+	return out
+}
+
+// optional форматирует значение по указателю; время приводится к UTC.
+func optional[T any](v *T) string {
+	if v == nil {
+		return "-"
+	}
+	if t, ok := any(*v).(time.Time); ok {
+		return t.UTC().String()
+	}
+	return fmt.Sprint(*v)
+}
+
+func testStreamList(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	shared := create(t, repo, "Netflix", 1000, testUsers[0], month(2024, time.January), nil)
+	create(t, repo, "Spotify", 300, testUsers[0], month(2024, time.March), monthPtr(2024, time.May))
+	create(t, repo, "Netflix", 700, testUsers[1], month(2024, time.March), nil)
+
+	if err := repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: shared.ID, Price: 1200, EffectiveFrom: month(2024, time.July)}); err != nil {
+		t.Fatalf("add price change: %v", err)
+	}
+	if _, err := repo.Pause(ctx, shared.ID, month(2024, time.March), monthPtr(2024, time.April)); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if err := repo.AddDiscount(ctx, shared.ID, &Discount{Kind: DiscountFixed, Value: 100, StartDate: month(2024, time.May), DurationPeriods: 120, Description: "promo"}); err != nil {
+		t.Fatalf("add discount: %v", err)
+	}
+	for _, member := range []*Member{{UserID: testUsers[2], FixedAmount: intPtr(200)}, {UserID: testUsers[1], SharePercent: intPtr(25)}} {
+		if err := repo.AddMember(ctx, shared.ID, member); err != nil {
+			t.Fatalf("add member: %v", err)
+		}
+	}
+
+	for _, filters := range []map[string]interface{}{
+		{},
+		{"user_id": testUsers[0]},
+		{"service_name": "Netflix", "limit": 1, "offset": 1},
+	} {
+		listed, err := repo.List(ctx, filters)
+		if err != nil {
+			t.Fatalf("list %v: %v", filters, err)
+		}
+		var streamed []*Subscription
+		if err := repo.StreamList(ctx, filters, func(sub *Subscription) error {
+			streamed = append(streamed, sub)
+			return nil
+		}); err != nil {
+			t.Fatalf("stream %v: %v", filters, err)
+		}
+
+		if len(streamed) != len(listed) {
+			t.Fatalf("%v: streamed %d subscriptions, listed %d", filters, len(streamed), len(listed))
+		}
+		for i := range listed {
+			if got, want := describe(streamed[i]), describe(listed[i]); got != want {
+				t.Fatalf("%v: streamed\n%s\nwant\n%s", filters, got, want)
+			}
+		}
+	}
+
+	// Ошибка получателя прекращает выборку и возвращается без обёртки
+	stop := errors.New("stop")
+	calls := 0
+	err := repo.StreamList(ctx, map[string]interface{}{}, func(*Subscription) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Fatalf("error = %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+func testListForPeriod(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	a := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), monthPtr(2024, time.March))
+	b := create(t, repo, "Spotify", 300, testUsers[1], month(2024, time.February), nil)
+	c := create(t, repo, "Netflix", 700, testUsers[2], month(2024, time.June), nil)
+
+	if err := repo.AddMember(ctx, b.ID, &Member{UserID: testUsers[0], FixedAmount: intPtr(100)}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		want    []string
+	}{
+		{"all", map[string]interface{}{}, sortedIDs(a, b, c)},
+		{"owner or member", map[string]interface{}{"user_id": testUsers[0]}, sortedIDs(a, b)},
+		{"owners or members", map[string]interface{}{"user_ids": []string{testUsers[0], testUsers[2]}}, sortedIDs(a, b, c)},
+		{"ends on the first month", map[string]interface{}{"start_date_from": month(2024, time.March)}, sortedIDs(a, b, c)},
+		{"ended before", map[string]interface{}{"start_date_from": month(2024, time.April)}, sortedIDs(b, c)},
+		{"starts after", map[string]interface{}{"start_date_to": month(2024, time.May)}, sortedIDs(a, b)},
+		{"service", map[string]interface{}{"service_name": "Netflix", "start_date_to": month(2024, time.June)}, sortedIDs(a, c)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs, err := repo.ListForPeriod(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("list for period: %v", err)
+			}
+			if got := subscriptionIDs(subs); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ids %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testMonthlyCost считает стоимость подписки с изменением цены, паузой,
+// скидкой и участником; суммы посчитаны вручную.
+func testMonthlyCost(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	shared := create(t, repo, "Netflix", 1000, testUsers[0], month(2024, time.January), monthPtr(2024, time.December))
+	own := create(t, repo, "Spotify", 300, testUsers[1], month(2024, time.June), nil)
+
+	if err := repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: shared.ID, Price: 1200, EffectiveFrom: month(2024, time.July)}); err != nil {
+		t.Fatalf("add price change: %v", err)
+	}
+	if _, err := repo.Pause(ctx, shared.ID, month(2024, time.March), monthPtr(2024, time.April)); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if err := repo.AddDiscount(ctx, shared.ID, &Discount{Kind: DiscountPercentage, Value: 50, StartDate: month(2024, time.May), DurationPeriods: 1}); err != nil {
+		t.Fatalf("add discount: %v", err)
+	}
+	if err := repo.AddMember(ctx, shared.ID, &Member{UserID: testUsers[1], SharePercent: intPtr(25)}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+
+	// shared по месяцам 2024: 1000, 1000, пауза, пауза, 500, 1000, 6×1200; own: 7×300 с июня
+	year := map[string]interface{}{"start_date_from": month(2024, time.January), "start_date_to": month(2024, time.December)}
+	with := func(key string, value interface{}) map[string]interface{} {
+		filters := map[string]interface{}{key: value}
+		for k, v := range year {
+			filters[k] = v
+		}
+		return filters
+	}
+
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		want    int
+	}{
+		{"all", year, 10700 + 2100},
+		{"owner pays the rest", with("user_id", testUsers[0]), 10700 - 2675},
+		{"member pays a share", with("user_id", testUsers[1]), 2675 + 2100},
+		{"users", with("user_ids", []string{testUsers[0], testUsers[1]}), 10700 + 2100},
+		{"service", with("service_name", "Spotify"), 2100},
+		{"one month", map[string]interface{}{"start_date_from": month(2024, time.May), "start_date_to": month(2024, time.May)}, 500},
+		{"after the end", map[string]interface{}{"start_date_from": month(2025, time.January), "start_date_to": month(2025, time.February), "service_name": "Netflix"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, err := repo.CalculateMonthlyCost(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("calculate: %v", err)
+			}
+			if total != tt.want {
+				t.Fatalf("total %d, want %d", total, tt.want)
+			}
+
+			// Агрегат, если он есть, должен давать ту же сумму
+			spend, ok, err := repo.MonthlySpend(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("monthly spend: %v", err)
+			}
+			if !ok {
+				return
+			}
+			sum := 0
+			for _, row := range spend {
+				sum += row.Amount
+			}
+			if sum != tt.want {
+				t.Fatalf("monthly spend sums to %d, want %d", sum, tt.want)
+			}
+		})
+	}
+
+	got := get(t, repo, own.ID)
+	if got.ListPrice != 300 {
+		t.Fatalf("own list price %d, want 300", got.ListPrice)
+	}
+}
+
+func testDuplicates(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	a := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), monthPtr(2024, time.June))
+	b := create(t, repo, "netflix", 500, testUsers[0], month(2024, time.June), nil)
+	c := create(t, repo, "Spotify", 300, testUsers[0], month(2023, time.January), nil)
+	d := create(t, repo, "Spotify", 300, testUsers[0], month(2023, time.May), monthPtr(2023, time.August))
+	create(t, repo, "Netflix", 500, testUsers[0], month(2022, time.January), monthPtr(2022, time.December))
+	create(t, repo, "Netflix", 500, testUsers[1], month(2024, time.January), nil)
+
+	overlaps, err := repo.FindDuplicates(ctx, testUsers[0])
+	if err != nil {
+		t.Fatalf("find duplicates: %v", err)
+	}
+	if len(overlaps) != 2 {
+		t.Fatalf("found %d overlaps, want 2: %+v", len(overlaps), overlaps)
+	}
+
+	pair := func(o *Overlap) []string {
+		ids := []string{o.First.ID, o.Second.ID}
+		sort.Strings(ids)
+		return ids
+	}
+	ids := func(x, y *Subscription) []string {
+		ids := []string{x.ID, y.ID}
+		sort.Strings(ids)
+		return ids
+	}
+
+	netflix, spotify := overlaps[0], overlaps[1]
+	if !reflect.DeepEqual(pair(netflix), ids(a, b)) || !netflix.From.Equal(month(2024, time.June)) ||
+		netflix.To == nil || !netflix.To.Equal(month(2024, time.June)) {
+		t.Fatalf("netflix overlap %+v", netflix)
+	}
+	if netflix.First.ID >= netflix.Second.ID || netflix.ServiceName != netflix.First.ServiceName {
+		t.Fatalf("first %s should precede second %s and name the service", netflix.First.ID, netflix.Second.ID)
+	}
+	if !reflect.DeepEqual(pair(spotify), ids(c, d)) || !spotify.From.Equal(month(2023, time.May)) ||
+		spotify.To == nil || !spotify.To.Equal(month(2023, time.August)) {
+		t.Fatalf("spotify overlap %+v", spotify)
+	}
+
+	overlaps, err = repo.FindDuplicates(ctx, testUsers[2])
+	if err != nil {
+		t.Fatalf("find duplicates: %v", err)
+	}
+	if len(overlaps) != 0 {
+		t.Fatalf("found %d overlaps for a user without subscriptions", len(overlaps))
+	}
+}
+
+func testOverlapping(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	a := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), monthPtr(2024, time.March))
+	b := create(t, repo, "NETFLIX", 500, testUsers[0], month(2024, time.June), nil)
+	create(t, repo, "Netflix", 500, testUsers[1], month(2024, time.January), nil)
+	create(t, repo, "Spotify", 300, testUsers[0], month(2024, time.January), nil)
+
+	tests := []struct {
+		name string
+		sub  *Subscription
+		want []string
+	}{
+		{"touches the end month", &Subscription{UserID: testUsers[0], ServiceName: "netflix", StartDate: month(2024, time.March), EndDate: monthPtr(2024, time.April)}, sortedIDs(a)},
+		{"between", &Subscription{UserID: testUsers[0], ServiceName: "Netflix", StartDate: month(2024, time.April), EndDate: monthPtr(2024, time.May)}, []string{}},
+		{"open ended", &Subscription{UserID: testUsers[0], ServiceName: "Netflix", StartDate: month(2023, time.December)}, sortedIDs(a, b)},
+		{"itself", &Subscription{ID: b.ID, UserID: testUsers[0], ServiceName: "Netflix", StartDate: month(2024, time.June)}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs, err := repo.FindOverlapping(ctx, tt.sub)
+			if err != nil {
+				t.Fatalf("find overlapping: %v", err)
+			}
+			if got := subscriptionIDs(subs); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ids %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testCreateNonOverlapping(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	a := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), monthPtr(2024, time.March))
+
+	conflicting := &Subscription{ServiceName: "NETFLIX", Price: 400, UserID: testUsers[0], StartDate: month(2024, time.March)}
+	conflicts, err := repo.CreateNonOverlapping(ctx, conflicting)
+	wantErr(t, err, ErrSubscriptionOverlaps)
+	if got := subscriptionIDs(conflicts); !reflect.DeepEqual(got, sortedIDs(a)) || conflicting.ID != "" {
+		t.Fatalf("conflicts %v, id %q", got, conflicting.ID)
+	}
+
+	later := &Subscription{ServiceName: "Netflix", Price: 400, UserID: testUsers[0], StartDate: month(2024, time.April)}
+	if conflicts, err := repo.CreateNonOverlapping(ctx, later); err != nil || len(conflicts) != 0 {
+		t.Fatalf("create: %v, conflicts %v", err, subscriptionIDs(conflicts))
+	}
+	if got := get(t, repo, later.ID); got.Price != 400 || !got.StartDate.Equal(month(2024, time.April)) {
+		t.Fatalf("created %+v", got)
+	}
+
+	// Параллельные создания одной и той же подписки: проверка и вставка атомарны,
+	// поэтому создаётся ровно одна
+	const concurrent = 8
+	errs := make(chan error, concurrent)
+	for range concurrent {
+		go func() {
+			sub := &Subscription{ServiceName: "Spotify", Price: 300, UserID: testUsers[1], StartDate: month(2024, time.January)}
+			_, err := repo.CreateNonOverlapping(ctx, sub)
+			errs <- err
+		}()
+	}
+	created := 0
+	for range concurrent {
+		switch err := <-errs; {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrSubscriptionOverlaps):
+			t.Fatalf("concurrent create: %v", err)
+		}
+	}
+	subs, err := repo.List(ctx, map[string]interface{}{"user_id": testUsers[1]})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if created != 1 || len(subs) != 1 {
+		t.Fatalf("%d creations succeeded, %d subscriptions stored", created, len(subs))
+	}
+}
+
+func testPriceChanges(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "Netflix", 500, testUsers[0], month(2023, time.January), nil)
+
+	later := &PriceChange{SubscriptionID: sub.ID, Price: 700, EffectiveFrom: month(2024, time.January)}
+	earlier := &PriceChange{SubscriptionID: sub.ID, Price: 600, EffectiveFrom: month(2023, time.June)}
+	for _, pc := range []*PriceChange{later, earlier} {
+		if err := repo.AddPriceChange(ctx, pc); err != nil {
+			t.Fatalf("add price change: %v", err)
+		}
+		if pc.ID == "" || pc.CreatedAt.IsZero() {
+			t.Fatalf("price change should get an id and creation time: %+v", pc)
+		}
+	}
+
+	// Изменение на тот же месяц заменяет цену, сохраняя запись
+	replaced := &PriceChange{SubscriptionID: sub.ID, Price: 800, EffectiveFrom: month(2024, time.January)}
+	if err := repo.AddPriceChange(ctx, replaced); err != nil {
+		t.Fatalf("replace price change: %v", err)
+	}
+	if replaced.ID != later.ID {
+		t.Fatalf("replacement id %s, want %s", replaced.ID, later.ID)
+	}
+
+	changes, err := repo.ListPriceChanges(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("list price changes: %v", err)
+	}
+	if len(changes) != 2 || changes[0].ID != earlier.ID || changes[0].Price != 600 ||
+		changes[1].ID != later.ID || changes[1].Price != 800 || !changes[1].EffectiveFrom.Equal(month(2024, time.January)) {
+		t.Fatalf("price changes %+v", changes)
+	}
+
+	if got := get(t, repo, sub.ID); got.ListPrice != 800 || len(got.PriceChanges) != 2 {
+		t.Fatalf("list price %d with %d changes, want 800 with 2", got.ListPrice, len(got.PriceChanges))
+	}
+
+	changes, err = repo.ListPriceChanges(ctx, missingID)
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("price changes of a missing subscription: %v, %v", changes, err)
+	}
+
+	// Изменение проверяется по сроку подписки на момент записи
+	ended := create(t, repo, "Spotify", 300, testUsers[0], month(2024, time.January), monthPtr(2024, time.June))
+	wantErr(t, repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: ended.ID, Price: 400, EffectiveFrom: month(2024, time.January)}), ErrInvalidEffectiveDate)
+	wantErr(t, repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: ended.ID, Price: 400, EffectiveFrom: month(2024, time.July)}), ErrInvalidEffectiveDate)
+	wantErr(t, repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: ended.ID, Price: 0, EffectiveFrom: month(2024, time.March)}), ErrInvalidPrice)
+	wantErr(t, repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: missingID, Price: 400, EffectiveFrom: month(2024, time.March)}), ErrSubscriptionNotFound)
+	if got := get(t, repo, ended.ID); len(got.PriceChanges) != 0 {
+		t.Fatalf("rejected price changes were stored: %+v", got.PriceChanges)
+	}
+}
+
+func testPauses(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), monthPtr(2024, time.December))
+
+	pause, err := repo.Pause(ctx, sub.ID, month(2024, time.March), nil)
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if pause.ID == "" || pause.SubscriptionID != sub.ID || pause.EndDate != nil {
+		t.Fatalf("pause %+v", pause)
+	}
+
+	_, err = repo.Pause(ctx, sub.ID, month(2024, time.May), monthPtr(2024, time.June))
+	wantErr(t, err, ErrPauseOverlap)
+	_, err = repo.Pause(ctx, sub.ID, month(2025, time.January), nil)
+	wantErr(t, err, ErrPauseOutOfRange)
+	_, err = repo.Pause(ctx, missingID, month(2024, time.March), nil)
+	wantErr(t, err, ErrSubscriptionNotFound)
+
+	_, err = repo.Resume(ctx, sub.ID, month(2024, time.March))
+	wantErr(t, err, ErrInvalidResumeDate)
+	resumed, err := repo.Resume(ctx, sub.ID, month(2024, time.May))
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if resumed.ID != pause.ID || resumed.EndDate == nil || !resumed.EndDate.Equal(month(2024, time.April)) {
+		t.Fatalf("resumed pause %+v", resumed)
+	}
+	_, err = repo.Resume(ctx, sub.ID, month(2024, time.May))
+	wantErr(t, err, ErrNotPaused)
+
+	got := get(t, repo, sub.ID)
+	if len(got.Pauses) != 1 || !got.PausedIn(month(2024, time.April)) || got.PausedIn(month(2024, time.May)) {
+		t.Fatalf("pauses %+v", got.Pauses)
+	}
+}
+
+func testDiscounts(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), nil)
+
+	later := &Discount{Kind: DiscountFixed, Value: 100, StartDate: month(2024, time.June), DurationPeriods: 2}
+	earlier := &Discount{Kind: DiscountPercentage, Value: 10, StartDate: month(2024, time.February), DurationPeriods: 3, Description: " promo "}
+	for _, d := range []*Discount{later, earlier} {
+		if err := repo.AddDiscount(ctx, sub.ID, d); err != nil {
+			t.Fatalf("add discount: %v", err)
+		}
+		if d.ID == "" || d.SubscriptionID != sub.ID {
+			t.Fatalf("discount %+v", d)
+		}
+	}
+
+	wantErr(t, repo.AddDiscount(ctx, sub.ID, &Discount{Kind: DiscountFixed, Value: 50, StartDate: month(2024, time.April), DurationPeriods: 1}), ErrDiscountOverlap)
+	wantErr(t, repo.AddDiscount(ctx, sub.ID, &Discount{Kind: DiscountPercentage, Value: 150, StartDate: month(2025, time.January), DurationPeriods: 1}), ErrInvalidDiscount)
+	wantErr(t, repo.AddDiscount(ctx, missingID, &Discount{Kind: DiscountFixed, Value: 50, StartDate: month(2024, time.April), DurationPeriods: 1}), ErrSubscriptionNotFound)
+
+	got := get(t, repo, sub.ID)
+	if len(got.Discounts) != 2 || got.Discounts[0].ID != earlier.ID || got.Discounts[0].Description != "promo" || got.Discounts[1].ID != later.ID {
+		t.Fatalf("discounts %+v", got.Discounts)
+	}
+
+	if err := repo.RemoveDiscount(ctx, sub.ID, earlier.ID); err != nil {
+		t.Fatalf("remove discount: %v", err)
+	}
+	wantErr(t, repo.RemoveDiscount(ctx, sub.ID, earlier.ID), ErrDiscountNotFound)
+	wantErr(t, repo.RemoveDiscount(ctx, missingID, later.ID), ErrDiscountNotFound)
+
+	if got := get(t, repo, sub.ID); len(got.Discounts) != 1 || got.Discounts[0].ID != later.ID {
+		t.Fatalf("discounts after removal %+v", got.Discounts)
+	}
+}
+
+func testMembers(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), nil)
+
+	for _, m := range []*Member{
+		{UserID: testUsers[2], FixedAmount: intPtr(100)},
+		{UserID: testUsers[1], SharePercent: intPtr(60)},
+	} {
+		if err := repo.AddMember(ctx, sub.ID, m); err != nil {
+			t.Fatalf("add member: %v", err)
+		}
+		if m.SubscriptionID != sub.ID || m.CreatedAt.IsZero() {
+			t.Fatalf("member %+v", m)
+		}
+	}
+
+	wantErr(t, repo.AddMember(ctx, sub.ID, &Member{UserID: testUsers[1], SharePercent: intPtr(10)}), ErrMemberExists)
+	wantErr(t, repo.AddMember(ctx, sub.ID, &Member{UserID: testUsers[0], SharePercent: intPtr(10)}), ErrMemberIsOwner)
+	wantErr(t, repo.AddMember(ctx, sub.ID, &Member{UserID: testUsers[3], SharePercent: intPtr(50)}), ErrSharesExceedTotal)
+	// 100 из 500 — это 20%, вместе с 60% и новой долей выходит больше 100%
+	wantErr(t, repo.AddMember(ctx, sub.ID, &Member{UserID: testUsers[3], SharePercent: intPtr(30)}), ErrSharesExceedTotal)
+	wantErr(t, repo.AddMember(ctx, sub.ID, &Member{UserID: testUsers[3], FixedAmount: intPtr(150)}), ErrSharesExceedTotal)
+	wantErr(t, repo.AddMember(ctx, sub.ID, &Member{UserID: testUsers[3]}), ErrInvalidMemberShare)
+	wantErr(t, repo.AddMember(ctx, missingID, &Member{UserID: testUsers[3], SharePercent: intPtr(10)}), ErrSubscriptionNotFound)
+
+	// При цене 200 сумма 100 — это 50%, вместе с 60% больше 100%
+	wantErr(t, repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: sub.ID, Price: 200, EffectiveFrom: month(2024, time.June)}), ErrSharesExceedTotal)
+	if err := repo.AddPriceChange(ctx, &PriceChange{SubscriptionID: sub.ID, Price: 250, EffectiveFrom: month(2024, time.June)}); err != nil {
+		t.Fatalf("add price change: %v", err)
+	}
+	// 10% помещаются в цену 500, но не в запланированную цену 250
+	wantErr(t, repo.AddMember(ctx, sub.ID, &Member{UserID: testUsers[3], SharePercent: intPtr(10)}), ErrSharesExceedTotal)
+	if changes, err := repo.ListPriceChanges(ctx, sub.ID); err != nil || len(changes) != 1 || changes[0].Price != 250 {
+		t.Fatalf("price changes %+v, %v", changes, err)
+	}
+
+	got := get(t, repo, sub.ID)
+	if len(got.Members) != 2 || got.Members[0].UserID != testUsers[1] || got.Members[1].UserID != testUsers[2] ||
+		*got.Members[0].SharePercent != 60 || *got.Members[1].FixedAmount != 100 {
+		t.Fatalf("members should be sorted by user id: %+v", got.Members)
+	}
+
+	if err := repo.RemoveMember(ctx, sub.ID, testUsers[1]); err != nil {
+		t.Fatalf("remove member: %v", err)
+	}
+	wantErr(t, repo.RemoveMember(ctx, sub.ID, testUsers[1]), ErrMemberNotFound)
+	wantErr(t, repo.RemoveMember(ctx, missingID, testUsers[2]), ErrMemberNotFound)
+
+	if got := get(t, repo, sub.ID); len(got.Members) != 1 || got.Members[0].UserID != testUsers[2] {
+		t.Fatalf("members after removal %+v", got.Members)
+	}
+}
+
+func testCancelAndChurn(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	current := monthStart(time.Now().UTC())
+	start := current.AddDate(-1, 0, 0)
+
+	var subs []*Subscription
+	for _, service := range []string{"Netflix", "Netflix", "Spotify", "Spotify"} {
+		subs = append(subs, create(t, repo, service, 500, testUsers[0], start, nil))
+	}
+
+	cancelled, err := repo.Cancel(ctx, subs[0].ID, Cancellation{Effective: CancelEndOfPeriod, Reason: ReasonTooExpensive, Comment: " pricey "})
+	if err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if cancelled.EndDate == nil || !cancelled.EndDate.Equal(current) || cancelled.CancelledAt == nil ||
+		cancelled.CancellationReason != ReasonTooExpensive || cancelled.CancellationComment != "pricey" || cancelled.ListPrice != 500 {
+		t.Fatalf("cancelled %+v", cancelled)
+	}
+	if got := get(t, repo, subs[0].ID); got.CancelledAt == nil || !got.EndDate.Equal(current) {
+		t.Fatalf("stored cancellation %+v", got)
+	}
+
+	for i, reason := range []CancellationReason{ReasonTooExpensive, ReasonNotUsing} {
+		if _, err := repo.Cancel(ctx, subs[i+1].ID, Cancellation{Effective: CancelImmediately, Reason: reason}); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+	}
+
+	_, err = repo.Cancel(ctx, subs[0].ID, Cancellation{Effective: CancelEndOfPeriod, Reason: ReasonOther})
+	wantErr(t, err, ErrAlreadyCancelled)
+	_, err = repo.Cancel(ctx, subs[3].ID, Cancellation{Effective: CancelEndOfPeriod, Reason: "bored"})
+	wantErr(t, err, ErrInvalidCancellation)
+	_, err = repo.Cancel(ctx, missingID, Cancellation{Effective: CancelEndOfPeriod, Reason: ReasonOther})
+	wantErr(t, err, ErrSubscriptionNotFound)
+
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		want    []*ChurnStat
+	}{
+		{"all", map[string]interface{}{}, []*ChurnStat{
+			{ServiceName: "Netflix", Reason: ReasonTooExpensive, Count: 2},
+			{ServiceName: "Spotify", Reason: ReasonNotUsing, Count: 1},
+		}},
+		{"service", map[string]interface{}{"service_name": "Spotify"}, []*ChurnStat{
+			{ServiceName: "Spotify", Reason: ReasonNotUsing, Count: 1},
+		}},
+		{"current month", map[string]interface{}{"cancelled_from": current, "cancelled_to": current}, []*ChurnStat{
+			{ServiceName: "Netflix", Reason: ReasonTooExpensive, Count: 2},
+			{ServiceName: "Spotify", Reason: ReasonNotUsing, Count: 1},
+		}},
+		{"before", map[string]interface{}{"cancelled_to": current.AddDate(0, -1, 0)}, []*ChurnStat{}},
+		{"after", map[string]interface{}{"cancelled_from": current.AddDate(0, 1, 0)}, []*ChurnStat{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := repo.ChurnStats(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("churn stats: %v", err)
+			}
+			if len(stats) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(stats, tt.want) {
+				t.Fatalf("stats %v, want %v", stats, tt.want)
+			}
+		})
+	}
+}
+
+func testReturnsCopies(t *testing.T, repo ISubscriptionRepository) {
+	ctx := context.Background()
+	sub := create(t, repo, "Netflix", 500, testUsers[0], month(2024, time.January), nil)
+	if err := repo.AddMember(ctx, sub.ID, &Member{UserID: testUsers[1], SharePercent: intPtr(20)}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+
+	got := get(t, repo, sub.ID)
+	got.Price = 1
+	got.ServiceName = "Changed"
+	*got.Members[0].SharePercent = 99
+	got.Members = nil
+
+	sub.Price = 2
+
+	if again := get(t, repo, sub.ID); again.Price != 500 || again.ServiceName != "Netflix" ||
+		len(again.Members) != 1 || *again.Members[0].SharePercent != 20 {
+		t.Fatalf("stored subscription changed through a returned copy: %+v", again)
+	}
+}
@@ -0,0 +1,543 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"SubscriptionService/pkg/db"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// querier — общий интерфейс пула и транзакции для загрузки связанных данных.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (s *SubscriptionRepository) AddPriceChange(ctx context.Context, change *PriceChange) error {
+	query := `
+		INSERT INTO subscription_prices (subscription_id, price, effective_from)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (subscription_id, effective_from) DO UPDATE SET price = EXCLUDED.price
+		RETURNING id, created_at`
+
+	return s.withSpend(ctx, func() string { return change.SubscriptionID }, func(tx pgx.Tx) error {
+		// Проверка по заблокированной строке primary: параллельное изменение дат или
+		// отмена не пропустят изменение цены за пределами срока подписки
+		sub, err := s.getForUpdate(ctx, tx, change.SubscriptionID)
+		if err != nil {
+			return err
+		}
+		validated, err := sub.SchedulePriceChange(change.Price, change.EffectiveFrom)
+		if err != nil {
+			return err
+		}
+		change.EffectiveFrom = validated.EffectiveFrom
+
+		err = tx.QueryRow(ctx, query,
+			change.SubscriptionID,
+			change.Price,
+			change.EffectiveFrom,
+		).Scan(&change.ID, &change.CreatedAt)
+
+		if err != nil {
+			s.log(ctx).Error("failed to add price change",
+				zap.Error(err),
+				zap.String("subscription_id", change.SubscriptionID))
+			return fmt.Errorf("failed to add price change: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (s *SubscriptionRepository) ListPriceChanges(ctx context.Context, subscriptionID string) ([]*PriceChange, error) {
+	pool := s.router.Read(ctx)
+
+	query := `
+		SELECT id, subscription_id, price, effective_from, created_at
+		FROM subscription_prices
+		WHERE subscription_id = $1
+		ORDER BY effective_from`
+
+	rows, err := pool.Query(ctx, query, subscriptionID)
+	if err != nil {
+		s.log(ctx).Error("failed to list price changes",
+			zap.Error(err),
+			zap.String("subscription_id", subscriptionID))
+		return nil, fmt.Errorf("failed to list price changes: %w", err)
+	}
+
+	changes, err := pgx.CollectRows(rows, scanPriceChange)
+	if err != nil {
+		s.log(ctx).Error("failed to scan price changes",
+			zap.Error(err),
+			zap.String("subscription_id", subscriptionID))
+		return nil, fmt.Errorf("failed to list price changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// Pause приостанавливает подписку. Проверка пересечений выполняется в транзакции
+// под блокировкой строки подписки, чтобы параллельные запросы не создали
+// перекрывающиеся паузы.
+func (s *SubscriptionRepository) Pause(ctx context.Context, subscriptionID string, from time.Time, to *time.Time) (*Pause, error) {
+	db.MarkWrite(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.log(ctx).Error("failed to begin transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to pause subscription: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sub, err := s.getForUpdate(ctx, tx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pause, err := sub.Pause(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO subscription_pauses (subscription_id, start_date, end_date)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	if err := tx.QueryRow(ctx, query,
+		pause.SubscriptionID,
+		pause.StartDate,
+		pause.EndDate,
+	).Scan(&pause.ID, &pause.CreatedAt); err != nil {
+		s.log(ctx).Error("failed to insert pause",
+			zap.Error(err),
+			zap.String("subscription_id", subscriptionID))
+		return nil, fmt.Errorf("failed to pause subscription: %w", err)
+	}
+
+	if err := s.refreshSpend(ctx, tx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		s.log(ctx).Error("failed to commit pause", zap.Error(err))
+		return nil, fmt.Errorf("failed to pause subscription: %w", err)
+	}
+
+	return pause, nil
+}
+
+// Resume завершает паузу, действующую в указанном месяце.
+func (s *SubscriptionRepository) Resume(ctx context.Context, subscriptionID string, month time.Time) (*Pause, error) {
+	db.MarkWrite(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.log(ctx).Error("failed to begin transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to resume subscription: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sub, err := s.getForUpdate(ctx, tx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pause, err := sub.Resume(month)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE subscription_pauses SET end_date = $1 WHERE id = $2`,
+		pause.EndDate, pause.ID,
+	); err != nil {
+		s.log(ctx).Error("failed to update pause",
+			zap.Error(err),
+			zap.String("subscription_id", subscriptionID))
+		return nil, fmt.Errorf("failed to resume subscription: %w", err)
+	}
+
+	if err := s.refreshSpend(ctx, tx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		s.log(ctx).Error("failed to commit resume", zap.Error(err))
+		return nil, fmt.Errorf("failed to resume subscription: %w", err)
+	}
+
+	return pause, nil
+}
+
+// AddDiscount добавляет скидку к подписке, проверяя пересечения под блокировкой строки подписки.
+func (s *SubscriptionRepository) AddDiscount(ctx context.Context, subscriptionID string, discount *Discount) error {
+	db.MarkWrite(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.log(ctx).Error("failed to begin transaction", zap.Error(err))
+		return fmt.Errorf("failed to add discount: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sub, err := s.getForUpdate(ctx, tx, subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	if err := sub.AddDiscount(discount); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO subscription_discounts (subscription_id, kind, value, start_date, duration_periods, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	if err := tx.QueryRow(ctx, query,
+		discount.SubscriptionID,
+		discount.Kind,
+		discount.Value,
+		discount.StartDate,
+		discount.DurationPeriods,
+		discount.Description,
+	).Scan(&discount.ID, &discount.CreatedAt); err != nil {
+		s.log(ctx).Error("failed to insert discount",
+			zap.Error(err),
+			zap.String("subscription_id", subscriptionID))
+		return fmt.Errorf("failed to add discount: %w", err)
+	}
+
+	if err := s.refreshSpend(ctx, tx, subscriptionID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		s.log(ctx).Error("failed to commit discount", zap.Error(err))
+		return fmt.Errorf("failed to add discount: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SubscriptionRepository) RemoveDiscount(ctx context.Context, subscriptionID, discountID string) error {
+	query := `DELETE FROM subscription_discounts WHERE id = $1 AND subscription_id = $2`
+
+	return s.withSpend(ctx, func() string { return subscriptionID }, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, query, discountID, subscriptionID)
+		if err != nil {
+			s.log(ctx).Error("failed to remove discount",
+				zap.Error(err),
+				zap.String("subscription_id", subscriptionID),
+				zap.String("discount_id", discountID))
+			return fmt.Errorf("failed to remove discount: %w", err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return ErrDiscountNotFound
+		}
+
+		return nil
+	})
+}
+
+// AddMember добавляет участника подписки. Сумма долей проверяется в транзакции
+// под блокировкой строки подписки, чтобы параллельные добавления не превысили 100%.
+func (s *SubscriptionRepository) AddMember(ctx context.Context, subscriptionID string, member *Member) error {
+	db.MarkWrite(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.log(ctx).Error("failed to begin transaction", zap.Error(err))
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sub, err := s.getForUpdate(ctx, tx, subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	if err := sub.AddMember(member); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO subscription_members (subscription_id, user_id, share_percent, fixed_amount)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at`
+
+	if err := tx.QueryRow(ctx, query,
+		member.SubscriptionID,
+		member.UserID,
+		member.SharePercent,
+		member.FixedAmount,
+	).Scan(&member.CreatedAt); err != nil {
+		s.log(ctx).Error("failed to insert member",
+			zap.Error(err),
+			zap.String("subscription_id", subscriptionID),
+			zap.String("user_id", member.UserID))
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+
+	if err := s.refreshSpend(ctx, tx, subscriptionID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		s.log(ctx).Error("failed to commit member", zap.Error(err))
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SubscriptionRepository) RemoveMember(ctx context.Context, subscriptionID, userID string) error {
+	query := `DELETE FROM subscription_members WHERE subscription_id = $1 AND user_id = $2`
+
+	return s.withSpend(ctx, func() string { return subscriptionID }, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, query, subscriptionID, userID)
+		if err != nil {
+			s.log(ctx).Error("failed to remove member",
+				zap.Error(err),
+				zap.String("subscription_id", subscriptionID),
+				zap.String("user_id", userID))
+			return fmt.Errorf("failed to remove member: %w", err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return ErrMemberNotFound
+		}
+
+		return nil
+	})
+}
+
+// Cancel отменяет подписку под блокировкой строки, чтобы повторная отмена
+// не перезаписала дату окончания.
+func (s *SubscriptionRepository) Cancel(ctx context.Context, subscriptionID string, cancellation Cancellation) (*Subscription, error) {
+	db.MarkWrite(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.log(ctx).Error("failed to begin transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to cancel subscription: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sub, err := s.getForUpdate(ctx, tx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sub.Cancel(cancellation, time.Now()); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE subscriptions
+		SET end_date = $1, cancelled_at = $2, cancellation_reason = $3,
+			cancellation_comment = $4, updated_at = NOW()
+		WHERE id = $5`
+
+	if _, err := tx.Exec(ctx, query,
+		sub.EndDate,
+		sub.CancelledAt,
+		sub.CancellationReason,
+		sub.CancellationComment,
+		sub.ID,
+	); err != nil {
+		s.log(ctx).Error("failed to cancel subscription",
+			zap.Error(err),
+			zap.String("id", subscriptionID))
+		return nil, fmt.Errorf("failed to cancel subscription: %w", err)
+	}
+
+	if err := s.refreshSpend(ctx, tx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		s.log(ctx).Error("failed to commit cancellation", zap.Error(err))
+		return nil, fmt.Errorf("failed to cancel subscription: %w", err)
+	}
+
+	sub.RefreshPricing(time.Now().UTC())
+	return sub, nil
+}
+
+// getForUpdate загружает подписку со связанными данными, блокируя её строку
+// до конца транзакции.
+func (s *SubscriptionRepository) getForUpdate(ctx context.Context, tx pgx.Tx, id string) (*Subscription, error) {
+	query := `
+		SELECT id, service_name, price, user_id, start_date, end_date,
+		       cancelled_at, cancellation_reason, cancellation_comment
+		FROM subscriptions
+		WHERE id = $1
+		FOR UPDATE`
+
+	rows, err := tx.Query(ctx, query, id)
+	if err != nil {
+		s.log(ctx).Error("failed to lock subscription",
+			zap.Error(err),
+			zap.String("id", id))
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	sub, err := pgx.CollectExactlyOneRow(rows, scanSubscription)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSubscriptionNotFound
+		}
+		s.log(ctx).Error("failed to lock subscription",
+			zap.Error(err),
+			zap.String("id", id))
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	if err := s.attachDetails(ctx, tx, []*Subscription{sub}); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// attachDetails загружает расписание цен, паузы, скидки и участников для набора подписок
+// и пересчитывает их текущую цену.
+func (s *SubscriptionRepository) attachDetails(ctx context.Context, q querier, subs []*Subscription) error {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(subs))
+	byID := make(map[string]*Subscription, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+		byID[sub.ID] = sub
+	}
+
+	changes, err := collectChildren(ctx, q, ids, `
+		SELECT id, subscription_id, price, effective_from, created_at
+		FROM subscription_prices
+		WHERE subscription_id = ANY($1::uuid[])
+		ORDER BY effective_from`, scanPriceChange)
+	if err != nil {
+		s.log(ctx).Error("failed to load price changes", zap.Error(err))
+		return fmt.Errorf("failed to load price changes: %w", err)
+	}
+	for _, pc := range changes {
+		byID[pc.SubscriptionID].PriceChanges = append(byID[pc.SubscriptionID].PriceChanges, pc)
+	}
+
+	pauses, err := collectChildren(ctx, q, ids, `
+		SELECT id, subscription_id, start_date, end_date, created_at
+		FROM subscription_pauses
+		WHERE subscription_id = ANY($1::uuid[])
+		ORDER BY start_date`, scanPause)
+	if err != nil {
+		s.log(ctx).Error("failed to load pauses", zap.Error(err))
+		return fmt.Errorf("failed to load pauses: %w", err)
+	}
+	for _, p := range pauses {
+		byID[p.SubscriptionID].Pauses = append(byID[p.SubscriptionID].Pauses, p)
+	}
+
+	discounts, err := collectChildren(ctx, q, ids, `
+		SELECT id, subscription_id, kind, value, start_date, duration_periods, description, created_at
+		FROM subscription_discounts
+		WHERE subscription_id = ANY($1::uuid[])
+		ORDER BY start_date`, scanDiscount)
+	if err != nil {
+		s.log(ctx).Error("failed to load discounts", zap.Error(err))
+		return fmt.Errorf("failed to load discounts: %w", err)
+	}
+	for _, d := range discounts {
+		byID[d.SubscriptionID].Discounts = append(byID[d.SubscriptionID].Discounts, d)
+	}
+
+	members, err := collectChildren(ctx, q, ids, `
+		SELECT subscription_id, user_id, share_percent, fixed_amount, created_at
+		FROM subscription_members
+		WHERE subscription_id = ANY($1::uuid[])
+		ORDER BY user_id`, scanMember)
+	if err != nil {
+		s.log(ctx).Error("failed to load members", zap.Error(err))
+		return fmt.Errorf("failed to load members: %w", err)
+	}
+	for _, m := range members {
+		byID[m.SubscriptionID].Members = append(byID[m.SubscriptionID].Members, m)
+	}
+
+	now := time.Now().UTC()
+	for _, sub := range subs {
+		sub.RefreshPricing(now)
+	}
+
+	return nil
+}
+
+func collectChildren[T any](ctx context.Context, q querier, ids []string, query string, scan pgx.RowToFunc[T]) ([]T, error) {
+	rows, err := q.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scan)
+}
+
+func scanPause(row pgx.CollectableRow) (*Pause, error) {
+	var p Pause
+	err := row.Scan(
+		&p.ID,
+		&p.SubscriptionID,
+		&p.StartDate,
+		&p.EndDate,
+		&p.CreatedAt,
+	)
+	return &p, err
+}
+
+func scanPriceChange(row pgx.CollectableRow) (*PriceChange, error) {
+	var pc PriceChange
+	err := row.Scan(
+		&pc.ID,
+		&pc.SubscriptionID,
+		&pc.Price,
+		&pc.EffectiveFrom,
+		&pc.CreatedAt,
+	)
+	return &pc, err
+}
+
+func scanDiscount(row pgx.CollectableRow) (*Discount, error) {
+	var d Discount
+	err := row.Scan(
+		&d.ID,
+		&d.SubscriptionID,
+		&d.Kind,
+		&d.Value,
+		&d.StartDate,
+		&d.DurationPeriods,
+		&d.Description,
+		&d.CreatedAt,
+	)
+	return &d, err
+}
+
+func scanMember(row pgx.CollectableRow) (*Member, error) {
+	var m Member
+	err := row.Scan(
+		&m.SubscriptionID,
+		&m.UserID,
+		&m.SharePercent,
+		&m.FixedAmount,
+		&m.CreatedAt,
+	)
+	return &m, err
+}
@@ -2,37 +2,65 @@ package subscriptions
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 	"time"
 
+	"SubscriptionService/internal/config"
+	"SubscriptionService/internal/logging"
+	"SubscriptionService/internal/tracing"
+	"SubscriptionService/pkg/db"
+
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// RequestIDHeader передаётся клиентом или генерируется сервером и возвращается в ответе.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину принятого от клиента идентификатора запроса
+const maxRequestIDLength = 128
+
 type Server struct {
-	httpServer *http.Server
-	logger     *zap.Logger
-	router     *gin.Engine
+	httpServer   *http.Server
+	logger       *zap.Logger
+	accessLogger *zap.Logger
+	router       *gin.Engine
+	drainDelay   time.Duration
+	onShutdown   []func()
 }
 
-func NewServer(logger *zap.Logger) *Server {
+// NewServer creates the HTTP server. accessLogger receives one line per
+// request and is usually sampled, see logging.Sampled.
+func NewServer(logger, accessLogger *zap.Logger, cfg config.HTTPConfig) *Server {
 	router := gin.New()
+	// Без доверенных прокси ClientIP берёт адрес соединения, а не X-Forwarded-For
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Error("Invalid trusted proxies", zap.Error(err))
+	}
 
 	//  swagger роутинг
 	url := ginSwagger.URL("/swagger/doc.json")
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
 
 	server := &Server{
-		logger: logger,
-		router: router,
+		logger:       logger,
+		accessLogger: accessLogger,
+		router:       router,
+		drainDelay:   cfg.ShutdownDelay,
 		httpServer: &http.Server{
-			Addr:         ":8080",
+			Addr:         fmt.Sprintf(":%d", cfg.Port),
 			Handler:      router,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			IdleTimeout:  60 * time.Second,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
 		},
 	}
 
@@ -46,8 +74,28 @@ func (s *Server) Start(addr string) error {
 	return s.httpServer.ListenAndServe()
 }
 
+// OnShutdown registers fn to run when Shutdown starts, before the server stops
+// accepting connections. It is used to make readiness fail first.
+func (s *Server) OnShutdown(fn func()) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Shutdown runs the OnShutdown hooks, keeps serving for the configured drain
+// delay so load balancers notice the failing readiness, and then gracefully
+// stops the server.
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("Shutting down HTTP server")
+	for _, fn := range s.onShutdown {
+		fn()
+	}
+
+	if s.drainDelay > 0 {
+		s.logger.Info("Waiting before closing connections", zap.Duration("delay", s.drainDelay))
+		select {
+		case <-time.After(s.drainDelay):
+		case <-ctx.Done():
+		}
+	}
 	return s.httpServer.Shutdown(ctx)
 }
 
@@ -58,16 +106,58 @@ func (s *Server) GetRouter() *gin.Engine {
 func (s *Server) setupMiddleware() {
 	s.router.Use(
 		gin.Recovery(),
+		// span создаётся до requestID, чтобы trace_id попал в логгер запроса
+		otelgin.Middleware(tracing.ServiceName),
+		s.requestIDMiddleware(),
 		s.loggingMiddleware(),
 	)
 }
 
+// requestIDMiddleware берёт X-Request-ID из запроса или генерирует новый и кладёт
+// в контекст запроса логгер с этим идентификатором. Запрос также становится сессией
+// read-your-writes: после записи чтения в нём не уходят в реплики.
+func (s *Server) requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("http.request_id", requestID))
+		ctx := logging.WithRequest(c.Request.Context(), s.logger, requestID)
+		c.Request = c.Request.WithContext(db.WithSession(ctx))
+		c.Next()
+	}
+}
+
+// validRequestID пропускает только короткие идентификаторы из печатных ASCII-символов,
+// чтобы клиент не мог подделать строки лога
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
 func (s *Server) loggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		c.Next()
 
-		s.logger.Info("HTTP request",
+		s.accessLogger.Info("HTTP request",
+			zap.String("request_id", logging.RequestID(c.Request.Context())),
+			zap.String("trace_id", trace.SpanContextFromContext(c.Request.Context()).TraceID().String()),
 			zap.String("method", c.Request.Method),
 			zap.String("path", c.Request.URL.Path),
 			zap.Int("status", c.Writer.Status()),
@@ -75,3 +165,13 @@ func (s *Server) loggingMiddleware() gin.HandlerFunc {
 		)
 	}
 }
+
+// serverError отвечает 503, если БД недоступна (в том числе открыт circuit breaker),
+// чтобы клиент и балансировщик повторили запрос позже, и 500 в остальных случаях.
+func serverError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	if db.IsUnavailable(err) {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{"error": message})
+}
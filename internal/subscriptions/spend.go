@@ -0,0 +1,160 @@
+package subscriptions
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MonthlySpend is one row of the monthly_spend rollup: the amount a user pays
+// for a service in a month. Rows are stored per subscription so that a change
+// to one subscription only rewrites its own rows.
+type MonthlySpend struct {
+	SubscriptionID string    `json:"subscription_id,omitempty"`
+	UserID         string    `json:"user_id,omitempty"`
+	ServiceName    string    `json:"service_name"`
+	Month          time.Time `json:"month"`
+	Amount         int       `json:"amount"`
+}
+
+// SpendRows разбивает стоимость подписки на доли участников по месяцам с начала
+// подписки до окончания, но не дальше through. Сумма долей месяца равна
+// CostForMonth, поэтому агрегат даёт те же итоги, что и расчёт на лету.
+func (s *Subscription) SpendRows(through time.Time) []MonthlySpend {
+	from, to := s.clampPeriod(time.Time{}, through)
+
+	var rows []MonthlySpend
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		for userID, amount := range s.SharesForMonth(month) {
+			if amount == 0 {
+				continue
+			}
+			rows = append(rows, MonthlySpend{
+				SubscriptionID: s.ID,
+				UserID:         userID,
+				ServiceName:    s.ServiceName,
+				Month:          month,
+				Amount:         amount,
+			})
+		}
+	}
+	return rows
+}
+
+// SpendQuery is a cost query the rollup can answer.
+type SpendQuery struct {
+	UserID      string
+	ServiceName string
+	// From is the zero time when the period starts with each subscription.
+	From time.Time
+	To   time.Time
+}
+
+// spendFilters — фильтры, которые агрегат учитывает так же, как costConditions.
+var spendFilters = map[string]bool{
+	"user_id":         true,
+	"service_name":    true,
+	"start_date_from": true,
+	"start_date_to":   true,
+}
+
+// NewSpendQuery converts cost filters to a rollup query. It reports false when
+// the filters need the on-the-fly calculation: unknown keys such as user_ids,
+// or dates that are not the first day of a month.
+func NewSpendQuery(filters map[string]interface{}, now time.Time) (SpendQuery, bool) {
+	var q SpendQuery
+	for key := range filters {
+		if !spendFilters[key] {
+			return q, false
+		}
+	}
+
+	var ok bool
+	if v, has := filters["user_id"]; has {
+		if q.UserID, ok = v.(string); !ok || q.UserID == "" {
+			return q, false
+		}
+	}
+	if v, has := filters["service_name"]; has {
+		if q.ServiceName, ok = v.(string); !ok || q.ServiceName == "" {
+			return q, false
+		}
+	}
+	if v, has := filters["start_date_from"]; has {
+		if q.From, ok = v.(time.Time); !ok || !q.From.Equal(monthStart(q.From)) {
+			return q, false
+		}
+		q.From = monthStart(q.From)
+	}
+	q.To = monthStart(now)
+	if v, has := filters["start_date_to"]; has {
+		if q.To, ok = v.(time.Time); !ok || !q.To.Equal(monthStart(q.To)) {
+			return q, false
+		}
+		q.To = monthStart(q.To)
+	}
+	return q, true
+}
+
+// SpendRollup продлевает горизонт агрегата monthly_spend и при первом запуске
+// заполняет его, если этого ещё не сделала команда backfill.
+type SpendRollup struct {
+	repo    *SubscriptionRepository
+	logger  *zap.Logger
+	now     Clock
+	horizon int
+}
+
+// NewSpendRollup creates the maintenance job. horizonMonths is how many months
+// after the current one are kept materialized for subscriptions without an end.
+func NewSpendRollup(repo *SubscriptionRepository, logger *zap.Logger, clock Clock, horizonMonths int) *SpendRollup {
+	return &SpendRollup{repo: repo, logger: logger, now: clock, horizon: horizonMonths}
+}
+
+// Maintain заполняет агрегат, если он не готов, или продлевает горизонт, если
+// до его конца осталось меньше половины.
+func (r *SpendRollup) Maintain(ctx context.Context) error {
+	state, err := r.repo.SpendState(ctx)
+	if err != nil {
+		return err
+	}
+
+	current := monthStart(r.now())
+	through := current.AddDate(0, r.horizon, 0)
+	switch {
+	case !state.Ready:
+		r.logger.Info("Заполнение агрегата monthly_spend", zap.Time("through", through))
+		n, err := r.repo.RebuildSpend(ctx, through, true, nil)
+		if err != nil {
+			return err
+		}
+		r.logger.Info("Агрегат monthly_spend заполнен", zap.Int("subscriptions", n))
+	case state.Through.Before(current.AddDate(0, r.horizon/2, 0)):
+		n, err := r.repo.RebuildSpend(ctx, through, false, nil)
+		if err != nil {
+			return err
+		}
+		r.logger.Info("Горизонт monthly_spend продлён",
+			zap.Time("through", through), zap.Int("subscriptions", n))
+	}
+	return nil
+}
+
+// Run периодически вызывает Maintain до отмены контекста.
+func (r *SpendRollup) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Maintain(ctx); err != nil {
+			r.logger.Error("monthly spend maintenance failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
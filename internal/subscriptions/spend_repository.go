@@ -0,0 +1,269 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"SubscriptionService/pkg/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// spendLockID — ключ advisory lock, под которым выполняется пересчёт агрегата,
+// чтобы фоновая задача и команда backfill не работали одновременно.
+const spendLockID int64 = 0x5370656e64526f6c // "SpendRol"
+
+// SpendState describes how far the monthly_spend rollup is materialized.
+type SpendState struct {
+	// Ready is false until the first full backfill has completed.
+	Ready bool `json:"ready"`
+	// Through is the last month with rows for subscriptions without an end date.
+	Through time.Time `json:"through"`
+}
+
+func (s *SubscriptionRepository) SpendState(ctx context.Context) (SpendState, error) {
+	return s.spendState(ctx, s.db)
+}
+
+// spendState читает состояние агрегата из пула, которым будет прочитан и сам агрегат:
+// реплика может отставать от primary.
+func (s *SubscriptionRepository) spendState(ctx context.Context, pool *pgxpool.Pool) (SpendState, error) {
+	var state SpendState
+	err := pool.QueryRow(ctx,
+		`SELECT ready, materialized_through FROM monthly_spend_state`,
+	).Scan(&state.Ready, &state.Through)
+	if err != nil {
+		s.log(ctx).Error("failed to read monthly spend state", zap.Error(err))
+		return state, fmt.Errorf("failed to read monthly spend state: %w", err)
+	}
+	return state, nil
+}
+
+// refreshSpend пересчитывает строки monthly_spend подписки в транзакции записи.
+// Строка подписки блокируется, поэтому параллельные изменения и пересчёт
+// горизонта применяются по очереди. Удалённой подписке строки не нужны: их
+// удаляет каскад.
+func (s *SubscriptionRepository) refreshSpend(ctx context.Context, tx pgx.Tx, subscriptionID string) error {
+	sub, err := s.getForUpdate(ctx, tx, subscriptionID)
+	if errors.Is(err, ErrSubscriptionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Во время продления горизонта новые строки считаются уже до будущей границы
+	var through time.Time
+	if err := tx.QueryRow(ctx,
+		`SELECT GREATEST(materialized_through, pending_through) FROM monthly_spend_state`,
+	).Scan(&through); err != nil {
+		s.log(ctx).Error("failed to read monthly spend state", zap.Error(err))
+		return fmt.Errorf("failed to refresh monthly spend: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM monthly_spend WHERE subscription_id = $1`, subscriptionID); err != nil {
+		s.log(ctx).Error("failed to clear monthly spend",
+			zap.Error(err),
+			zap.String("subscription_id", subscriptionID))
+		return fmt.Errorf("failed to refresh monthly spend: %w", err)
+	}
+
+	rows := sub.SpendRows(through)
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"monthly_spend"},
+		[]string{"subscription_id", "user_id", "service_name", "month", "amount"},
+		pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+			r := rows[i]
+			return []any{r.SubscriptionID, r.UserID, r.ServiceName, r.Month, r.Amount}, nil
+		}))
+	if err != nil {
+		s.log(ctx).Error("failed to write monthly spend",
+			zap.Error(err),
+			zap.String("subscription_id", subscriptionID))
+		return fmt.Errorf("failed to refresh monthly spend: %w", err)
+	}
+	return nil
+}
+
+// withSpend выполняет запись fn и пересчёт агрегата подписки в одной транзакции.
+func (s *SubscriptionRepository) withSpend(ctx context.Context, subscriptionID func() string, fn func(tx pgx.Tx) error) error {
+	db.MarkWrite(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.log(ctx).Error("failed to begin transaction", zap.Error(err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := s.refreshSpend(ctx, tx, subscriptionID()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		s.log(ctx).Error("failed to commit transaction", zap.Error(err))
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RebuildSpend пересчитывает monthly_spend до месяца through включительно и
+// возвращает число обработанных подписок. С all=false пересчитываются только
+// подписки, действующие после текущего горизонта, — этого достаточно для его
+// продления. Полный пересчёт помечает агрегат готовым. progress, если задан,
+// вызывается после каждой подписки.
+func (s *SubscriptionRepository) RebuildSpend(ctx context.Context, through time.Time, all bool, progress func(done, total int)) (int, error) {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, spendLockID); err != nil {
+		return 0, fmt.Errorf("failed to acquire monthly spend lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, spendLockID); err != nil {
+			s.logger.Error("failed to release monthly spend lock", zap.Error(err))
+		}
+	}()
+
+	// Сначала объявляем будущую границу, чтобы параллельные записи считали строки
+	// до неё, затем пересчитываем подписки и только после этого открываем новые
+	// месяцы для чтения. Горизонт не сужается.
+	var current time.Time
+	if err := conn.QueryRow(ctx, `
+		UPDATE monthly_spend_state
+		SET pending_through = GREATEST(materialized_through, $1)
+		RETURNING materialized_through, pending_through`, monthStart(through),
+	).Scan(&current, &through); err != nil {
+		return 0, fmt.Errorf("failed to update monthly spend state: %w", err)
+	}
+
+	query := `SELECT id FROM subscriptions ORDER BY id`
+	var args []any
+	if !all {
+		query = `SELECT id FROM subscriptions WHERE end_date IS NULL OR end_date > $1 ORDER BY id`
+		args = append(args, current)
+	}
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list subscriptions for monthly spend: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, fmt.Errorf("failed to list subscriptions for monthly spend: %w", err)
+	}
+
+	for i, id := range ids {
+		err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+			return s.refreshSpend(ctx, tx, id)
+		})
+		if err != nil {
+			return i, err
+		}
+		if progress != nil {
+			progress(i+1, len(ids))
+		}
+	}
+
+	if _, err := conn.Exec(ctx, `
+		UPDATE monthly_spend_state
+		SET materialized_through = $1, pending_through = NULL, ready = ready OR $2`, through, all,
+	); err != nil {
+		return len(ids), fmt.Errorf("failed to update monthly spend state: %w", err)
+	}
+	return len(ids), nil
+}
+
+// spendTotal отвечает на запрос стоимости из агрегата. ok=false, если агрегат не
+// готов или период выходит за его горизонт.
+func (s *SubscriptionRepository) spendTotal(ctx context.Context, q SpendQuery) (int, bool, error) {
+	pool := s.router.Read(ctx)
+	state, err := s.spendState(ctx, pool)
+	if err != nil || !state.Ready || q.To.After(state.Through) {
+		return 0, false, err
+	}
+
+	conditions, args := spendConditions(q)
+	var total int64
+	err = pool.QueryRow(ctx,
+		`SELECT COALESCE(SUM(amount), 0)::bigint FROM monthly_spend WHERE `+strings.Join(conditions, " AND "),
+		args...,
+	).Scan(&total)
+	if err != nil {
+		s.log(ctx).Error("failed to sum monthly spend", zap.Error(err))
+		return 0, false, fmt.Errorf("failed to sum monthly spend: %w", err)
+	}
+	return int(total), true, nil
+}
+
+// MonthlySpend возвращает суммы по сервисам и месяцам периода из агрегата. Для
+// фильтров, которые агрегат не поддерживает, или периода за горизонтом
+// возвращает ok=false, и вызывающий считает по подпискам.
+func (s *SubscriptionRepository) MonthlySpend(ctx context.Context, filters map[string]interface{}) ([]*MonthlySpend, bool, error) {
+	q, ok := NewSpendQuery(filters, time.Now().UTC())
+	if !ok {
+		return nil, false, nil
+	}
+	pool := s.router.Read(ctx)
+	state, err := s.spendState(ctx, pool)
+	if err != nil || !state.Ready || q.To.After(state.Through) {
+		return nil, false, err
+	}
+
+	conditions, args := spendConditions(q)
+	rows, err := pool.Query(ctx, `
+		SELECT service_name, month, SUM(amount)::bigint
+		FROM monthly_spend
+		WHERE `+strings.Join(conditions, " AND ")+`
+		GROUP BY service_name, month
+		ORDER BY month, service_name`, args...)
+	if err != nil {
+		s.log(ctx).Error("failed to list monthly spend", zap.Error(err))
+		return nil, false, fmt.Errorf("failed to list monthly spend: %w", err)
+	}
+
+	spend, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*MonthlySpend, error) {
+		m := &MonthlySpend{}
+		var amount int64
+		err := row.Scan(&m.ServiceName, &m.Month, &amount)
+		m.Amount = int(amount)
+		return m, err
+	})
+	if err != nil {
+		s.log(ctx).Error("failed to scan monthly spend", zap.Error(err))
+		return nil, false, fmt.Errorf("failed to list monthly spend: %w", err)
+	}
+	return spend, true, nil
+}
+
+func spendConditions(q SpendQuery) ([]string, []any) {
+	conditions := []string{"month <= $1"}
+	args := []any{q.To}
+
+	if !q.From.IsZero() {
+		args = append(args, q.From)
+		conditions = append(conditions, fmt.Sprintf("month >= $%d", len(args)))
+	}
+	if q.UserID != "" {
+		args = append(args, q.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if q.ServiceName != "" {
+		args = append(args, q.ServiceName)
+		conditions = append(conditions, fmt.Sprintf("service_name = $%d", len(args)))
+	}
+	return conditions, args
+}
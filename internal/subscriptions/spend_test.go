@@ -0,0 +1,335 @@
+package subscriptions
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	testServices = []string{"Netflix", "Spotify", "Yandex Plus"}
+	testUsers    = []string{
+		"60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		"7b0d4a4e-3f36-4c0e-9a57-1f0f3bd0f2a1",
+		"c2a4b3f0-6a51-4b3e-8b7e-5d4c3b2a1f00",
+		"e9f8d7c6-b5a4-4321-8fed-cba987654321",
+	}
+	testEpoch = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func randomMonth(r *rand.Rand, span int) time.Time {
+	return testEpoch.AddDate(0, r.IntN(span), 0)
+}
+
+// randomSubscription строит подписку с изменениями цены, паузами, скидками и
+// участниками. Они добавляются через методы модели, как это делает API, и
+// отклонённые ими варианты пропускаются.
+func randomSubscription(r *rand.Rand, n int) *Subscription {
+	sub := &Subscription{
+		ID:          fmt.Sprintf("00000000-0000-0000-0000-%012d", n),
+		ServiceName: testServices[r.IntN(len(testServices))],
+		Price:       100 + r.IntN(1000),
+		UserID:      testUsers[r.IntN(len(testUsers))],
+		StartDate:   randomMonth(r, 36),
+	}
+	if r.IntN(2) == 0 {
+		end := sub.StartDate.AddDate(0, r.IntN(30), 0)
+		sub.EndDate = &end
+	}
+
+	effective := sub.StartDate
+	for range r.IntN(3) {
+		effective = effective.AddDate(0, 1+r.IntN(6), 0)
+		sub.SchedulePriceChange(50+r.IntN(1500), effective)
+	}
+
+	pauseFrom := sub.StartDate
+	for range r.IntN(3) {
+		pauseFrom = pauseFrom.AddDate(0, 1+r.IntN(6), 0)
+		var to *time.Time
+		if r.IntN(4) > 0 {
+			end := pauseFrom.AddDate(0, r.IntN(3), 0)
+			to = &end
+		}
+		if _, err := sub.Pause(pauseFrom, to); err != nil || to == nil {
+			break
+		}
+		pauseFrom = *to
+	}
+
+	discountFrom := sub.StartDate
+	for range r.IntN(3) {
+		discountFrom = discountFrom.AddDate(0, r.IntN(4), 0)
+		d := &Discount{Kind: DiscountPercentage, Value: 1 + r.IntN(100), StartDate: discountFrom, DurationPeriods: 1 + r.IntN(6)}
+		if r.IntN(2) == 0 {
+			d.Kind, d.Value = DiscountFixed, 1+r.IntN(500)
+		}
+		sub.AddDiscount(d)
+		discountFrom = discountFrom.AddDate(0, d.DurationPeriods, 0)
+	}
+
+	for _, userID := range testUsers {
+		if userID == sub.UserID || r.IntN(3) > 0 {
+			continue
+		}
+		m := &Member{UserID: userID}
+		if r.IntN(2) == 0 {
+			p := 1 + r.IntN(100)
+			m.SharePercent = &p
+		} else {
+			amount := 1 + r.IntN(400)
+			m.FixedAmount = &amount
+		}
+		sub.AddMember(m)
+	}
+	return sub
+}
+
+func randomFilters(r *rand.Rand) map[string]interface{} {
+	filters := map[string]interface{}{}
+	if r.IntN(2) == 0 {
+		filters["user_id"] = testUsers[r.IntN(len(testUsers))]
+	}
+	if r.IntN(2) == 0 {
+		filters["service_name"] = testServices[r.IntN(len(testServices))]
+	}
+	from := randomMonth(r, 60)
+	if r.IntN(3) > 0 {
+		filters["start_date_from"] = from
+	}
+	if r.IntN(3) > 0 {
+		filters["start_date_to"] = from.AddDate(0, r.IntN(24), 0)
+	}
+	return filters
+}
+
+// matchesCostConditions повторяет условия costConditions, по которым
+// ListForPeriod выбирает подписки.
+func matchesCostConditions(sub *Subscription, filters map[string]interface{}) bool {
+	if userID, ok := filters["user_id"].(string); ok {
+		member := sub.UserID == userID
+		for _, m := range sub.Members {
+			member = member || m.UserID == userID
+		}
+		if !member {
+			return false
+		}
+	}
+	if service, ok := filters["service_name"].(string); ok && sub.ServiceName != service {
+		return false
+	}
+	if from, ok := filters["start_date_from"].(time.Time); ok && sub.EndDate != nil && sub.EndDate.Before(from) {
+		return false
+	}
+	if to, ok := filters["start_date_to"].(time.Time); ok && sub.StartDate.After(to) {
+		return false
+	}
+	return true
+}
+
+// materialize возвращает строки агрегата для подписок до месяца through.
+func materialize(subs []*Subscription, through time.Time) []MonthlySpend {
+	var rows []MonthlySpend
+	for _, sub := range subs {
+		rows = append(rows, sub.SpendRows(through)...)
+	}
+	return rows
+}
+
+// selectSpend повторяет spendConditions над строками агрегата.
+func selectSpend(rows []MonthlySpend, q SpendQuery) []MonthlySpend {
+	var selected []MonthlySpend
+	for _, row := range rows {
+		if row.Month.After(q.To) ||
+			(!q.From.IsZero() && row.Month.Before(q.From)) ||
+			(q.UserID != "" && row.UserID != q.UserID) ||
+			(q.ServiceName != "" && row.ServiceName != q.ServiceName) {
+			continue
+		}
+		selected = append(selected, row)
+	}
+	return selected
+}
+
+// groupSpend повторяет GROUP BY service_name, month запроса MonthlySpend.
+func groupSpend(rows []MonthlySpend) []*MonthlySpend {
+	index := make(map[string]*MonthlySpend)
+	var grouped []*MonthlySpend
+	for _, row := range rows {
+		key := row.ServiceName + "|" + row.Month.Format("2006-01")
+		g, ok := index[key]
+		if !ok {
+			g = &MonthlySpend{ServiceName: row.ServiceName, Month: row.Month}
+			index[key] = g
+			grouped = append(grouped, g)
+		}
+		g.Amount += row.Amount
+	}
+	return grouped
+}
+
+func TestSpendRowsSumToMonthlyCost(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 46))
+	through := testEpoch.AddDate(0, 72, 0)
+
+	for n := range 500 {
+		sub := randomSubscription(r, n)
+		byMonth := make(map[time.Time]int)
+		for _, row := range sub.SpendRows(through) {
+			byMonth[row.Month] += row.Amount
+		}
+		for month := testEpoch; !month.After(through); month = month.AddDate(0, 1, 0) {
+			if got, want := byMonth[month], sub.CostForMonth(month); got != want {
+				t.Fatalf("subscription %d, %s: rows sum to %d, CostForMonth is %d", n, month.Format("01-2006"), got, want)
+			}
+		}
+	}
+}
+
+func TestSpendRollupMatchesPeriodCost(t *testing.T) {
+	now := time.Date(2025, time.June, 17, 12, 30, 0, 0, time.UTC)
+	through := monthStart(now).AddDate(0, 24, 0)
+
+	for seed := range uint64(20) {
+		r := rand.New(rand.NewPCG(seed, 46))
+		subs := make([]*Subscription, 100)
+		for i := range subs {
+			subs[i] = randomSubscription(r, i)
+		}
+		rollup := materialize(subs, through)
+
+		for range 200 {
+			filters := randomFilters(r)
+			q, ok := NewSpendQuery(filters, now)
+			if !ok {
+				t.Fatalf("seed %d: filters %v should be answered by the rollup", seed, filters)
+			}
+			if q.To.After(through) {
+				continue
+			}
+
+			var matched []*Subscription
+			for _, sub := range subs {
+				if matchesCostConditions(sub, filters) {
+					matched = append(matched, sub)
+				}
+			}
+			want := PeriodCost(matched, filters, now)
+
+			got := 0
+			for _, row := range selectSpend(rollup, q) {
+				got += row.Amount
+			}
+			if got != want {
+				t.Fatalf("seed %d, filters %v: rollup total %d, on-the-fly total %d", seed, filters, got, want)
+			}
+		}
+	}
+}
+
+func TestBuildForecastFromSpendMatchesBuildForecast(t *testing.T) {
+	for seed := range uint64(20) {
+		r := rand.New(rand.NewPCG(seed, 1046))
+		subs := make([]*Subscription, 60)
+		for i := range subs {
+			subs[i] = randomSubscription(r, i)
+		}
+		rollup := materialize(subs, testEpoch.AddDate(0, 48+MaxForecastMonths, 0))
+
+		for range 50 {
+			from := randomMonth(r, 48)
+			months := 1 + r.IntN(MaxForecastMonths)
+			filters := map[string]interface{}{
+				"start_date_from": from,
+				"start_date_to":   from.AddDate(0, months-1, 0),
+			}
+			userID := ""
+			if r.IntN(2) == 0 {
+				userID = testUsers[r.IntN(len(testUsers))]
+				filters["user_id"] = userID
+			}
+			if r.IntN(2) == 0 {
+				filters["service_name"] = testServices[r.IntN(len(testServices))]
+			}
+
+			var matched []*Subscription
+			for _, sub := range subs {
+				if matchesCostConditions(sub, filters) {
+					matched = append(matched, sub)
+				}
+			}
+			want := BuildForecast(matched, from, months, userID)
+
+			q, ok := NewSpendQuery(filters, from)
+			if !ok {
+				t.Fatalf("seed %d: filters %v should be answered by the rollup", seed, filters)
+			}
+			got := BuildForecastFromSpend(groupSpend(selectSpend(rollup, q)), from, months)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("seed %d, filters %v, %d months: forecasts differ\nrollup:     %+v\non the fly: %+v",
+					seed, filters, months, got, want)
+			}
+		}
+	}
+}
+
+func TestNewSpendQuery(t *testing.T) {
+	now := time.Date(2025, time.June, 17, 12, 30, 0, 0, time.UTC)
+	month := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		want    SpendQuery
+		ok      bool
+	}{
+		{
+			name:    "no filters end with the current month",
+			filters: map[string]interface{}{},
+			want:    SpendQuery{To: monthStart(now)},
+			ok:      true,
+		},
+		{
+			name: "all supported filters",
+			filters: map[string]interface{}{
+				"user_id":         testUsers[0],
+				"service_name":    "Netflix",
+				"start_date_from": month,
+				"start_date_to":   month.AddDate(0, 5, 0),
+			},
+			want: SpendQuery{UserID: testUsers[0], ServiceName: "Netflix", From: month, To: month.AddDate(0, 5, 0)},
+			ok:   true,
+		},
+		{
+			name:    "several users",
+			filters: map[string]interface{}{"user_ids": []string{testUsers[0], testUsers[1]}},
+		},
+		{
+			name:    "date inside a month",
+			filters: map[string]interface{}{"start_date_from": month.AddDate(0, 0, 3)},
+		},
+		{
+			name:    "empty service name",
+			filters: map[string]interface{}{"service_name": ""},
+		},
+		{
+			name:    "date of another type",
+			filters: map[string]interface{}{"start_date_to": "03-2024"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NewSpendQuery(tt.filters, now)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Fatalf("query = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-CREATE TABLE subscriptions (
+CREATE TABLE IF NOT EXISTS subscriptions (
                                id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
                                service_name VARCHAR(255) NOT NULL,
                                price INTEGER NOT NULL CHECK (price > 0),
@@ -9,6 +9,6 @@ CREATE TABLE subscriptions (
                                updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
-CREATE INDEX idx_subscriptions_user_id ON subscriptions(user_id);
-CREATE INDEX idx_subscriptions_service_name ON subscriptions(service_name);
-CREATE INDEX idx_subscriptions_dates ON subscriptions(start_date, end_date);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id ON subscriptions(user_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name ON subscriptions(service_name);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_dates ON subscriptions(start_date, end_date);
\ No newline at end of file
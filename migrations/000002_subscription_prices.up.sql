@@ -0,0 +1,10 @@
+CREATE TABLE subscription_prices (
+                                     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                     subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+                                     price INTEGER NOT NULL CHECK (price > 0),
+                                     effective_from DATE NOT NULL,
+                                     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                                     UNIQUE (subscription_id, effective_from)
+);
+
+CREATE INDEX idx_subscription_prices_subscription_id ON subscription_prices(subscription_id);
@@ -0,0 +1,9 @@
+CREATE TABLE subscription_pauses (
+                                     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                     subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+                                     start_date DATE NOT NULL,
+                                     end_date DATE CHECK (end_date IS NULL OR end_date >= start_date),
+                                     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_subscription_pauses_subscription_id ON subscription_pauses(subscription_id);
@@ -0,0 +1,12 @@
+CREATE TABLE subscription_discounts (
+                                        id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                        subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+                                        kind VARCHAR(20) NOT NULL CHECK (kind IN ('percentage', 'fixed')),
+                                        value INTEGER NOT NULL CHECK (value > 0),
+                                        start_date DATE NOT NULL,
+                                        duration_periods INTEGER NOT NULL CHECK (duration_periods > 0),
+                                        description VARCHAR(255) NOT NULL DEFAULT '',
+                                        created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_subscription_discounts_subscription_id ON subscription_discounts(subscription_id);
@@ -0,0 +1,11 @@
+CREATE TABLE subscription_members (
+                                      subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+                                      user_id UUID NOT NULL,
+                                      share_percent INTEGER CHECK (share_percent BETWEEN 1 AND 100),
+                                      fixed_amount INTEGER CHECK (fixed_amount > 0),
+                                      created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                                      PRIMARY KEY (subscription_id, user_id),
+                                      CHECK ((share_percent IS NULL) <> (fixed_amount IS NULL))
+);
+
+CREATE INDEX idx_subscription_members_user_id ON subscription_members(user_id);
@@ -0,0 +1,10 @@
+CREATE TABLE budgets (
+                         id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                         user_id UUID NOT NULL,
+                         service_name VARCHAR(255),
+                         amount INTEGER NOT NULL CHECK (amount > 0),
+                         created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                         updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_budgets_user_scope ON budgets(user_id, COALESCE(service_name, ''));
@@ -0,0 +1,15 @@
+CREATE TABLE anomalies (
+                           id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                           kind VARCHAR(50) NOT NULL,
+                           user_id UUID NOT NULL,
+                           service_name VARCHAR(255) NOT NULL DEFAULT '',
+                           subscription_id UUID REFERENCES subscriptions(id) ON DELETE SET NULL,
+                           month DATE NOT NULL,
+                           value INTEGER NOT NULL,
+                           baseline DOUBLE PRECISION NOT NULL,
+                           score DOUBLE PRECISION NOT NULL,
+                           detected_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+                           UNIQUE (kind, user_id, service_name, month)
+);
+
+CREATE INDEX idx_anomalies_detected_at ON anomalies(detected_at);
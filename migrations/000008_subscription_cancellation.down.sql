@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_subscriptions_cancelled_at;
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS cancellation_comment,
+    DROP COLUMN IF EXISTS cancellation_reason,
+    DROP COLUMN IF EXISTS cancelled_at;
@@ -0,0 +1,6 @@
+ALTER TABLE subscriptions
+    ADD COLUMN cancelled_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN cancellation_reason VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN cancellation_comment TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_subscriptions_cancelled_at ON subscriptions(cancelled_at) WHERE cancelled_at IS NOT NULL;
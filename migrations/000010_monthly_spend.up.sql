@@ -0,0 +1,21 @@
+CREATE TABLE monthly_spend (
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    service_name VARCHAR(255) NOT NULL,
+    month DATE NOT NULL,
+    amount BIGINT NOT NULL,
+    PRIMARY KEY (subscription_id, user_id, month)
+);
+
+CREATE INDEX idx_monthly_spend_user_month ON monthly_spend(user_id, month);
+CREATE INDEX idx_monthly_spend_service_month ON monthly_spend(service_name, month);
+
+-- Единственная строка: до какого месяца заполнен агрегат и готов ли он к чтению
+CREATE TABLE monthly_spend_state (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    ready BOOLEAN NOT NULL DEFAULT FALSE,
+    materialized_through DATE NOT NULL,
+    pending_through DATE
+);
+
+INSERT INTO monthly_spend_state (materialized_through) VALUES (date_trunc('month', NOW())::date);
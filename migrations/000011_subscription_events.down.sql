@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS subscription_members_notify ON subscription_members;
+DROP TRIGGER IF EXISTS subscription_discounts_notify ON subscription_discounts;
+DROP TRIGGER IF EXISTS subscription_pauses_notify ON subscription_pauses;
+DROP TRIGGER IF EXISTS subscription_prices_notify ON subscription_prices;
+DROP TRIGGER IF EXISTS subscriptions_notify ON subscriptions;
+
+DROP FUNCTION IF EXISTS notify_subscription_detail_change();
+DROP FUNCTION IF EXISTS notify_subscription_change();
+DROP FUNCTION IF EXISTS notify_subscription_event(TEXT, UUID, UUID);
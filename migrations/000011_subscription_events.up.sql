@@ -0,0 +1,71 @@
+-- Изменения подписок рассылаются через NOTIFY, чтобы SSE-поток получали клиенты
+-- всех экземпляров сервиса. Уведомления доставляются только после COMMIT, а
+-- одинаковые уведомления одной транзакции PostgreSQL объединяет в одно.
+CREATE FUNCTION notify_subscription_event(event_type TEXT, subscription_id UUID, user_id UUID) RETURNS void AS $$
+BEGIN
+    PERFORM pg_notify('subscription_events', json_build_object(
+        'type', event_type,
+        'subscription_id', subscription_id,
+        'user_id', user_id,
+        'occurred_at', now()
+    )::text);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION notify_subscription_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM notify_subscription_event('subscription.created', NEW.id, NEW.user_id);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM notify_subscription_event('subscription.deleted', OLD.id, OLD.user_id);
+    ELSIF NEW IS DISTINCT FROM OLD THEN
+        PERFORM notify_subscription_event('subscription.updated', NEW.id, NEW.user_id);
+        -- Прежний владелец тоже должен узнать, что подписка у него больше не числится
+        IF NEW.user_id <> OLD.user_id THEN
+            PERFORM notify_subscription_event('subscription.updated', OLD.id, OLD.user_id);
+        END IF;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Цены, паузы, скидки и участники меняют подписку, поэтому отправляют updated её владельцу.
+-- При каскадном удалении подписки её строки уже нет и событие deleted уже отправлено.
+CREATE FUNCTION notify_subscription_detail_change() RETURNS trigger AS $$
+DECLARE
+    sub_id UUID;
+    owner_id UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        sub_id := OLD.subscription_id;
+    ELSE
+        sub_id := NEW.subscription_id;
+    END IF;
+
+    SELECT s.user_id INTO owner_id FROM subscriptions s WHERE s.id = sub_id;
+    IF FOUND THEN
+        PERFORM notify_subscription_event('subscription.updated', sub_id, owner_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subscriptions_notify
+    AFTER INSERT OR UPDATE OR DELETE ON subscriptions
+    FOR EACH ROW EXECUTE FUNCTION notify_subscription_change();
+
+CREATE TRIGGER subscription_prices_notify
+    AFTER INSERT OR UPDATE OR DELETE ON subscription_prices
+    FOR EACH ROW EXECUTE FUNCTION notify_subscription_detail_change();
+
+CREATE TRIGGER subscription_pauses_notify
+    AFTER INSERT OR UPDATE OR DELETE ON subscription_pauses
+    FOR EACH ROW EXECUTE FUNCTION notify_subscription_detail_change();
+
+CREATE TRIGGER subscription_discounts_notify
+    AFTER INSERT OR UPDATE OR DELETE ON subscription_discounts
+    FOR EACH ROW EXECUTE FUNCTION notify_subscription_detail_change();
+
+CREATE TRIGGER subscription_members_notify
+    AFTER INSERT OR UPDATE OR DELETE ON subscription_members
+    FOR EACH ROW EXECUTE FUNCTION notify_subscription_detail_change();
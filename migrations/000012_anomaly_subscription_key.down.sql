@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_anomalies_subscription_month_key;
+DROP INDEX IF EXISTS idx_anomalies_user_month_key;
+
+-- Прежний ключ допускает один выброс цены на пользователя и сервис в месяц
+DELETE FROM anomalies a
+USING anomalies b
+WHERE a.kind = b.kind
+  AND a.user_id = b.user_id
+  AND a.service_name = b.service_name
+  AND a.month = b.month
+  AND a.id > b.id;
+
+ALTER TABLE anomalies ADD CONSTRAINT anomalies_kind_user_id_service_name_month_key
+    UNIQUE (kind, user_id, service_name, month);
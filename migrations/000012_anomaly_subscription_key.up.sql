@@ -0,0 +1,13 @@
+-- Выбросы цены сохраняются по подписке: у пользователя может быть несколько подписок
+-- одного сервиса, и прежний ключ без subscription_id терял все, кроме первой.
+-- Для остальных видов аномалий subscription_id пуст, и ключ остаётся прежним.
+ALTER TABLE anomalies DROP CONSTRAINT IF EXISTS anomalies_kind_user_id_service_name_month_key;
+
+CREATE UNIQUE INDEX idx_anomalies_user_month_key
+    ON anomalies(kind, user_id, service_name, month)
+    WHERE kind <> 'price_outlier';
+
+-- После удаления подписки subscription_id становится NULL, такие строки ключ не ограничивает
+CREATE UNIQUE INDEX idx_anomalies_subscription_month_key
+    ON anomalies(kind, user_id, service_name, month, subscription_id)
+    WHERE kind = 'price_outlier';
@@ -0,0 +1,9 @@
+DELETE FROM subscription_discounts WHERE kind = 'trial';
+
+ALTER TABLE subscription_discounts DROP CONSTRAINT IF EXISTS subscription_discounts_kind_check;
+ALTER TABLE subscription_discounts DROP CONSTRAINT IF EXISTS subscription_discounts_value_check;
+
+ALTER TABLE subscription_discounts ADD CONSTRAINT subscription_discounts_kind_check
+    CHECK (kind IN ('percentage', 'fixed'));
+ALTER TABLE subscription_discounts ADD CONSTRAINT subscription_discounts_value_check
+    CHECK (value > 0);
@@ -0,0 +1,8 @@
+-- Пробный период хранится как скидка без значения, обнуляющая цену первых месяцев
+ALTER TABLE subscription_discounts DROP CONSTRAINT IF EXISTS subscription_discounts_kind_check;
+ALTER TABLE subscription_discounts DROP CONSTRAINT IF EXISTS subscription_discounts_value_check;
+
+ALTER TABLE subscription_discounts ADD CONSTRAINT subscription_discounts_kind_check
+    CHECK (kind IN ('percentage', 'fixed', 'trial'));
+ALTER TABLE subscription_discounts ADD CONSTRAINT subscription_discounts_value_check
+    CHECK ((kind = 'trial' AND value = 0) OR (kind <> 'trial' AND value > 0));
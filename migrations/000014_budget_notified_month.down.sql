@@ -0,0 +1 @@
+ALTER TABLE budgets DROP COLUMN IF EXISTS notified_month;
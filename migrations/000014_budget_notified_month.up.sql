@@ -0,0 +1,3 @@
+-- Месяц последнего уведомления о превышении хранится в строке бюджета, чтобы
+-- перезапуски и несколько экземпляров сервиса не отправляли его повторно
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS notified_month DATE;
@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL schema migrations into the binaries.
+package migrations
+
+import "embed"
+
+// FS содержит файлы NNNNNN_name.up.sql и NNNNNN_name.down.sql.
+//
+//go:embed *.sql
+var FS embed.FS
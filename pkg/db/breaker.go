@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of running a query while the database is
+// considered down.
+var ErrCircuitOpen = errors.New("database is unavailable: circuit breaker is open")
+
+// BreakerState is the state of a Breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects calls until the cooldown ends.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe through to check whether the database is back.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is a circuit breaker for database calls. After threshold consecutive
+// failures that mean the database is unavailable (see IsUnavailable) it opens
+// and rejects calls with ErrCircuitOpen for the cooldown, so requests fail fast
+// instead of waiting for connections. Then one probe call is let through: its
+// success closes the breaker, another failure opens it again.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	onChange  func(BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker creates a closed breaker. onChange, if set, is called with the
+// new state on every transition while the breaker's lock is held.
+func NewBreaker(threshold int, cooldown time.Duration, now func() time.Time, onChange func(BreakerState)) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: now, onChange: onChange}
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by Record with its result.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record учитывает результат разрешённого вызова. Любой ответ сервера, в том
+// числе ошибка запроса, считается успехом; отмена контекста ничего не меняет.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+	case !IsUnavailable(err):
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.setState(BreakerClosed)
+		}
+	default:
+		b.failures++
+		if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+			b.openedAt = b.now()
+			b.setState(BreakerOpen)
+		}
+	}
+}
+
+// State returns the current state.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) setState(state BreakerState) {
+	b.state = state
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
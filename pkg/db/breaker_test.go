@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2025, time.May, 1, 12, 0, 0, 0, time.UTC)
+	var transitions []BreakerState
+	b := NewBreaker(3, 10*time.Second, func() time.Time { return now }, func(s BreakerState) {
+		transitions = append(transitions, s)
+	})
+
+	down := &pgconn.PgError{Code: "08006"}
+	queryErr := &pgconn.PgError{Code: "23505"}
+
+	allow := func(want error) {
+		t.Helper()
+		if err := b.Allow(); !errors.Is(err, want) {
+			t.Fatalf("allow in state %s: got %v, want %v", b.State(), err, want)
+		}
+	}
+	expectState := func(want BreakerState) {
+		t.Helper()
+		if got := b.State(); got != want {
+			t.Fatalf("state %s, want %s", got, want)
+		}
+	}
+
+	// Ошибки запросов и отмена не считаются недоступностью и сбрасывают счётчик
+	for _, err := range []error{down, down, queryErr, down, down, context.Canceled, nil} {
+		allow(nil)
+		b.Record(err)
+	}
+	expectState(BreakerClosed)
+
+	for range 3 {
+		allow(nil)
+		b.Record(down)
+	}
+	expectState(BreakerOpen)
+
+	now = now.Add(9 * time.Second)
+	allow(ErrCircuitOpen)
+
+	// После паузы пропускается ровно одна пробная попытка
+	now = now.Add(time.Second)
+	allow(nil)
+	expectState(BreakerHalfOpen)
+	allow(ErrCircuitOpen)
+
+	// Неудачная проба снова открывает breaker на полную паузу
+	b.Record(down)
+	expectState(BreakerOpen)
+	now = now.Add(5 * time.Second)
+	allow(ErrCircuitOpen)
+
+	now = now.Add(5 * time.Second)
+	allow(nil)
+	b.Record(queryErr)
+	expectState(BreakerClosed)
+	allow(nil)
+
+	want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transitions %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestBreakerCancelledProbe(t *testing.T) {
+	now := time.Date(2025, time.May, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBreaker(1, time.Second, func() time.Time { return now }, nil)
+
+	b.Allow()
+	b.Record(&pgconn.PgError{Code: "57P03"})
+	now = now.Add(time.Second)
+
+	// Отменённая проба не решает судьбу breaker, но освобождает место для следующей
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	b.Record(context.DeadlineExceeded)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state %s, want half-open", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("second probe: %v", err)
+	}
+}
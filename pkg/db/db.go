@@ -7,19 +7,65 @@ import (
 	"time"
 )
 
-func NewPGXPool(ctx context.Context, connString string, logger *zap.Logger) (*pgxpool.Pool, error) {
+// PoolConfig — строка подключения и размеры пула соединений.
+type PoolConfig struct {
+	URL             string
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+}
+
+func NewPGXPool(ctx context.Context, cfg PoolConfig, logger *zap.Logger) (*pgxpool.Pool, error) {
+	pool, err := newPool(ctx, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Проверка подключения
+	if err := pool.Ping(ctx); err != nil {
+		logger.Error("Проверка подключения не пройдена", zap.Error(err))
+		pool.Close()
+		return nil, err
+	}
+
+	logger.Info("Пул соединений к PostgreSQL успешно создан",
+		zap.String("db_host", pool.Config().ConnConfig.Host),
+		zap.String("db_name", pool.Config().ConnConfig.Database),
+	)
 
+	return pool, nil
+}
+
+// NewReplicaPool создаёт пул реплики без проверки подключения: недоступная при
+// старте реплика не мешает запуску и начнёт получать чтения после проверки Router.
+func NewReplicaPool(ctx context.Context, cfg PoolConfig, logger *zap.Logger) (*pgxpool.Pool, error) {
+	pool, err := newPool(ctx, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Создан пул соединений к реплике",
+		zap.String("db_host", pool.Config().ConnConfig.Host),
+		zap.String("db_name", pool.Config().ConnConfig.Database),
+	)
+	return pool, nil
+}
+
+func newPool(ctx context.Context, cfg PoolConfig, logger *zap.Logger) (*pgxpool.Pool, error) {
 	// конфигурация пула
-	config, err := pgxpool.ParseConfig(connString)
+	config, err := pgxpool.ParseConfig(cfg.URL)
 	if err != nil {
 		logger.Error("Ошибка парсинга строки подключения", zap.Error(err))
 		return nil, err
 	}
 
-	config.MaxConns = 10
-	config.MinConns = 2
-	config.MaxConnLifetime = time.Hour
-	config.MaxConnIdleTime = 30 * time.Minute
+	config.MaxConns = cfg.MaxConns
+	config.MinConns = cfg.MinConns
+	config.MaxConnLifetime = cfg.MaxConnLifetime
+	config.MaxConnIdleTime = cfg.MaxConnIdleTime
+	// Каждый запрос становится дочерним span текущего запроса (см. internal/tracing)
+	config.ConnConfig.Tracer = newQueryTracer()
 
 	logger.Debug("Настройки пула соединений",
 		zap.Int("max_conns", int(config.MaxConns)),
@@ -32,17 +78,5 @@ func NewPGXPool(ctx context.Context, connString string, logger *zap.Logger) (*pg
 		logger.Error("Ошибка создания пула соединений", zap.Error(err))
 		return nil, err
 	}
-
-	// Проверка подключения
-	if err := pool.Ping(ctx); err != nil {
-		logger.Error("Проверка подключения не пройдена", zap.Error(err))
-		return nil, err
-	}
-
-	logger.Info("Пул соединений к PostgreSQL успешно создан",
-		zap.String("db_host", config.ConnConfig.Host),
-		zap.String("db_name", config.ConnConfig.Database),
-	)
-
 	return pool, nil
 }
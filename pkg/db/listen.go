@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// listenBackoff задаёт паузы между попытками восстановить соединение LISTEN;
+// Attempts не используется, попытки продолжаются до отмены контекста
+var listenBackoff = RetryPolicy{BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+
+// Listener receives PostgreSQL notifications on one channel over a dedicated
+// connection. NOTIFY is not replicated to standbys, so the pool must be the
+// primary one; the connection is taken out of the pool for as long as it listens.
+type Listener struct {
+	pool    *pgxpool.Pool
+	channel string
+	logger  *zap.Logger
+}
+
+func NewListener(pool *pgxpool.Pool, channel string, logger *zap.Logger) *Listener {
+	return &Listener{pool: pool, channel: channel, logger: logger}
+}
+
+// Run listens until ctx is cancelled and calls handle with the payload of
+// each notification. A lost connection is re-established with backoff; lost
+// is called first, because notifications sent in between are not delivered.
+func (l *Listener) Run(ctx context.Context, handle func(payload string), lost func()) {
+	for attempt := 0; ; attempt++ {
+		err := l.listen(ctx, handle, func() { attempt = 0 })
+		if ctx.Err() != nil {
+			return
+		}
+		lost()
+
+		delay := listenBackoff.delay(attempt)
+		l.logger.Warn("Соединение LISTEN потеряно, переподключение",
+			zap.String("channel", l.channel), zap.Duration("delay", delay), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// listen подписывается на канал и передаёт уведомления handle до ошибки соединения.
+// connected вызывается после успешного LISTEN.
+func (l *Listener) listen(ctx context.Context, handle func(payload string), connected func()) error {
+	pooled, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	conn := pooled.Hijack()
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn.Close(closeCtx)
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return err
+	}
+	connected()
+	l.logger.Info("Подписка на уведомления PostgreSQL", zap.String("channel", l.channel))
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handle(notification.Payload)
+	}
+}
@@ -0,0 +1,299 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// migrationLockID — ключ advisory lock, под которым выполняются миграции, чтобы
+// несколько экземпляров сервиса, запущенных одновременно, не применяли их параллельно.
+const migrationLockID int64 = 0x5375627363726970 // "Subscrip"
+
+// migrationFile соответствует именам вида 000001_init.up.sql и 000001_init.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// ErrNoDownMigration is returned when rolling back a migration without a .down.sql file.
+var ErrNoDownMigration = errors.New("migration has no down script")
+
+// Migration — одна версия схемы из каталога миграций.
+type Migration struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+	Up      string `json:"-"`
+	Down    string `json:"-"`
+}
+
+// MigrationStatus сообщает, применена ли миграция и когда.
+type MigrationStatus struct {
+	Migration
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator применяет SQL-миграции и хранит номера применённых версий в schema_migrations.
+type Migrator struct {
+	pool   *pgxpool.Pool
+	fsys   fs.FS
+	logger *zap.Logger
+}
+
+func NewMigrator(pool *pgxpool.Pool, fsys fs.FS, logger *zap.Logger) *Migrator {
+	return &Migrator{pool: pool, fsys: fsys, logger: logger}
+}
+
+// Up применяет все ещё не применённые миграции по возрастанию версии и возвращает их.
+// Каждая миграция выполняется в отдельной транзакции вместе с записью о версии.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, conn, mig, mig.Up, `INSERT INTO schema_migrations (version) VALUES ($1)`); err != nil {
+				return err
+			}
+			m.logger.Info("Миграция применена",
+				zap.Int64("version", mig.Version),
+				zap.String("name", mig.Name))
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down откатывает steps последних применённых миграций в обратном порядке.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("invalid number of migrations to roll back: %d", steps)
+	}
+
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, ErrNoDownMigration)
+			}
+			if err := m.run(ctx, conn, mig, mig.Down, `DELETE FROM schema_migrations WHERE version = $1`); err != nil {
+				return err
+			}
+			m.logger.Info("Миграция откачена",
+				zap.Int64("version", mig.Version),
+				zap.String("name", mig.Name))
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status возвращает все известные миграции с отметкой о применении.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	err = m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		statuses = make([]MigrationStatus, len(migrations))
+		for i, mig := range migrations {
+			statuses[i] = MigrationStatus{Migration: mig}
+			if appliedAt, ok := applied[mig.Version]; ok {
+				statuses[i].Applied = true
+				statuses[i].AppliedAt = &appliedAt
+			}
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Pending возвращает миграции, которые ещё не применены. В отличие от Status работает
+// без advisory lock, чтобы проверка готовности не ждала идущую миграцию.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mig := range migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// withLock выполняет fn на одном соединении, удерживая advisory lock миграций.
+// Блокировка сессионная, поэтому все запросы идут через это же соединение.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection for migrations: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Снимаем блокировку даже при отменённом ctx, иначе она останется до закрытия соединения
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			m.logger.Error("Не удалось снять блокировку миграций", zap.Error(err))
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// run выполняет скрипт миграции и обновляет schema_migrations в одной транзакции.
+func (m *Migrator) run(ctx context.Context, conn *pgxpool.Conn, mig Migration, script, record string) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", mig.Version, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return fmt.Errorf("failed to run migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.Exec(ctx, record, mig.Version); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// load читает пары *.up.sql/*.down.sql из корня fsys и сортирует их по версии.
+func (m *Migrator) load() ([]Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(m.fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// rolledBackCodes — SQLSTATE, при которых сервер откатил транзакцию целиком,
+// поэтому её можно повторить даже для неидемпотентной записи.
+var rolledBackCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+}
+
+// unavailableCodes — SQLSTATE, означающие, что сервер недоступен или не принимает
+// соединения. Такие ошибки учитывает circuit breaker.
+var unavailableCodes = map[string]bool{
+	"08000": true, // connection_exception
+	"08001": true, // sqlclient_unable_to_establish_sqlconnection
+	"08003": true, // connection_does_not_exist
+	"08004": true, // sqlserver_rejected_establishment_of_sqlconnection
+	"08006": true, // connection_failure
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// IsUnavailable reports whether err means the database cannot be reached: an
+// open circuit breaker, a failed connection or a server that is shutting down.
+// Context cancellation and deadlines are not treated as unavailability.
+func IsUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return unavailableCodes[pgErr.Code]
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// IsTransient reports whether an idempotent operation that failed with err
+// may succeed if repeated.
+func IsTransient(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	return IsRolledBack(err) || IsUnavailable(err)
+}
+
+// IsRolledBack reports whether err guarantees that nothing was committed, so
+// even a non-idempotent write may be repeated: the server rolled back the
+// transaction or the request never reached it.
+func IsRolledBack(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return rolledBackCodes[pgErr.Code]
+	}
+	return pgconn.SafeToRetry(err)
+}
+
+// RetryPolicy repeats failed operations with exponential backoff and full
+// jitter: the delay before attempt n is random in [0, min(MaxDelay, BaseDelay·2ⁿ)).
+type RetryPolicy struct {
+	// Attempts is the total number of tries, including the first one.
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Do calls fn until it succeeds or returns an error retryable rejects. It
+// stops early when attempts run out, ctx is done, or the next delay would end
+// after ctx's deadline, and returns the last error of fn. retried, if set, is
+// called before each repeat.
+func (p RetryPolicy) Do(ctx context.Context, retryable func(error) bool, retried func(err error), fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || !retryable(err) || attempt+1 >= p.Attempts {
+			return err
+		}
+
+		delay := p.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			return err
+		}
+		if retried != nil {
+			retried(err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	limit := p.MaxDelay
+	if attempt < 32 {
+		if d := p.BaseDelay << attempt; d > 0 && d < limit {
+			limit = d
+		}
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit)
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		rolledBack  bool
+		transient   bool
+		unavailable bool
+	}{
+		{name: "serialization failure", err: &pgconn.PgError{Code: "40001"}, rolledBack: true, transient: true},
+		{name: "deadlock", err: &pgconn.PgError{Code: "40P01"}, rolledBack: true, transient: true},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, transient: true, unavailable: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}},
+		{name: "wrapped serialization failure", err: fmt.Errorf("failed to update subscription: %w", &pgconn.PgError{Code: "40001"}), rolledBack: true, transient: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, transient: true, unavailable: true},
+		{name: "open circuit", err: ErrCircuitOpen, unavailable: true},
+		{name: "cancelled", err: context.Canceled},
+		{name: "deadline", err: context.DeadlineExceeded},
+		{name: "nil", err: nil},
+		{name: "other", err: errors.New("boom")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRolledBack(tt.err); got != tt.rolledBack {
+				t.Errorf("IsRolledBack = %v, want %v", got, tt.rolledBack)
+			}
+			if got := IsTransient(tt.err); got != tt.transient {
+				t.Errorf("IsTransient = %v, want %v", got, tt.transient)
+			}
+			if got := IsUnavailable(tt.err); got != tt.unavailable {
+				t.Errorf("IsUnavailable = %v, want %v", got, tt.unavailable)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	transient := &pgconn.PgError{Code: "40001"}
+	permanent := &pgconn.PgError{Code: "23505"}
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	tests := []struct {
+		name string
+		// results — ошибки последовательных вызовов; после них вызовы успешны
+		results   []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", wantCalls: 1},
+		{name: "recovers", results: []error{transient, transient}, wantCalls: 3},
+		{name: "attempts exhausted", results: []error{transient, transient, transient, transient}, wantCalls: 3, wantErr: transient},
+		{name: "not retryable", results: []error{permanent}, wantCalls: 1, wantErr: permanent},
+		{name: "retryable then permanent", results: []error{transient, permanent}, wantCalls: 2, wantErr: permanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, retried := 0, 0
+			err := policy.Do(context.Background(), IsTransient, func(error) { retried++ }, func() error {
+				calls++
+				if calls <= len(tt.results) {
+					return tt.results[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls || retried != calls-1 {
+				t.Fatalf("calls %d, retried %d, want %d calls", calls, retried, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoDeadline(t *testing.T) {
+	transient := &pgconn.PgError{Code: "40001"}
+
+	t.Run("delay past the deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		// Любая пауза с BaseDelay в час закончилась бы после дедлайна
+		policy := RetryPolicy{Attempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+		calls := 0
+		start := time.Now()
+		err := policy.Do(ctx, IsTransient, nil, func() error {
+			calls++
+			return transient
+		})
+		if !errors.Is(err, transient) || calls != 1 || time.Since(start) > 10*time.Millisecond {
+			t.Fatalf("error %v after %d calls in %v", err, calls, time.Since(start))
+		}
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		policy := RetryPolicy{Attempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+		calls := 0
+		err := policy.Do(ctx, IsTransient, func(error) { cancel() }, func() error {
+			calls++
+			return transient
+		})
+		if !errors.Is(err, transient) || calls != 1 {
+			t.Fatalf("error %v after %d calls", err, calls)
+		}
+	})
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for attempt, limit := range []time.Duration{10, 20, 40, 50, 50} {
+		for range 100 {
+			if d := policy.delay(attempt); d < 0 || d >= limit*time.Millisecond {
+				t.Fatalf("attempt %d: delay %v, want below %v", attempt, d, limit*time.Millisecond)
+			}
+		}
+	}
+	if d := policy.delay(100); d < 0 || d >= policy.MaxDelay {
+		t.Fatalf("delay %v for a large attempt", d)
+	}
+	if d := (RetryPolicy{}).delay(3); d != 0 {
+		t.Fatalf("delay %v without limits", d)
+	}
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// replicationLagQuery возвращает отставание реплики в секундах. Если реплика
+// применила всё полученное, отставание нулевое, даже когда на primary давно не
+// было записей и pg_last_xact_replay_timestamp устарел.
+const replicationLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END::float8`
+
+// Router sends writes to the primary pool and reads to replicas. A replica
+// receives reads only after it has passed a health check; when none has, reads
+// fall back to the primary. Within a session (see WithSession) reads go to the
+// primary after the first write, so a request always sees its own changes.
+type Router struct {
+	primary  *pgxpool.Pool
+	replicas []*replica
+	next     atomic.Uint64
+	maxLag   time.Duration
+	logger   *zap.Logger
+}
+
+type replica struct {
+	pool    *pgxpool.Pool
+	name    string
+	healthy atomic.Bool
+}
+
+// NewRouter creates a router. maxLag is the replication lag above which a
+// replica stops receiving reads; 0 disables the lag check.
+func NewRouter(primary *pgxpool.Pool, replicas []*pgxpool.Pool, maxLag time.Duration, logger *zap.Logger) *Router {
+	r := &Router{primary: primary, maxLag: maxLag, logger: logger}
+	for _, pool := range replicas {
+		cc := pool.Config().ConnConfig
+		r.replicas = append(r.replicas, &replica{pool: pool, name: fmt.Sprintf("%s:%d", cc.Host, cc.Port)})
+	}
+	return r
+}
+
+// Primary returns the pool for writes and for reads that must not lag.
+func (r *Router) Primary() *pgxpool.Pool {
+	return r.primary
+}
+
+// Read returns the pool for a read-only query: the next healthy replica, or
+// the primary if the session has written or no replica is healthy.
+func (r *Router) Read(ctx context.Context) *pgxpool.Pool {
+	if len(r.replicas) == 0 || written(ctx) {
+		return r.primary
+	}
+
+	// Счётчик делится только между здоровыми репликами, иначе доля исключённой
+	// реплики целиком доставалась бы следующей за ней
+	healthy := make([]*pgxpool.Pool, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			healthy = append(healthy, rep.pool)
+		}
+	}
+	if len(healthy) == 0 {
+		return r.primary
+	}
+	return healthy[(r.next.Add(1)-1)%uint64(len(healthy))]
+}
+
+// Check проверяет доступность и отставание каждой реплики и включает или
+// исключает её из чтения. Каждая проверка ограничена timeout.
+func (r *Router) Check(ctx context.Context, timeout time.Duration) {
+	for _, rep := range r.replicas {
+		err := r.check(ctx, rep, timeout)
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			r.logger.Info("Реплика доступна для чтения", zap.String("replica", rep.name))
+		} else {
+			r.logger.Warn("Реплика исключена из чтения", zap.String("replica", rep.name), zap.Error(err))
+		}
+	}
+}
+
+func (r *Router) check(ctx context.Context, rep *replica, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lag float64
+	if err := rep.pool.QueryRow(ctx, replicationLagQuery).Scan(&lag); err != nil {
+		return err
+	}
+	if lag := time.Duration(lag * float64(time.Second)); r.maxLag > 0 && lag > r.maxLag {
+		return fmt.Errorf("replication lag %s exceeds %s", lag.Round(time.Millisecond), r.maxLag)
+	}
+	return nil
+}
+
+// Run проверяет реплики раз в interval до отмены контекста. До первой проверки
+// все чтения идут в primary.
+func (r *Router) Run(ctx context.Context, interval time.Duration) {
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Check(ctx, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close закрывает пулы реплик; primary закрывает тот, кто его создал.
+func (r *Router) Close() {
+	for _, rep := range r.replicas {
+		rep.pool.Close()
+	}
+}
+
+type sessionKey struct{}
+
+// session отмечает, что в рамках запроса уже была запись.
+type session struct {
+	written atomic.Bool
+}
+
+// WithSession starts a read-your-writes session, usually one per request.
+// Without a session every read may go to a replica.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// MarkWrite records that the session in ctx has written to the primary, so
+// the following reads in it are not served by a lagging replica.
+func MarkWrite(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.written.Store(true)
+	}
+}
+
+func written(ctx context.Context) bool {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	return ok && s.written.Load()
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// newTestRouter создаёт router с пулами, которые ни разу не подключаются:
+// Read только выбирает пул, а здоровье реплик задаётся вручную.
+func newTestRouter(t *testing.T, replicas int) (*Router, *pgxpool.Pool, []*pgxpool.Pool) {
+	t.Helper()
+	pool := func(port int) *pgxpool.Pool {
+		p, err := pgxpool.New(context.Background(), fmt.Sprintf("postgres://app@127.0.0.1:%d/subscriptions", port))
+		if err != nil {
+			t.Fatalf("create pool: %v", err)
+		}
+		t.Cleanup(p.Close)
+		return p
+	}
+
+	primary := pool(5432)
+	pools := make([]*pgxpool.Pool, replicas)
+	for i := range pools {
+		pools[i] = pool(5433 + i)
+	}
+	return NewRouter(primary, pools, 0, zap.NewNop()), primary, pools
+}
+
+func TestRouterRead(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("round robin over healthy replicas", func(t *testing.T) {
+		r, primary, pools := newTestRouter(t, 3)
+		for _, rep := range r.replicas {
+			rep.healthy.Store(true)
+		}
+		r.replicas[1].healthy.Store(false)
+
+		counts := make(map[*pgxpool.Pool]int)
+		for range 10 {
+			counts[r.Read(ctx)]++
+		}
+		if counts[pools[0]] != 5 || counts[pools[2]] != 5 || counts[pools[1]] != 0 || counts[primary] != 0 {
+			t.Fatalf("reads per pool: first %d, second %d, third %d, primary %d",
+				counts[pools[0]], counts[pools[1]], counts[pools[2]], counts[primary])
+		}
+	})
+
+	t.Run("primary when no replica is healthy", func(t *testing.T) {
+		r, primary, _ := newTestRouter(t, 2)
+		// До первой проверки реплики считаются недоступными
+		if got := r.Read(ctx); got != primary {
+			t.Fatal("read before the first check went to a replica")
+		}
+		r.replicas[0].healthy.Store(true)
+		r.replicas[0].healthy.Store(false)
+		if got := r.Read(ctx); got != primary {
+			t.Fatal("read went to an unhealthy replica")
+		}
+	})
+
+	t.Run("primary without replicas", func(t *testing.T) {
+		r, primary, _ := newTestRouter(t, 0)
+		if got := r.Read(ctx); got != primary || r.Primary() != primary {
+			t.Fatal("read did not go to the primary")
+		}
+	})
+
+	t.Run("write forces the primary for the session", func(t *testing.T) {
+		r, primary, pools := newTestRouter(t, 1)
+		r.replicas[0].healthy.Store(true)
+
+		session := WithSession(ctx)
+		if got := r.Read(session); got != pools[0] {
+			t.Fatal("read before a write did not go to the replica")
+		}
+		MarkWrite(session)
+		for range 3 {
+			if got := r.Read(session); got != primary {
+				t.Fatal("read after a write did not go to the primary")
+			}
+		}
+
+		// Другие сессии и запросы без сессии по-прежнему читают с реплики
+		if got := r.Read(WithSession(ctx)); got != pools[0] {
+			t.Fatal("write leaked into another session")
+		}
+		MarkWrite(ctx)
+		if got := r.Read(ctx); got != pools[0] {
+			t.Fatal("write without a session forced the primary")
+		}
+	})
+}